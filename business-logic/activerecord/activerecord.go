@@ -6,19 +6,35 @@ import (
 	"fmt"
 
 	"github.com/google/uuid"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
 )
 
 const (
-	humanFindByID = "SELECT id, name, weight, height from human WHERE id = '%s';"
-	humanInsert   = "INSERT INTO human (id, name, weight, height) VALUES('%s','%s','%d','%d');"
-	humanUpdate   = "UPDATE human SET id = %s, name = %s, weight = %d, height = %d;"
-	humanDelete   = "DELETE FROM human WHERE id = %s;"
+	humanFindByID = "SELECT id, name, weight, height from human WHERE id = $1;"
+	humanInsert   = "INSERT INTO human (id, name, weight, height) VALUES($1, $2, $3, $4);"
+	humanUpdate   = "UPDATE human SET name = $1, weight = $2, height = $3 WHERE id = $4;"
+	humanDelete   = "DELETE FROM human WHERE id = $1;"
 )
 
-type sqlDbClient interface {
-	Exec(ctx context.Context, query string)
-	Query(ctx context.Context, query string) *sql.Row
-}
+type (
+	sqlExecer interface {
+		Exec(ctx context.Context, query string, args ...any) error
+	}
+
+	// sqlTx is a database transaction. Writes to a record and to its audit trail go through the
+	// same transaction so that one is never persisted without the other.
+	sqlTx interface {
+		sqlExecer
+		Commit() error
+		Rollback() error
+	}
+
+	sqlDbClient interface {
+		sqlExecer
+		Query(ctx context.Context, query string, args ...any) *sql.Row
+		Begin(ctx context.Context) sqlTx
+	}
+)
 
 type Human struct {
 	dbClient sqlDbClient
@@ -27,20 +43,29 @@ type Human struct {
 	Name   string
 	Weight int // kg
 	Height int // centimeters
+
+	persisted *HumanValues // the values as last read from or written to the database, used for auditing
+}
+
+// HumanValues are the auditable values of a Human
+type HumanValues struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+	Height int    `json:"height"`
 }
 
 func NewHuman(dbClient sqlDbClient, name string, weight, height int) (*Human, error) {
 	// Validate input to only allow instantiation of humans with sane values
 	if name == "" {
-		return nil, fmt.Errorf("name must not be empty")
+		return nil, apperror.Validation("HUMAN_INVALID_NAME", "name must not be empty")
 	}
 
 	if weight < 4 || weight > 300 {
-		return nil, fmt.Errorf("weight outside valid range")
+		return nil, apperror.Validation("HUMAN_INVALID_WEIGHT", "weight = %d outside valid range", weight)
 	}
 
 	if height < 35 || height > 250 {
-		return nil, fmt.Errorf("height outside valid range")
+		return nil, apperror.Validation("HUMAN_INVALID_HEIGHT", "height = %d outside valid range", height)
 	}
 
 	return &Human{
@@ -53,21 +78,47 @@ func NewHuman(dbClient sqlDbClient, name string, weight, height int) (*Human, er
 }
 
 // Encapuslation of persistence mechanism interaction
-func (h *Human) Insert(ctx context.Context) {
-	query := fmt.Sprintf(humanInsert, h.ID, h.Name, h.Weight, h.Height)
-	h.dbClient.Exec(ctx, query)
+func (h *Human) Insert(ctx context.Context) error {
+	return h.write(ctx, AuditOperationInsert, h.values(), humanInsert, h.ID.String(), h.Name, h.Weight, h.Height)
 }
 
 // Encapuslation of persistence mechanism interaction
-func (h *Human) Update(ctx context.Context) {
-	query := fmt.Sprintf(humanUpdate, h.ID, h.Name, h.Weight, h.Height)
-	h.dbClient.Exec(ctx, query)
+func (h *Human) Update(ctx context.Context) error {
+	return h.write(ctx, AuditOperationUpdate, h.values(), humanUpdate, h.Name, h.Weight, h.Height, h.ID.String())
 }
 
 // Encapuslation of persistence mechanism interaction
-func (h *Human) Delete(ctx context.Context) {
-	query := fmt.Sprintf(humanDelete, h.ID)
-	h.dbClient.Exec(ctx, query)
+func (h *Human) Delete(ctx context.Context) error {
+	return h.write(ctx, AuditOperationDelete, nil, humanDelete, h.ID.String())
+}
+
+// write executes the query and records the change in the audit trail within the same transaction.
+// Nothing is committed if either fails.
+func (h *Human) write(ctx context.Context, op AuditOperation, newValues *HumanValues, query string, args ...any) error {
+	tx := h.dbClient.Begin(ctx)
+	defer func() {
+		tx.Rollback()
+	}()
+
+	if err := tx.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("could not %s human by id = %s: %w", op, h.ID, err)
+	}
+
+	entry := newAuditEntry(ctx, h.ID, op, h.persisted, newValues)
+	if err := entry.insert(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit %s of human by id = %s: %w", op, h.ID, err)
+	}
+	h.persisted = newValues
+
+	return nil
+}
+
+func (h *Human) values() *HumanValues {
+	return &HumanValues{Name: h.Name, Weight: h.Weight, Height: h.Height}
 }
 
 // Business logic
@@ -2,11 +2,17 @@ package activerecord
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
 )
 
+// ErrHumanNotFound is returned when there is no human with the given id
+var ErrHumanNotFound = apperror.New(apperror.CategoryNotFound, "HUMAN_NOT_FOUND", "human not found")
+
 // humanQuerier is an implementation of the local humanFinder interface.
 type humanQuerier struct {
 	dbClient sqlDbClient
@@ -17,9 +23,12 @@ func NewHumanQuerier(dbClient sqlDbClient) *humanQuerier {
 }
 
 // Useful methods to reconstitute humans ...
-func (q *humanQuerier) FindByID(ctx context.Context, id string) *Human {
-	query := fmt.Sprintf(humanFindByID, id)
-	row := q.dbClient.Query(ctx, query)
+func (q *humanQuerier) FindByID(ctx context.Context, id string) (*Human, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, apperror.Validation("HUMAN_INVALID_ID", "could not parse id: %w", err)
+	}
+
+	row := q.dbClient.Query(ctx, humanFindByID, id)
 
 	var (
 		idField uuid.UUID
@@ -28,11 +37,22 @@ func (q *humanQuerier) FindByID(ctx context.Context, id string) *Human {
 		height  int
 	)
 
-	_ = row.Scan(&idField, &name, &weight, &height)
-	return &Human{
-		ID:     idField,
-		Name:   name,
-		Weight: weight,
-		Height: height,
+	if err := row.Scan(&idField, &name, &weight, &height); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("could not find human by id = %s: %w", id, ErrHumanNotFound)
+		}
+
+		return nil, fmt.Errorf("could not find human by id = %s: %w", id, err)
 	}
+
+	human := &Human{
+		dbClient: q.dbClient,
+		ID:       idField,
+		Name:     name,
+		Weight:   weight,
+		Height:   height,
+	}
+	human.persisted = human.values()
+
+	return human, nil
 }
@@ -0,0 +1,92 @@
+package activerecord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	auditInsert         = "INSERT INTO human_audit (id, record_id, operation, actor, changed_at, old_values, new_values) VALUES($1, $2, $3, $4, $5, $6, $7);"
+	auditFindByRecordID = "SELECT id, record_id, operation, actor, changed_at, old_values, new_values FROM human_audit WHERE record_id = $1 ORDER BY changed_at;"
+	auditActorAnonymous = "anonymous"
+
+	// auditTimestampLayout is fixed-width, unlike time.RFC3339Nano, so that the timestamps sort in
+	// order as text. They're always in UTC.
+	auditTimestampLayout = "2006-01-02T15:04:05.000000000Z07:00"
+)
+
+type AuditOperation string
+
+const (
+	AuditOperationInsert AuditOperation = "insert"
+	AuditOperationUpdate AuditOperation = "update"
+	AuditOperationDelete AuditOperation = "delete"
+)
+
+// now is the clock used to timestamp audit entries. It's a variable so tests can replace it.
+var now = time.Now
+
+type actorCtxKey struct{}
+
+// WithActor returns a copy of ctx carrying the actor that is making changes to active records
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorCtxKey{}, actor)
+}
+
+// ActorFromContext returns the actor carried by ctx or "anonymous" if there is none
+func ActorFromContext(ctx context.Context) string {
+	actor, ok := ctx.Value(actorCtxKey{}).(string)
+	if !ok || actor == "" {
+		return auditActorAnonymous
+	}
+
+	return actor
+}
+
+// AuditEntry is an active record of a single change made to a Human. OldValues is nil for
+// inserts and NewValues is nil for deletes.
+type AuditEntry struct {
+	ID        uuid.UUID
+	RecordID  uuid.UUID
+	Operation AuditOperation
+	Actor     string
+	ChangedAt time.Time
+	OldValues *HumanValues
+	NewValues *HumanValues
+}
+
+func newAuditEntry(ctx context.Context, recordID uuid.UUID, op AuditOperation, oldValues, newValues *HumanValues) *AuditEntry {
+	return &AuditEntry{
+		ID:        uuid.New(),
+		RecordID:  recordID,
+		Operation: op,
+		Actor:     ActorFromContext(ctx),
+		ChangedAt: now().UTC(),
+		OldValues: oldValues,
+		NewValues: newValues,
+	}
+}
+
+// Encapuslation of persistence mechanism interaction. The entry is written using the same
+// transaction as the change it records.
+func (e *AuditEntry) insert(ctx context.Context, tx sqlExecer) error {
+	oldValues, err := json.Marshal(e.OldValues)
+	if err != nil {
+		return fmt.Errorf("could not marshal old values: %w", err)
+	}
+	newValues, err := json.Marshal(e.NewValues)
+	if err != nil {
+		return fmt.Errorf("could not marshal new values: %w", err)
+	}
+
+	if err = tx.Exec(ctx, auditInsert, e.ID.String(), e.RecordID.String(), string(e.Operation), e.Actor,
+		e.ChangedAt.UTC().Format(auditTimestampLayout), string(oldValues), string(newValues)); err != nil {
+		return fmt.Errorf("could not insert audit entry of human by id = %s: %w", e.RecordID, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package activerecord
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type sqlDbRowsClient interface {
+	QueryRows(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// auditQuerier is an implementation of the local auditFinder interface.
+type auditQuerier struct {
+	dbClient sqlDbRowsClient
+}
+
+func NewAuditQuerier(dbClient sqlDbRowsClient) *auditQuerier {
+	return &auditQuerier{dbClient: dbClient}
+}
+
+// FindByRecordID returns the history of changes made to the record with the given id, oldest first
+func (q *auditQuerier) FindByRecordID(ctx context.Context, id string) ([]AuditEntry, error) {
+	rows, err := q.dbClient.QueryRows(ctx, auditFindByRecordID, id)
+	if err != nil {
+		return nil, fmt.Errorf("could not find audit entries of human by id = %s: %w", id, err)
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var (
+			entryID   uuid.UUID
+			recordID  uuid.UUID
+			operation string
+			actor     string
+			changedAt string
+			oldValues []byte
+			newValues []byte
+		)
+
+		if err = rows.Scan(&entryID, &recordID, &operation, &actor, &changedAt, &oldValues, &newValues); err != nil {
+			return nil, fmt.Errorf("could not scan audit entry of human by id = %s: %w", id, err)
+		}
+		entry := AuditEntry{
+			ID:        entryID,
+			RecordID:  recordID,
+			Operation: AuditOperation(operation),
+			Actor:     actor,
+		}
+		if entry.ChangedAt, err = time.Parse(auditTimestampLayout, changedAt); err != nil {
+			return nil, fmt.Errorf("could not parse changed at of audit entry by id = %s: %w", entryID, err)
+		}
+		if err = json.Unmarshal(oldValues, &entry.OldValues); err != nil {
+			return nil, fmt.Errorf("could not unmarshal old values of audit entry by id = %s: %w", entryID, err)
+		}
+		if err = json.Unmarshal(newValues, &entry.NewValues); err != nil {
+			return nil, fmt.Errorf("could not unmarshal new values of audit entry by id = %s: %w", entryID, err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not find audit entries of human by id = %s: %w", id, err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,297 @@
+package activerecord
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/migrations"
+	_ "modernc.org/sqlite"
+)
+
+type execution struct {
+	query string
+	args  []any
+}
+
+type fakeTx struct {
+	ExecErr error // returned by the Exec of the audit entry
+
+	executions []execution
+	committed  bool
+	rolledBack bool
+}
+
+func (tx *fakeTx) Exec(ctx context.Context, query string, args ...any) error {
+	tx.executions = append(tx.executions, execution{query: query, args: args})
+	if strings.HasPrefix(query, "INSERT INTO human_audit") {
+		return tx.ExecErr
+	}
+
+	return nil
+}
+func (tx *fakeTx) Commit() error { tx.committed = true; return nil }
+func (tx *fakeTx) Rollback() error {
+	if !tx.committed {
+		tx.rolledBack = true
+	}
+	return nil
+}
+
+type fakeDbClient struct {
+	tx *fakeTx
+}
+
+func (c *fakeDbClient) Exec(ctx context.Context, query string, args ...any) error     { return nil }
+func (c *fakeDbClient) Query(ctx context.Context, query string, args ...any) *sql.Row { return nil }
+func (c *fakeDbClient) Begin(ctx context.Context) sqlTx                               { return c.tx }
+
+func TestHuman_AuditTrail(t *testing.T) {
+	fixedNow := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return fixedNow }
+	defer func() { now = time.Now }()
+
+	persisted := &HumanValues{Name: "Jane", Weight: 70, Height: 170}
+
+	tests := []struct {
+		name          string
+		persisted     *HumanValues
+		execErr       error
+		write         func(ctx context.Context, h *Human) error
+		wantQuery     string
+		wantAuditArgs []any // the audit entry's operation, actor, changed at, old and new values
+		wantErr       bool
+	}{
+		{
+			name:          "should record insert without old values",
+			write:         func(ctx context.Context, h *Human) error { return h.Insert(ctx) },
+			wantQuery:     "INSERT INTO human ",
+			wantAuditArgs: []any{"insert", "o'brien", "2022-08-01T12:00:00.000000000Z", "null", `{"name":"Jane","weight":72,"height":170}`},
+		},
+		{
+			name:          "should record update with old and new values",
+			persisted:     persisted,
+			write:         func(ctx context.Context, h *Human) error { return h.Update(ctx) },
+			wantQuery:     "UPDATE human ",
+			wantAuditArgs: []any{"update", "o'brien", "2022-08-01T12:00:00.000000000Z", `{"name":"Jane","weight":70,"height":170}`, `{"name":"Jane","weight":72,"height":170}`},
+		},
+		{
+			name:          "should record delete without new values",
+			persisted:     persisted,
+			write:         func(ctx context.Context, h *Human) error { return h.Delete(ctx) },
+			wantQuery:     "DELETE FROM human ",
+			wantAuditArgs: []any{"delete", "o'brien", "2022-08-01T12:00:00.000000000Z", `{"name":"Jane","weight":70,"height":170}`, "null"},
+		},
+		{
+			name:      "should return error and roll back when the audit entry can't be written",
+			persisted: persisted,
+			execErr:   errors.New("disk full"),
+			write:     func(ctx context.Context, h *Human) error { return h.Update(ctx) },
+			wantQuery: "UPDATE human ",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			tx := &fakeTx{ExecErr: tt.execErr}
+			h := &Human{
+				dbClient:  &fakeDbClient{tx: tx},
+				ID:        uuid.New(),
+				Name:      "Jane",
+				Weight:    72,
+				Height:    170,
+				persisted: tt.persisted,
+			}
+			ctx := WithActor(context.Background(), "o'brien")
+
+			// When
+			err := tt.write(ctx, h)
+
+			// Then
+			require.Len(tx.executions, 2)
+			require.True(strings.HasPrefix(tx.executions[0].query, tt.wantQuery), "query %q should start with %q", tx.executions[0].query, tt.wantQuery)
+			require.Equal(auditInsert, tx.executions[1].query)
+			if tt.wantErr {
+				require.True(errors.Is(err, tt.execErr), "expected %v to wrap %v", err, tt.execErr)
+				require.False(tx.committed)
+				require.True(tx.rolledBack)
+				require.Equal(tt.persisted, h.persisted)
+				return
+			}
+			require.NoError(err)
+			require.True(tx.committed)
+			require.False(tx.rolledBack)
+			require.Equal(h.ID.String(), tx.executions[1].args[1])
+			require.Equal(tt.wantAuditArgs, tx.executions[1].args[2:])
+		})
+	}
+}
+
+// sqlRowsClient runs the queries of the audit querier against a database
+type sqlRowsClient struct {
+	db *sql.DB
+}
+
+func (c sqlRowsClient) QueryRows(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return c.db.QueryContext(ctx, query, args...)
+}
+
+func TestAuditQuerier_FindByRecordID(t *testing.T) {
+	// Given entries written in the same second, whose timestamps sorted out of order with
+	// time.RFC3339Nano, and an actor that would break a query built with fmt.Sprintf
+	require := require.New(t)
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "audit.db"))
+	require.NoError(err)
+	defer db.Close()
+	runner, err := migrations.NewRunner(db, migrations.SQLite)
+	require.NoError(err)
+	require.NoError(runner.Up(ctx))
+
+	recordID := uuid.New()
+	second := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	changes := []struct {
+		at        time.Time
+		operation AuditOperation
+	}{
+		{at: second, operation: AuditOperationInsert},
+		{at: second.Add(100 * time.Millisecond), operation: AuditOperationUpdate},
+		{at: second.Add(120 * time.Millisecond), operation: AuditOperationDelete},
+	}
+	for _, change := range changes {
+		entry := newAuditEntry(WithActor(ctx, "o'brien"), recordID, change.operation, nil, &HumanValues{Name: "Jane"})
+		entry.ChangedAt = change.at
+		require.NoError(entry.insert(ctx, sqlExecClient{db: db}))
+	}
+
+	// When
+	entries, err := NewAuditQuerier(sqlRowsClient{db: db}).FindByRecordID(ctx, recordID.String())
+
+	// Then
+	require.NoError(err)
+	require.Len(entries, len(changes))
+	for i, change := range changes {
+		require.Equal(change.operation, entries[i].Operation)
+		require.Equal(change.at, entries[i].ChangedAt)
+		require.Equal("o'brien", entries[i].Actor)
+		require.Nil(entries[i].OldValues)
+		require.Equal(&HumanValues{Name: "Jane"}, entries[i].NewValues)
+	}
+}
+
+// sqlExecClient runs the statements of active records against a database
+type sqlExecClient struct {
+	db *sql.DB
+}
+
+func (c sqlExecClient) Exec(ctx context.Context, query string, args ...any) error {
+	_, err := c.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+func TestActorFromContext(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("anonymous", ActorFromContext(context.Background()))
+	require.Equal("alice", ActorFromContext(WithActor(context.Background(), "alice")))
+}
+
+// sqlClient runs the statements and transactions of active records against a database
+type sqlClient struct {
+	sqlExecClient
+}
+
+func (c sqlClient) Query(ctx context.Context, query string, args ...any) *sql.Row {
+	return c.db.QueryRowContext(ctx, query, args...)
+}
+
+func (c sqlClient) Begin(ctx context.Context) sqlTx {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	return sqlTxClient{Tx: tx}
+}
+
+type sqlTxClient struct {
+	*sql.Tx
+}
+
+func (tx sqlTxClient) Exec(ctx context.Context, query string, args ...any) error {
+	_, err := tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+func TestHuman_Persistence(t *testing.T) {
+	// Given a name that would break a query built with fmt.Sprintf, and another human that must
+	// be left as is
+	require := require.New(t)
+	ctx := WithActor(context.Background(), "alice")
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "human.db"))
+	require.NoError(err)
+	defer db.Close()
+	runner, err := migrations.NewRunner(db, migrations.SQLite)
+	require.NoError(err)
+	require.NoError(runner.Up(ctx))
+	client := sqlClient{sqlExecClient{db: db}}
+	humans := NewHumanQuerier(client)
+
+	other, err := NewHuman(client, "Jane", 60, 165)
+	require.NoError(err)
+	require.NoError(other.Insert(ctx))
+	h, err := NewHuman(client, "O'Brien", 80, 180)
+	require.NoError(err)
+
+	// When the human is inserted
+	require.NoError(h.Insert(ctx))
+
+	// Then
+	found, err := humans.FindByID(ctx, h.ID.String())
+	require.NoError(err)
+	require.Equal(&HumanValues{Name: "O'Brien", Weight: 80, Height: 180}, found.values())
+
+	// When it's updated
+	found.Weight = 78
+	require.NoError(found.Update(ctx))
+
+	// Then only its row is changed
+	found, err = humans.FindByID(ctx, h.ID.String())
+	require.NoError(err)
+	require.Equal(78, found.Weight)
+	unchanged, err := humans.FindByID(ctx, other.ID.String())
+	require.NoError(err)
+	require.Equal(&HumanValues{Name: "Jane", Weight: 60, Height: 165}, unchanged.values())
+
+	// When it's deleted
+	require.NoError(found.Delete(ctx))
+
+	// Then only its row is deleted
+	_, err = humans.FindByID(ctx, h.ID.String())
+	require.True(errors.Is(err, ErrHumanNotFound), "expected %v to wrap %v", err, ErrHumanNotFound)
+	_, err = humans.FindByID(ctx, other.ID.String())
+	require.NoError(err)
+
+	// Then every change is in the audit trail
+	entries, err := NewAuditQuerier(sqlRowsClient{db: db}).FindByRecordID(ctx, h.ID.String())
+	require.NoError(err)
+	require.Len(entries, 3)
+	require.Equal(AuditOperationInsert, entries[0].Operation)
+	require.Nil(entries[0].OldValues)
+	require.Equal(&HumanValues{Name: "O'Brien", Weight: 80, Height: 180}, entries[0].NewValues)
+	require.Equal(AuditOperationUpdate, entries[1].Operation)
+	require.Equal(&HumanValues{Name: "O'Brien", Weight: 80, Height: 180}, entries[1].OldValues)
+	require.Equal(&HumanValues{Name: "O'Brien", Weight: 78, Height: 180}, entries[1].NewValues)
+	require.Equal(AuditOperationDelete, entries[2].Operation)
+	require.Equal(&HumanValues{Name: "O'Brien", Weight: 78, Height: 180}, entries[2].OldValues)
+	require.Nil(entries[2].NewValues)
+	require.Equal("alice", entries[2].Actor)
+}
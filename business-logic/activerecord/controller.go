@@ -3,9 +3,12 @@ package activerecord
 import (
 	"context"
 	"encoding/json"
-	"io"
 	"net/http"
 	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
 )
 
 type (
@@ -19,7 +22,7 @@ type (
 )
 
 type humanFinder interface {
-	FindByID(ctx context.Context, id string) *Human
+	FindByID(ctx context.Context, id string) (*Human, error)
 }
 
 type Controller struct {
@@ -33,13 +36,19 @@ func NewController(humanFinder humanFinder) *Controller {
 // CalculateBMI calculates BMI for a human given the id in the payload
 func (c *Controller) CalculateBMI(w http.ResponseWriter, r *http.Request) {
 	// Bind request model
-	body, _ := io.ReadAll(r.Body)
-	var reqModel RequestModel
-	json.Unmarshal(body, &reqModel)
+	reqModel, err := bindRequestModel(r)
+	if err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
 
 	// Use humanFinder to reconstitute the human given by the id in the request model
 	ctx := r.Context()
-	human := c.humanFinder.FindByID(ctx, reqModel.ID)
+	human, err := c.humanFinder.FindByID(ctx, reqModel.ID)
+	if err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
 
 	// Calculate the human's BMI = business logic
 	bmi := human.Bmi()
@@ -50,8 +59,86 @@ func (c *Controller) CalculateBMI(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Encode response model as JSON
-	body, _ = json.Marshal(&respModel)
+	body, _ := json.Marshal(&respModel)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+type (
+	AuditEntryModel struct {
+		Operation string       `json:"operation"`
+		Actor     string       `json:"actor"`
+		ChangedAt string       `json:"changedAt"`
+		Old       *HumanValues `json:"old"`
+		New       *HumanValues `json:"new"`
+	}
+
+	AuditResponseModel struct {
+		History []AuditEntryModel `json:"history"`
+	}
+)
+
+type auditFinder interface {
+	FindByRecordID(ctx context.Context, id string) ([]AuditEntry, error)
+}
+
+type AuditController struct {
+	auditFinder auditFinder
+}
+
+func NewAuditController(auditFinder auditFinder) *AuditController {
+	return &AuditController{auditFinder: auditFinder}
+}
+
+// History returns the audit trail of the human given by the id in the payload
+func (c *AuditController) History(w http.ResponseWriter, r *http.Request) {
+	// Bind request model
+	reqModel, err := bindRequestModel(r)
+	if err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	// Use auditFinder to reconstitute the audit entries of the human given by the id in the request model
+	ctx := r.Context()
+	entries, err := c.auditFinder.FindByRecordID(ctx, reqModel.ID)
+	if err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	// Create response model
+	respModel := AuditResponseModel{History: make([]AuditEntryModel, 0, len(entries))}
+	for _, entry := range entries {
+		respModel.History = append(respModel.History, AuditEntryModel{
+			Operation: string(entry.Operation),
+			Actor:     entry.Actor,
+			ChangedAt: entry.ChangedAt.Format(time.RFC3339),
+			Old:       entry.OldValues,
+			New:       entry.NewValues,
+		})
+	}
+
+	// Encode response model as JSON
+	body, _ := json.Marshal(&respModel)
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(body)
 }
+
+// bindRequestModel decodes the request body and checks that it holds a valid id
+func bindRequestModel(r *http.Request) (RequestModel, error) {
+	var reqModel RequestModel
+	if err := json.NewDecoder(r.Body).Decode(&reqModel); err != nil {
+		return reqModel, apperror.Validation("INVALID_REQUEST", "could not decode request body: %v", err)
+	}
+
+	if _, err := uuid.Parse(reqModel.ID); err != nil {
+		return reqModel, apperror.InvalidFields(apperror.InvalidParam{Name: "id", Reason: "must be a UUID"})
+	}
+
+	return reqModel, nil
+}
@@ -0,0 +1,67 @@
+package activerecord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+)
+
+type fakeHumanFinder struct {
+	humans map[string]*Human
+}
+
+func (f *fakeHumanFinder) FindByID(_ context.Context, id string) (*Human, error) {
+	human, ok := f.humans[id]
+	if !ok {
+		return nil, fmt.Errorf("could not find human by id = %s: %w", id, ErrHumanNotFound)
+	}
+
+	return human, nil
+}
+
+func TestController_CalculateBMI(t *testing.T) {
+	jane := &Human{ID: uuid.New(), Name: "Jane", Weight: 70, Height: 170}
+	controller := NewController(&fakeHumanFinder{humans: map[string]*Human{jane.ID.String(): jane}})
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   string
+		wantCode   string
+	}{
+		{name: "should calculate BMI", body: fmt.Sprintf(`{"id": %q}`, jane.ID), wantStatus: http.StatusOK, wantBody: `{"bmi": "24.2"}`},
+		{name: "should not find unknown human", body: fmt.Sprintf(`{"id": %q}`, uuid.New()), wantStatus: http.StatusNotFound, wantCode: "HUMAN_NOT_FOUND"},
+		{name: "should reject invalid id", body: `{"id": "42"}`, wantStatus: http.StatusBadRequest},
+		{name: "should reject malformed body", body: `{"id": `, wantStatus: http.StatusBadRequest, wantCode: "INVALID_REQUEST"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+
+			// When
+			rec := httptest.NewRecorder()
+			controller.CalculateBMI(rec, httptest.NewRequest(http.MethodPost, "/bmi", strings.NewReader(tt.body)))
+
+			// Then
+			require.Equal(tt.wantStatus, rec.Code)
+			if tt.wantBody != "" {
+				require.JSONEq(tt.wantBody, rec.Body.String())
+				return
+			}
+
+			require.Equal(apperror.ProblemContentType, rec.Header().Get("Content-Type"))
+			var problem apperror.Problem
+			require.NoError(json.Unmarshal(rec.Body.Bytes(), &problem))
+			require.Equal(tt.wantCode, problem.Code)
+		})
+	}
+}
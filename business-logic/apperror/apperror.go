@@ -0,0 +1,173 @@
+// Package apperror categorizes the errors of the application so that every transport maps them the
+// same way. Domain packages declare their sentinel errors with New, and controllers turn whatever
+// error they get into a response with WriteProblem or GRPCStatus. Errors that aren't categorized
+// are internal errors whose details are never exposed to clients.
+package apperror
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Category is the kind of failure an error represents. It determines the HTTP status code and
+// gRPC status code the error is mapped to.
+type Category int
+
+const (
+	CategoryInternal           Category = iota // the application failed, e.g. the database is down
+	CategoryValidation                         // the input is malformed or breaks a rule for what it may contain
+	CategoryNotFound                           // the resource doesn't exist
+	CategoryConflict                           // the resource was changed concurrently or is in a conflicting state
+	CategoryPreconditionFailed                 // a business rule rejected the command, e.g. the credit limit was exceeded
+	CategoryUnauthorized                       // the caller isn't authenticated or isn't allowed to do what it tried
+)
+
+var categoryNames = map[Category]string{
+	CategoryInternal:           "internal",
+	CategoryValidation:         "validation",
+	CategoryNotFound:           "not found",
+	CategoryConflict:           "conflict",
+	CategoryPreconditionFailed: "precondition failed",
+	CategoryUnauthorized:       "unauthorized",
+}
+
+func (c Category) String() string {
+	if name, ok := categoryNames[c]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Category(%d)", int(c))
+}
+
+// Error is a categorized error. Errors with the same code are considered equal by errors.Is, so a
+// sentinel error still matches after details have been added to it.
+type Error struct {
+	Category      Category
+	Code          string            // identifies the error for clients, e.g. ORDER_NOT_FOUND. Optional.
+	Details       map[string]string // structured information about the error, e.g. the id of a resource
+	InvalidParams []InvalidParam    // the request fields that failed validation, if any
+
+	message string
+	cause   error
+}
+
+// InvalidParam describes why a request field failed validation
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// New returns an error of the category. It's mostly used to declare sentinel errors.
+func New(category Category, code, message string) *Error {
+	return &Error{Category: category, Code: code, message: message}
+}
+
+// Newf returns an error of the category with a formatted message. An error formatted with the %w
+// verb is wrapped by the returned error.
+func Newf(category Category, code, format string, args ...any) *Error {
+	err := fmt.Errorf(format, args...)
+
+	return &Error{Category: category, Code: code, message: err.Error(), cause: errors.Unwrap(err)}
+}
+
+// Wrap gives err the category and code. The message of err is kept as is.
+func Wrap(err error, category Category, code string) *Error {
+	return &Error{Category: category, Code: code, message: err.Error(), cause: err}
+}
+
+// Validation returns a validation error with a formatted message
+func Validation(code, format string, args ...any) *Error {
+	return Newf(CategoryValidation, code, format, args...)
+}
+
+// InvalidFields returns a validation error for a request whose fields failed validation
+func InvalidFields(params ...InvalidParam) *Error {
+	return &Error{Category: CategoryValidation, message: "request has invalid fields", InvalidParams: params}
+}
+
+// NotFound returns a not found error with a formatted message
+func NotFound(code, format string, args ...any) *Error {
+	return Newf(CategoryNotFound, code, format, args...)
+}
+
+// Conflict returns a conflict error with a formatted message
+func Conflict(code, format string, args ...any) *Error {
+	return Newf(CategoryConflict, code, format, args...)
+}
+
+// PreconditionFailed returns a precondition failed error with a formatted message
+func PreconditionFailed(code, format string, args ...any) *Error {
+	return Newf(CategoryPreconditionFailed, code, format, args...)
+}
+
+// Unauthorized returns an unauthorized error with a formatted message
+func Unauthorized(code, format string, args ...any) *Error {
+	return Newf(CategoryUnauthorized, code, format, args...)
+}
+
+// Internal returns an internal error with a formatted message
+func Internal(code, format string, args ...any) *Error {
+	return Newf(CategoryInternal, code, format, args...)
+}
+
+func (e *Error) Error() string {
+	return e.message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is reports whether target is an Error with the same code. Errors without codes are only equal
+// to themselves.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+
+	return e.Code != "" && e.Code == t.Code
+}
+
+// WithDetail returns a copy of the error with the detail added to it
+func (e *Error) WithDetail(key, value string) *Error {
+	details := make(map[string]string, len(e.Details)+1)
+	for k, v := range e.Details {
+		details[k] = v
+	}
+	details[key] = value
+
+	copied := *e
+	copied.Details = details
+
+	return &copied
+}
+
+// As returns the outermost categorized error in err's chain
+func As(err error) (*Error, bool) {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr, true
+	}
+
+	return nil, false
+}
+
+// CategoryOf returns the category of the outermost categorized error in err's chain. Errors that
+// aren't categorized are internal errors.
+func CategoryOf(err error) Category {
+	if appErr, ok := As(err); ok {
+		return appErr.Category
+	}
+
+	return CategoryInternal
+}
+
+// Ensure gives err the category unless it's already categorized
+func Ensure(err error, category Category) error {
+	if _, ok := As(err); ok {
+		return err
+	}
+
+	return Wrap(err, category, "")
+}
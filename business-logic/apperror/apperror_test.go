@@ -0,0 +1,169 @@
+package apperror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+)
+
+var errNotFound = New(CategoryNotFound, "THING_NOT_FOUND", "thing not found")
+
+func TestError_Wrapping(t *testing.T) {
+	cause := errors.New("connection refused")
+
+	tests := []struct {
+		name         string
+		err          error
+		wantCategory Category
+		wantIs       error
+		wantMessage  string
+	}{
+		{
+			name:         "should survive wrapping with %w",
+			err:          fmt.Errorf("could not load thing = 1: %w", errNotFound),
+			wantCategory: CategoryNotFound,
+			wantIs:       errNotFound,
+			wantMessage:  "could not load thing = 1: thing not found",
+		},
+		{
+			name:         "should match sentinel by code after details are added",
+			err:          fmt.Errorf("could not load thing: %w", errNotFound.WithDetail("id", "1")),
+			wantCategory: CategoryNotFound,
+			wantIs:       errNotFound,
+			wantMessage:  "could not load thing: thing not found",
+		},
+		{
+			name:         "should wrap cause formatted with %w",
+			err:          Conflict("THING_CONFLICT", "could not store thing: %w", cause),
+			wantCategory: CategoryConflict,
+			wantIs:       cause,
+			wantMessage:  "could not store thing: connection refused",
+		},
+		{
+			name:         "should keep category of wrapped error",
+			err:          Wrap(cause, CategoryUnauthorized, "TOKEN_EXPIRED"),
+			wantCategory: CategoryUnauthorized,
+			wantIs:       cause,
+			wantMessage:  "connection refused",
+		},
+		{
+			name:         "should categorize uncategorized errors as internal",
+			err:          fmt.Errorf("could not store thing: %w", cause),
+			wantCategory: CategoryInternal,
+			wantIs:       cause,
+			wantMessage:  "could not store thing: connection refused",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+
+			require.Equal(tt.wantCategory, CategoryOf(tt.err))
+			require.True(errors.Is(tt.err, tt.wantIs), "expected %v to wrap %v", tt.err, tt.wantIs)
+			require.Equal(tt.wantMessage, tt.err.Error())
+		})
+	}
+}
+
+func TestError_Is(t *testing.T) {
+	require := require.New(t)
+
+	require.False(errors.Is(NotFound("OTHER_NOT_FOUND", "other not found"), errNotFound))
+	require.False(errors.Is(Validation("", "invalid"), Validation("", "invalid")))
+	require.Equal(map[string]string{"id": "1"}, errNotFound.WithDetail("id", "1").Details)
+	require.Nil(errNotFound.Details)
+}
+
+func TestEnsure(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(CategoryValidation, CategoryOf(Ensure(errors.New("malformed JSON"), CategoryValidation)))
+	require.Equal(CategoryNotFound, CategoryOf(Ensure(errNotFound, CategoryValidation)))
+}
+
+func TestMapping(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+		wantCode   codes.Code
+		wantDetail string
+	}{
+		{err: Validation("INVALID", "invalid"), wantStatus: http.StatusBadRequest, wantCode: codes.InvalidArgument, wantDetail: "invalid"},
+		{err: errNotFound, wantStatus: http.StatusNotFound, wantCode: codes.NotFound, wantDetail: "thing not found"},
+		{err: Conflict("CONFLICT", "conflict"), wantStatus: http.StatusConflict, wantCode: codes.Aborted, wantDetail: "conflict"},
+		{err: PreconditionFailed("REJECTED", "rejected"), wantStatus: http.StatusUnprocessableEntity, wantCode: codes.FailedPrecondition, wantDetail: "rejected"},
+		{err: Unauthorized("UNAUTHORIZED", "unauthorized"), wantStatus: http.StatusUnauthorized, wantCode: codes.Unauthenticated, wantDetail: "unauthorized"},
+		{err: Internal("INTERNAL", "secret"), wantStatus: http.StatusInternalServerError, wantCode: codes.Internal},
+		{err: errors.New("secret"), wantStatus: http.StatusInternalServerError, wantCode: codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			require := require.New(t)
+
+			// When
+			rec := httptest.NewRecorder()
+			WriteProblem(rec, httptest.NewRequest(http.MethodGet, "/things/1", nil), tt.err)
+			st := GRPCStatus(tt.err)
+
+			// Then
+			require.Equal(tt.wantStatus, rec.Code)
+			require.Equal(ProblemContentType, rec.Header().Get("Content-Type"))
+			var problem Problem
+			require.NoError(json.Unmarshal(rec.Body.Bytes(), &problem))
+			require.Equal(tt.wantStatus, problem.Status)
+			require.Equal(tt.wantDetail, problem.Detail)
+			require.Equal("/things/1", problem.Instance)
+
+			require.Equal(tt.wantCode, st.Code())
+			if tt.wantCode == codes.Internal {
+				require.Equal("internal error", st.Message())
+			}
+		})
+	}
+}
+
+func TestNewProblem_Extensions(t *testing.T) {
+	require := require.New(t)
+	err := fmt.Errorf("could not load thing: %w", errNotFound.WithDetail("id", "1"))
+
+	problem := NewProblem(httptest.NewRequest(http.MethodGet, "/things/1", nil), err)
+
+	require.Equal(Problem{
+		Type:     "about:blank",
+		Title:    "Not Found",
+		Status:   http.StatusNotFound,
+		Detail:   "could not load thing: thing not found",
+		Instance: "/things/1",
+		Code:     "THING_NOT_FOUND",
+		Details:  map[string]string{"id": "1"},
+	}, problem)
+
+	problem = NewProblem(httptest.NewRequest(http.MethodPost, "/things", nil), InvalidFields(InvalidParam{Name: "id", Reason: "must be a UUID"}))
+	require.Equal([]InvalidParam{{Name: "id", Reason: "must be a UUID"}}, problem.InvalidParams)
+}
+
+func TestGRPCStatus_Details(t *testing.T) {
+	require := require.New(t)
+	err := InvalidFields(InvalidParam{Name: "id", Reason: "must be a UUID"})
+
+	st := GRPCStatus(fmt.Errorf("could not load thing: %w", errNotFound.WithDetail("id", "1")))
+	require.Len(st.Details(), 1)
+	info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+	require.True(ok)
+	require.Equal("THING_NOT_FOUND", info.Reason)
+	require.Equal(ErrorDomain, info.Domain)
+	require.Equal(map[string]string{"id": "1"}, info.Metadata)
+
+	st = GRPCStatus(err)
+	require.Len(st.Details(), 1)
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+	require.True(ok)
+	require.Equal("id", badRequest.FieldViolations[0].Field)
+}
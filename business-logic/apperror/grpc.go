@@ -0,0 +1,94 @@
+package apperror
+
+import (
+	"context"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorDomain is the domain of the errdetails.ErrorInfo attached to gRPC statuses
+const ErrorDomain = "business-logic-patterns"
+
+var grpcCodes = map[Category]codes.Code{
+	CategoryInternal:           codes.Internal,
+	CategoryValidation:         codes.InvalidArgument,
+	CategoryNotFound:           codes.NotFound,
+	CategoryConflict:           codes.Aborted,
+	CategoryPreconditionFailed: codes.FailedPrecondition,
+	CategoryUnauthorized:       codes.Unauthenticated,
+}
+
+// GRPCCode returns the gRPC status code of the category
+func GRPCCode(category Category) codes.Code {
+	if code, ok := grpcCodes[category]; ok {
+		return code
+	}
+
+	return codes.Internal
+}
+
+// GRPCStatus maps err to a gRPC status. The code and details of the error are attached as an
+// errdetails.ErrorInfo and its invalid params as an errdetails.BadRequest. The details of internal
+// errors aren't exposed to clients.
+func GRPCStatus(err error) *status.Status {
+	appErr, ok := As(err)
+	if !ok || appErr.Category == CategoryInternal {
+		return status.New(codes.Internal, "internal error")
+	}
+
+	st := status.New(GRPCCode(appErr.Category), err.Error())
+
+	if appErr.Code != "" || len(appErr.Details) > 0 {
+		info := &errdetails.ErrorInfo{Reason: appErr.Code, Domain: ErrorDomain, Metadata: appErr.Details}
+		if withDetails, err := st.WithDetails(info); err == nil {
+			st = withDetails
+		}
+	}
+
+	if len(appErr.InvalidParams) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, param := range appErr.InvalidParams {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       param.Name,
+				Description: param.Reason,
+			})
+		}
+		if withDetails, err := st.WithDetails(badRequest); err == nil {
+			st = withDetails
+		}
+	}
+
+	return st
+}
+
+// toGRPCError maps err to a gRPC status error unless it already is one
+func toGRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	return GRPCStatus(err).Err()
+}
+
+// UnaryServerInterceptor maps the errors returned by unary RPC handlers to gRPC statuses
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+
+		return resp, toGRPCError(err)
+	}
+}
+
+// StreamServerInterceptor maps the errors returned by streaming RPC handlers to gRPC statuses
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return toGRPCError(handler(srv, stream))
+	}
+}
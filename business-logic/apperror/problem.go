@@ -0,0 +1,83 @@
+package apperror
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. Code, Details and InvalidParams are extension
+// members.
+type Problem struct {
+	Type          string            `json:"type"`
+	Title         string            `json:"title"`
+	Status        int               `json:"status"`
+	Detail        string            `json:"detail,omitempty"`
+	Instance      string            `json:"instance,omitempty"`
+	Code          string            `json:"code,omitempty"`
+	Details       map[string]string `json:"details,omitempty"`
+	InvalidParams []InvalidParam    `json:"invalid-params,omitempty"`
+}
+
+var httpStatuses = map[Category]int{
+	CategoryInternal:           http.StatusInternalServerError,
+	CategoryValidation:         http.StatusBadRequest,
+	CategoryNotFound:           http.StatusNotFound,
+	CategoryConflict:           http.StatusConflict,
+	CategoryPreconditionFailed: http.StatusUnprocessableEntity,
+	CategoryUnauthorized:       http.StatusUnauthorized,
+}
+
+// HTTPStatus returns the HTTP status code of the category
+func HTTPStatus(category Category) int {
+	if status, ok := httpStatuses[category]; ok {
+		return status
+	}
+
+	return http.StatusInternalServerError
+}
+
+// NewProblem maps err to problem details. The details of internal errors aren't exposed to
+// clients.
+func NewProblem(r *http.Request, err error) Problem {
+	appErr, ok := As(err)
+	if !ok || appErr.Category == CategoryInternal {
+		return NewStatusProblem(r, http.StatusInternalServerError, "")
+	}
+
+	problem := NewStatusProblem(r, HTTPStatus(appErr.Category), err.Error())
+	problem.Code = appErr.Code
+	problem.Details = appErr.Details
+	problem.InvalidParams = appErr.InvalidParams
+
+	return problem
+}
+
+// NewStatusProblem returns problem details of a status that isn't caused by an application error,
+// e.g. 405 Method Not Allowed
+func NewStatusProblem(r *http.Request, status int, detail string) Problem {
+	return Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	}
+}
+
+// WriteProblem responds with the problem details of err
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	problem := NewProblem(r, err)
+	problem.Write(w)
+}
+
+// Write responds with the problem details
+func (p Problem) Write(w http.ResponseWriter) {
+	body, _ := json.Marshal(&p)
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	w.Write(body)
+}
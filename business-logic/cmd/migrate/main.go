@@ -0,0 +1,100 @@
+// Command migrate applies the database schema used by the business logic pattern examples.
+//
+//	migrate -driver sqlite -dsn examples.db up
+//	migrate -driver postgres -dsn postgres://localhost/examples status
+//	migrate -driver postgres -dsn postgres://localhost/examples to 2
+//
+// force-unlock releases the migration lock left behind by a migrate command that was stopped
+// before it could release it.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/migrations"
+	_ "modernc.org/sqlite"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	driver := flag.String("driver", "sqlite", "database driver, sqlite or postgres")
+	dsn := flag.String("dsn", "", "data source name")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: migrate -driver sqlite|postgres -dsn DSN up|down|status|to VERSION|force-unlock")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *dsn == "" || flag.NArg() == 0 {
+		flag.Usage()
+		return fmt.Errorf("missing dsn or command")
+	}
+
+	dialect, err := migrations.DialectByName(*driver)
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open(*driver, *dsn)
+	if err != nil {
+		return fmt.Errorf("could not open database: %w", err)
+	}
+	defer db.Close()
+
+	runner, err := migrations.NewRunner(db, dialect)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch cmd := flag.Arg(0); cmd {
+	case "up":
+		return runner.Up(ctx)
+	case "down":
+		return runner.Down(ctx)
+	case "to":
+		version, err := strconv.Atoi(flag.Arg(1))
+		if err != nil {
+			return fmt.Errorf("invalid version = %q", flag.Arg(1))
+		}
+		return runner.To(ctx, version)
+	case "force-unlock":
+		return runner.ForceUnlock(ctx)
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return printStatus(statuses)
+	default:
+		return fmt.Errorf("unknown command = %s", cmd)
+	}
+}
+
+func printStatus(statuses []migrations.Status) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.Applied {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+	}
+
+	return w.Flush()
+}
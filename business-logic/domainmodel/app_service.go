@@ -2,10 +2,16 @@ package domainmodel
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/customer"
 	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/promotion"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/tax"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/sqltx"
 )
 
 type (
@@ -14,32 +20,354 @@ type (
 	}
 
 	txMaker interface {
-		BeginTransaction(ctx context.Context) order.Tx
+		BeginTransaction(ctx context.Context) (order.Tx, error)
+	}
+
+	// afterCommitter is implemented by transactions that join a transaction committed by someone
+	// else, e.g. the shared transactions of the sqltx package. Committing them doesn't commit
+	// anything yet.
+	afterCommitter interface {
+		AfterCommit(fn func())
 	}
 )
 
 type Service struct {
-	eventPublisher eventPublisher
-	txMaker        txMaker
+	eventPublisher     eventPublisher
+	txMaker            txMaker
+	newRepository      func(tx order.Tx) order.Repository
+	newPromotionEngine func(tx order.Tx) *promotion.Engine
+	newCustomerService func(tx order.Tx) *customer.Service
+	taxService         *tax.Service
+	conflictRetries    int
+	onPublishError     func(ctx context.Context, e order.Event, err error)
+}
+
+type ServiceOption func(s *Service)
+
+// WithConflictRetries makes the service retry commands up to the given number of times when the
+// order was changed concurrently. Every retry reloads the order in a new transaction, so the
+// command is checked against the order's latest state. Commands run in a shared transaction, see
+// sqltx.Begin, aren't retried.
+func WithConflictRetries(retries int) ServiceOption {
+	return func(s *Service) {
+		s.conflictRetries = retries
+	}
+}
+
+// WithPromotions makes the service apply the given promotions to orders. Their usages are tracked
+// in the database, in the same transactions as the orders.
+func WithPromotions(promotions ...promotion.Promotion) ServiceOption {
+	return func(s *Service) {
+		s.newPromotionEngine = func(tx order.Tx) *promotion.Engine {
+			return promotion.NewEngine(promotion.NewSQLUsageStore(tx), promotions...)
+		}
+	}
 }
 
-func NewService(txMaker txMaker, eventPublisher eventPublisher) *Service {
-	return &Service{
+// WithCustomerCredit makes the service refuse to submit orders of blocked customers and orders
+// whose totals exceed the remaining credit of their customers. The credit is reserved in the same
+// transactions as the orders are submitted in and given back when they're paid or cancelled.
+func WithCustomerCredit() ServiceOption {
+	return func(s *Service) {
+		s.newCustomerService = func(tx order.Tx) *customer.Service {
+			return customer.NewService(customer.NewRepository(tx))
+		}
+	}
+}
+
+// WithTaxes makes the service calculate the tax of orders with the given tax service
+func WithTaxes(taxService *tax.Service) ServiceOption {
+	return func(s *Service) {
+		s.taxService = taxService
+	}
+}
+
+// WithPublishErrorHandler makes the service tell onError about the domain events it fails to
+// publish instead of logging them. Events are published on a best-effort basis after the orders
+// are committed, so the failures don't undo the commands.
+func WithPublishErrorHandler(onError func(ctx context.Context, e order.Event, err error)) ServiceOption {
+	return func(s *Service) {
+		s.onPublishError = onError
+	}
+}
+
+// WithEventSourcing makes the service store orders as streams of domain events instead of storing
+// their current state. A snapshot of an order is taken every snapshotEvery events unless it's 0.
+func WithEventSourcing(snapshotEvery int) ServiceOption {
+	return func(s *Service) {
+		s.newRepository = func(tx order.Tx) order.Repository {
+			var opts []order.EventSourcedRepositoryOption
+			if snapshotEvery > 0 {
+				opts = append(opts, order.WithSnapshots(order.NewSQLSnapshotStore(tx), snapshotEvery))
+			}
+
+			return order.NewEventSourcedRepository(order.NewSQLEventStore(tx), opts...)
+		}
+	}
+}
+
+func NewService(txMaker txMaker, eventPublisher eventPublisher, opts ...ServiceOption) *Service {
+	s := &Service{
 		eventPublisher: eventPublisher,
 		txMaker:        txMaker,
+		newRepository:  order.NewRepository,
+		onPublishError: logPublishError,
+	}
+
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	return s
+}
+
+// CreateOrder creates a pending Order of the items for the customer and returns its id
+func (s *Service) CreateOrder(ctx context.Context, customerID string, items []order.OrderItem) (order.ID, error) {
+	custID, err := order.NewCustomerID(customerID)
+	if err != nil {
+		return "", err
+	}
+
+	o, err := order.NewOrder(custID, items, order.OrderStatePending, order.WithMetadata(order.MetadataFromContext(ctx)))
+	if err != nil {
+		return "", fmt.Errorf("could not create order: %w", err)
+	}
+
+	// begin database transaction and instantiate a new order repository
+	tx, err := s.txMaker.BeginTransaction(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer func() {
+		tx.Rollback()
+	}()
+
+	if err = s.save(ctx, tx, s.newRepository(tx), o); err != nil {
+		return "", err
+	}
+
+	return o.ID(), nil
+}
+
+// GetOrder returns the Order with the given id
+func (s *Service) GetOrder(ctx context.Context, id string) (*order.Order, error) {
+	tx, err := s.txMaker.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer func() {
+		tx.Rollback()
+	}()
+
+	o, err := s.newRepository(tx).FindByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("could not find order by id = %s: %w", id, err)
+	}
+
+	return o, nil
+}
+
+// ReadEvents returns at most limit events of all orders after the given position, in the order
+// they were stored
+func (s *Service) ReadEvents(ctx context.Context, afterPosition int64, limit int) ([]order.RecordedEvent, error) {
+	tx, err := s.txMaker.BeginTransaction(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer func() {
+		tx.Rollback()
+	}()
+
+	events, err := order.NewSQLEventStore(tx).ReadAll(ctx, afterPosition, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not read events after position = %d: %w", afterPosition, err)
+	}
+
+	return events, nil
 }
 
 // SubmitOrder coordinates the submission of an Order. This example is a simplified version since
-// it takes a shortcut. It's missing the Outbox pattern for making sure domain events get
-// delivered at least once.
+// it takes a shortcut. Its domain events are published after the transaction is committed, so they
+// are lost if the process stops in between. It's missing the Outbox pattern for making sure domain
+// events get delivered at least once.
 func (s *Service) SubmitOrder(ctx context.Context, id string) error {
+	return s.execute(ctx, id, func(ctx context.Context, tx order.Tx, o *order.Order) error {
+		// perform business logic
+		if err := o.Submit(); err != nil {
+			return fmt.Errorf("could not submit order by id = %s: %w", id, err)
+		}
+
+		// the customer must have credit left for the order
+		if s.newCustomerService != nil {
+			if err := s.newCustomerService(tx).ReserveCredit(ctx, o); err != nil {
+				return fmt.Errorf("could not reserve credit for order by id = %s: %w", id, err)
+			}
+		}
+
+		// the promotions applied to the order are used up when it's submitted
+		if s.newPromotionEngine != nil {
+			if err := s.newPromotionEngine(tx).Redeem(ctx, o); err != nil {
+				return fmt.Errorf("could not redeem promotions of order by id = %s: %w", id, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// CancelOrder cancels a pending or submitted Order. The credit reserved for a submitted order is
+// given back to its customer and the usages of the promotions redeemed for it are released.
+func (s *Service) CancelOrder(ctx context.Context, id, reason string) error {
+	return s.execute(ctx, id, func(ctx context.Context, tx order.Tx, o *order.Order) error {
+		return s.cancel(ctx, tx, o, reason)
+	})
+}
+
+// PayOrder marks a submitted Order as paid. The credit reserved for it is given back to its
+// customer, who no longer owes the total.
+func (s *Service) PayOrder(ctx context.Context, id string) error {
+	return s.execute(ctx, id, func(ctx context.Context, tx order.Tx, o *order.Order) error {
+		if err := o.Pay(); err != nil {
+			return fmt.Errorf("could not pay order by id = %s: %w", id, err)
+		}
+
+		if s.newCustomerService != nil {
+			if err := s.newCustomerService(tx).ReleaseCredit(ctx, o); err != nil {
+				return fmt.Errorf("could not release credit of order by id = %s: %w", id, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// RejectOrder cancels a submitted Order that can't be fulfilled, e.g. because there isn't enough
+// stock for it. Orders that are no longer submitted, e.g. because they were cancelled by the
+// customer in the meantime, are left as is.
+func (s *Service) RejectOrder(ctx context.Context, id, reason string) error {
+	return s.execute(ctx, id, func(ctx context.Context, tx order.Tx, o *order.Order) error {
+		if o.State() != order.OrderStateSubmitted {
+			return nil
+		}
+
+		return s.cancel(ctx, tx, o, reason)
+	})
+}
+
+// cancel cancels the order and gives back what was reserved for it when it was submitted
+func (s *Service) cancel(ctx context.Context, tx order.Tx, o *order.Order, reason string) error {
+	submitted := o.State() == order.OrderStateSubmitted
+	if err := o.Cancel(reason); err != nil {
+		return fmt.Errorf("could not cancel order by id = %s: %w", o.ID(), err)
+	}
+
+	if submitted && s.newCustomerService != nil {
+		if err := s.newCustomerService(tx).ReleaseCredit(ctx, o); err != nil {
+			return fmt.Errorf("could not release credit of order by id = %s: %w", o.ID(), err)
+		}
+	}
+
+	if submitted && s.newPromotionEngine != nil {
+		if err := s.newPromotionEngine(tx).Release(ctx, o); err != nil {
+			return fmt.Errorf("could not release promotions of order by id = %s: %w", o.ID(), err)
+		}
+	}
+
+	return nil
+}
+
+// ApplyPromotions replaces the discounts of a pending Order with the discounts of the promotions
+// that apply to it, given the coupon codes the customer presented
+func (s *Service) ApplyPromotions(ctx context.Context, id string, couponCodes ...string) error {
+	if s.newPromotionEngine == nil {
+		return fmt.Errorf("could not apply promotions to order by id = %s: no promotions configured", id)
+	}
+
+	return s.execute(ctx, id, func(ctx context.Context, tx order.Tx, o *order.Order) error {
+		if err := s.newPromotionEngine(tx).Apply(ctx, o, couponCodes...); err != nil {
+			return fmt.Errorf("could not apply promotions to order by id = %s: %w", id, err)
+		}
+
+		return nil
+	})
+}
+
+// CalculateTax records the tax of a pending Order when it's shipped to the address. The tax must
+// be calculated again whenever the discounts or shipping fee of the order change.
+func (s *Service) CalculateTax(ctx context.Context, id string, address tax.Address) error {
+	if s.taxService == nil {
+		return fmt.Errorf("could not calculate tax of order by id = %s: no tax service configured", id)
+	}
+
+	return s.execute(ctx, id, func(ctx context.Context, tx order.Tx, o *order.Order) error {
+		if err := s.taxService.Apply(o, address); err != nil {
+			return fmt.Errorf("could not calculate tax of order by id = %s: %w", id, err)
+		}
+
+		return nil
+	})
+}
+
+// ExpireOrder cancels an Order that has been pending for too long. Orders that are no longer
+// pending, e.g. because they were submitted after they were found to be stale, are left as is.
+func (s *Service) ExpireOrder(ctx context.Context, id string) error {
+	return s.execute(ctx, id, func(_ context.Context, _ order.Tx, o *order.Order) error {
+		if o.State() != order.OrderStatePending {
+			return nil
+		}
+
+		return o.Cancel(order.CancelReasonOrderExpired)
+	})
+}
+
+// AddItem adds the article to a pending Order, or adds to its quantity if the order already
+// contains it. The discounts and tax of the order must be applied again afterwards.
+func (s *Service) AddItem(ctx context.Context, id, articleNo string, quantity int, unitPrice order.Money) error {
+	item, err := order.NewOrderItem(articleNo, quantity, unitPrice)
+	if err != nil {
+		return err
+	}
+
+	return s.execute(ctx, id, func(_ context.Context, _ order.Tx, o *order.Order) error {
+		return o.AddItem(item)
+	})
+}
+
+// RemoveItem removes the article from a pending Order. The discounts and tax of the order must be
+// applied again afterwards.
+func (s *Service) RemoveItem(ctx context.Context, id, articleNo string) error {
+	return s.execute(ctx, id, func(_ context.Context, _ order.Tx, o *order.Order) error {
+		return o.RemoveItem(articleNo)
+	})
+}
+
+// ChangeItemQuantity changes the quantity of the article in a pending Order. The discounts and tax
+// of the order must be applied again afterwards.
+func (s *Service) ChangeItemQuantity(ctx context.Context, id, articleNo string, quantity int) error {
+	return s.execute(ctx, id, func(_ context.Context, _ order.Tx, o *order.Order) error {
+		return o.ChangeQuantity(articleNo, quantity)
+	})
+}
+
+// execute runs an aggregate command against the Order with the given id in a transaction. It's
+// retried if the order was changed concurrently.
+func (s *Service) execute(ctx context.Context, id string, command func(ctx context.Context, tx order.Tx, o *order.Order) error) error {
+	return s.retryOnConflict(ctx, func() error {
+		return s.executeOnce(ctx, id, command)
+	})
+}
+
+func (s *Service) executeOnce(ctx context.Context, id string, command func(ctx context.Context, tx order.Tx, o *order.Order) error) error {
 	// begin database transaction and instantiate a new order repository
-	tx := s.txMaker.BeginTransaction(ctx)
+	tx, err := s.txMaker.BeginTransaction(ctx)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
 	defer func() {
 		tx.Rollback()
 	}()
-	orderRepo := order.NewRepository(tx)
+	orderRepo := s.newRepository(tx)
+	metadata := order.MetadataFromContext(ctx)
 
 	// use repository to reconstitute an existing Order
 	repoFindByIDCtx, cancelRepoFindByID := context.WithTimeout(ctx, 5*time.Second)
@@ -50,27 +378,72 @@ func (s *Service) SubmitOrder(ctx context.Context, id string) error {
 	}
 
 	// perform business logic
-	if err = order.Submit(); err != nil {
-		return fmt.Errorf("could not submit order by id = %s: %w", id, err)
+	order.Trace(metadata)
+	if err = command(ctx, tx, order); err != nil {
+		return err
 	}
 
+	return s.save(ctx, tx, orderRepo, order)
+}
+
+// save stores the Order and commits the transaction, then publishes the domain events it raised.
+// Events are only published once the transaction is committed, so consumers never see events of
+// changes that were rolled back. If the transaction joined a transaction committed by someone else,
+// they're published once that transaction is committed.
+func (s *Service) save(ctx context.Context, tx order.Tx, orderRepo order.Repository, order *order.Order) error {
 	// use repository to store the aggregate
 	repoUpsertCtx, cancelRepoUpsert := context.WithTimeout(ctx, 5*time.Second)
 	defer cancelRepoUpsert()
-	if err = orderRepo.Upsert(repoUpsertCtx, order); err != nil {
-		return fmt.Errorf("could not upsert order after submission: %w", err)
+	if err := orderRepo.Upsert(repoUpsertCtx, order); err != nil {
+		return fmt.Errorf("could not upsert order: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
 	}
 
-	// publish domain events to communicate the change(s) to other aggregates no matter if they belong
-	// to the same monolith or some other application. This change in the system will be eventually consistent.
+	events := order.Events()
+	if joined, ok := tx.(afterCommitter); ok {
+		joined.AfterCommit(func() { s.publish(ctx, events) })
+		return nil
+	}
+	s.publish(ctx, events)
+
+	return nil
+}
+
+// publish publishes domain events to communicate the change(s) to other aggregates no matter if
+// they belong to the same monolith or some other application. This change in the system will be
+// eventually consistent.
+func (s *Service) publish(ctx context.Context, events []order.Event) {
 	eventsCtx, cancelEvents := context.WithTimeout(ctx, 10*time.Second)
 	defer cancelEvents()
-	for _, msg := range order.Events() {
-		// publish event
-		s.eventPublisher.Publish(eventsCtx, msg)
+	for _, msg := range events {
+		// publish event, a failure doesn't stop the others from being published
+		if err := s.eventPublisher.Publish(eventsCtx, msg); err != nil {
+			s.onPublishError(ctx, msg, err)
+		}
 	}
+}
 
-	tx.Commit()
+// logPublishError is the default handler of the domain events the service fails to publish. There's
+// no caller to return the failures to, since they may happen after the command has returned.
+func logPublishError(_ context.Context, e order.Event, err error) {
+	log.Printf("could not publish event = %s (%s) of order = %s: %v", e.EventID(), e.EventType(), e.AggregateID(), err)
+}
 
-	return nil
+// retryOnConflict runs the command again if it fails because the order was changed concurrently.
+// Commands run in a shared transaction aren't run again, since the failed attempt made the shared
+// transaction rollback-only and whoever started it must start over.
+func (s *Service) retryOnConflict(ctx context.Context, command func() error) error {
+	err := command()
+	if sqltx.InShared(ctx) {
+		return err
+	}
+
+	for retry := 0; retry < s.conflictRetries && errors.Is(err, order.ErrConcurrencyConflict); retry++ {
+		err = command()
+	}
+
+	return err
 }
@@ -0,0 +1,804 @@
+package domainmodel
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/customer"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+	ordermock "github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order/mock"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/promotion"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/tax"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/migrations"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/sqltx"
+	_ "modernc.org/sqlite"
+)
+
+type eventPublisherMock struct {
+	PublishFn func(ctx context.Context, msg order.Event) error
+
+	published []order.Event
+}
+
+func (m *eventPublisherMock) Publish(ctx context.Context, msg order.Event) error {
+	if m.PublishFn != nil {
+		if err := m.PublishFn(ctx, msg); err != nil {
+			return err
+		}
+	}
+	m.published = append(m.published, msg)
+
+	return nil
+}
+
+// newTestDB returns a migrated sqlite database
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "domainmodel.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	runner, err := migrations.NewRunner(db, migrations.SQLite)
+	require.NoError(t, err)
+	require.NoError(t, runner.Up(context.Background()))
+
+	return db
+}
+
+func newPendingOrder(t *testing.T) *order.Order {
+	t.Helper()
+	customerID, _ := order.NewCustomerID("0f8fad5b-d9cb-469f-a165-70867728950e")
+	unitPrice, _ := order.NewMoney(1000, "EUR")
+	item, _ := order.NewOrderItem("A1", 1, unitPrice)
+	o, err := order.NewOrder(customerID, []order.OrderItem{item}, order.OrderStatePending)
+	require.NoError(t, err)
+
+	return o
+}
+
+func TestService_SubmitOrder(t *testing.T) {
+	errAny := fmt.Errorf("any-error")
+
+	pending := newPendingOrder(t)
+	cancelled := newPendingOrder(t)
+	require.NoError(t, cancelled.Cancel("changed my mind"))
+
+	type fields struct {
+		txMaker        *ordermock.TxMaker
+		eventPublisher *eventPublisherMock
+		upsertFn       func(ctx context.Context, o *order.Order) error
+	}
+	tests := []struct {
+		name            string
+		fields          fields
+		id              order.ID
+		wantErr         error
+		wantCommitted   bool
+		wantPublished   []string
+		wantPublishErrs int
+		wantState       order.OrderState
+	}{
+		{
+			name:          "should submit, publish and commit for happy path",
+			fields:        fields{txMaker: &ordermock.TxMaker{}, eventPublisher: &eventPublisherMock{}},
+			id:            pending.ID(),
+			wantCommitted: true,
+			wantPublished: []string{order.EventTypeOrderSubmitted},
+			wantState:     order.OrderStateSubmitted,
+		},
+		{
+			name:      "should return error when transaction can't begin",
+			fields:    fields{txMaker: &ordermock.TxMaker{BeginErr: errAny}, eventPublisher: &eventPublisherMock{}},
+			id:        pending.ID(),
+			wantErr:   errAny,
+			wantState: order.OrderStatePending,
+		},
+		{
+			name:      "should return error and roll back when order isn't found",
+			fields:    fields{txMaker: &ordermock.TxMaker{}, eventPublisher: &eventPublisherMock{}},
+			id:        "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+			wantErr:   order.ErrNotFound,
+			wantState: order.OrderStatePending,
+		},
+		{
+			name:      "should return error and roll back when order is in an illegal state",
+			fields:    fields{txMaker: &ordermock.TxMaker{}, eventPublisher: &eventPublisherMock{}},
+			id:        cancelled.ID(),
+			wantErr:   order.ErrIllegalTransition,
+			wantState: order.OrderStatePending,
+		},
+		{
+			name: "should return error, roll back and not publish when upsert fails",
+			fields: fields{
+				txMaker:        &ordermock.TxMaker{},
+				eventPublisher: &eventPublisherMock{},
+				upsertFn:       func(ctx context.Context, o *order.Order) error { return errAny },
+			},
+			id:        pending.ID(),
+			wantErr:   errAny,
+			wantState: order.OrderStatePending,
+		},
+		{
+			name: "should report publishing failures and still commit since events are published on a best-effort basis",
+			fields: fields{
+				txMaker:        &ordermock.TxMaker{},
+				eventPublisher: &eventPublisherMock{PublishFn: func(ctx context.Context, msg order.Event) error { return errAny }},
+			},
+			id:              pending.ID(),
+			wantCommitted:   true,
+			wantPublishErrs: 1,
+			wantState:       order.OrderStateSubmitted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			repo := ordermock.NewRepository(pending, cancelled)
+			repo.UpsertFn = tt.fields.upsertFn
+			var publishErrs []error
+			svc := &Service{
+				eventPublisher: tt.fields.eventPublisher,
+				txMaker:        tt.fields.txMaker,
+				newRepository:  func(tx order.Tx) order.Repository { return repo },
+				onPublishError: func(ctx context.Context, e order.Event, err error) {
+					require.Equal(order.EventTypeOrderSubmitted, e.EventType())
+					publishErrs = append(publishErrs, err)
+				},
+			}
+
+			// When
+			err := svc.SubmitOrder(context.Background(), string(tt.id))
+
+			// Then
+			if tt.wantErr != nil {
+				require.True(errors.Is(err, tt.wantErr), "expected %v to wrap %v", err, tt.wantErr)
+			} else {
+				require.NoError(err)
+			}
+
+			for _, tx := range tt.fields.txMaker.Txs {
+				require.Equal(tt.wantCommitted, tx.Committed)
+				require.Equal(!tt.wantCommitted, tx.RolledBack)
+			}
+
+			var published []string
+			for _, e := range tt.fields.eventPublisher.published {
+				require.Equal(pending.ID(), e.AggregateID())
+				published = append(published, e.EventType())
+			}
+			require.Equal(tt.wantPublished, published)
+			require.Len(publishErrs, tt.wantPublishErrs)
+
+			stored, err := repo.FindByID(context.Background(), string(pending.ID()))
+			require.NoError(err)
+			require.Equal(tt.wantState, stored.State())
+		})
+	}
+}
+
+func TestService_SubmitOrder_RetriesOnConflict(t *testing.T) {
+	// Given
+	require := require.New(t)
+	pending := newPendingOrder(t)
+	repo := ordermock.NewRepository(pending)
+	conflicts := 1
+	repo.UpsertFn = func(ctx context.Context, o *order.Order) error {
+		if conflicts > 0 {
+			conflicts--
+			return order.ErrConcurrencyConflict
+		}
+		repo.UpsertFn = nil
+		return repo.Upsert(ctx, o)
+	}
+	txMaker := &ordermock.TxMaker{}
+	svc := NewService(txMaker, &eventPublisherMock{}, WithConflictRetries(1))
+	svc.newRepository = func(tx order.Tx) order.Repository { return repo }
+
+	// When
+	err := svc.SubmitOrder(context.Background(), string(pending.ID()))
+
+	// Then
+	require.NoError(err)
+	require.Len(txMaker.Txs, 2)
+	require.True(txMaker.Txs[0].RolledBack)
+	require.True(txMaker.Txs[1].Committed)
+}
+
+func TestService_ApplyPromotions(t *testing.T) {
+	// Given
+	require := require.New(t)
+	ctx := context.Background()
+	pending := newPendingOrder(t)
+	repo := ordermock.NewRepository(pending)
+	publisher := &eventPublisherMock{}
+	usages := promotion.NewMemoryUsageStore()
+	discount, _ := order.NewMoney(200, "EUR")
+	engine := promotion.NewEngine(usages, promotion.Promotion{
+		ID:         "welcome",
+		Rule:       promotion.FixedAmountOff{Amount: discount},
+		CouponCode: "WELCOME",
+	})
+	svc := NewService(&ordermock.TxMaker{}, publisher)
+	svc.newRepository = func(tx order.Tx) order.Repository { return repo }
+	svc.newPromotionEngine = func(tx order.Tx) *promotion.Engine { return engine }
+
+	// When an unknown coupon is presented
+	err := svc.ApplyPromotions(ctx, string(pending.ID()), "UNKNOWN")
+
+	// Then
+	require.True(errors.Is(err, promotion.ErrInvalidCoupon))
+	require.Empty(publisher.published)
+
+	// When a known coupon is presented and the order is submitted
+	require.NoError(svc.ApplyPromotions(ctx, string(pending.ID()), "WELCOME"))
+	require.NoError(svc.SubmitOrder(ctx, string(pending.ID())))
+
+	// Then the discounts are applied and the promotion is redeemed
+	require.Len(publisher.published, 2)
+	require.Equal(order.EventTypeOrderDiscountsApplied, publisher.published[0].EventType())
+	require.Equal(order.EventTypeOrderSubmitted, publisher.published[1].EventType())
+	submitted := publisher.published[1].Payload().(order.OrderSubmitted)
+	require.Equal("8.00 EUR", submitted.Total.String())
+	require.Len(submitted.Discounts, 1)
+	uses, _, err := usages.Count(ctx, "welcome", pending.CustomerID())
+	require.NoError(err)
+	require.Equal(1, uses)
+
+	// When the order is cancelled
+	require.NoError(svc.CancelOrder(ctx, string(pending.ID()), "changed my mind"))
+
+	// Then the usage of the promotion is released
+	uses, _, err = usages.Count(ctx, "welcome", pending.CustomerID())
+	require.NoError(err)
+	require.Equal(0, uses)
+}
+
+func TestService_CalculateTax(t *testing.T) {
+	// Given
+	require := require.New(t)
+	ctx := context.Background()
+	pending := newPendingOrder(t)
+	repo := ordermock.NewRepository(pending)
+	publisher := &eventPublisherMock{}
+	rates, err := tax.DefaultRateTable()
+	require.NoError(err)
+	svc := NewService(&ordermock.TxMaker{}, publisher, WithTaxes(tax.NewService(rates, nil)))
+	svc.newRepository = func(tx order.Tx) order.Repository { return repo }
+
+	// When
+	err = svc.CalculateTax(ctx, string(pending.ID()), tax.Address{Country: "DE"})
+
+	// Then
+	require.NoError(err)
+	require.Len(publisher.published, 1)
+	taxApplied := publisher.published[0].Payload().(order.OrderTaxApplied)
+	require.Equal("DE", taxApplied.Tax.Jurisdiction)
+	require.Equal("1.60 EUR", taxApplied.Tax.Total.String())
+	require.Equal("10.00 EUR", taxApplied.Total.String())
+
+	stored, err := repo.FindByID(ctx, string(pending.ID()))
+	require.NoError(err)
+	_, ok := stored.Tax()
+	require.True(ok)
+
+	// When the jurisdiction is unknown
+	err = svc.CalculateTax(ctx, string(pending.ID()), tax.Address{Country: "XX"})
+
+	// Then
+	require.True(errors.Is(err, tax.ErrUnknownJurisdiction))
+}
+
+func TestService_ItemCommands(t *testing.T) {
+	// Given
+	require := require.New(t)
+	ctx := context.Background()
+	pending := newPendingOrder(t)
+	id := string(pending.ID())
+	repo := ordermock.NewRepository(pending)
+	publisher := &eventPublisherMock{}
+	svc := NewService(&ordermock.TxMaker{}, publisher)
+	svc.newRepository = func(tx order.Tx) order.Repository { return repo }
+	unitPrice, err := order.NewMoney(250, "EUR")
+	require.NoError(err)
+
+	// When
+	require.NoError(svc.AddItem(ctx, id, "B2", 2, unitPrice))
+	require.NoError(svc.ChangeItemQuantity(ctx, id, "B2", 3))
+	require.NoError(svc.RemoveItem(ctx, id, "A1"))
+
+	// Then
+	require.Len(publisher.published, 3)
+	stored, err := repo.FindByID(ctx, id)
+	require.NoError(err)
+	require.Equal([]order.OrderItem{{ArticleNo: "B2", Quantity: 3, UnitPrice: unitPrice}}, stored.OrderItems())
+	total, err := stored.Total()
+	require.NoError(err)
+	require.Equal("7.50 EUR", total.String())
+
+	// When the article isn't in the order
+	err = svc.RemoveItem(ctx, id, "A1")
+
+	// Then
+	require.True(errors.Is(err, order.ErrItemNotFound), "expected %v to wrap %v", err, order.ErrItemNotFound)
+
+	// When the item is invalid
+	err = svc.AddItem(ctx, id, "C3", 0, unitPrice)
+
+	// Then
+	require.Error(err)
+	require.Len(publisher.published, 3)
+}
+
+func TestService_SubmitOrder_CustomerCredit(t *testing.T) {
+	eur := func(amount int64) order.Money {
+		m, _ := order.NewMoney(amount, "EUR")
+		return m
+	}
+
+	tests := []struct {
+		name        string
+		blocked     bool
+		creditLimit order.Money
+		creditUsed  order.Money
+		wantUsed    order.Money
+		wantErr     bool
+		wantErrIs   error
+	}{
+		{
+			name:        "should submit order within remaining credit",
+			creditLimit: eur(5000),
+			creditUsed:  eur(4000),
+			wantUsed:    eur(5000),
+		},
+		{
+			name:        "should not submit order exceeding remaining credit",
+			creditLimit: eur(5000),
+			creditUsed:  eur(4001),
+			wantUsed:    eur(4001),
+			wantErr:     true,
+			wantErrIs:   customer.ErrCreditLimitExceeded,
+		},
+		{
+			name:        "should not submit order of blocked customer",
+			blocked:     true,
+			creditLimit: eur(5000),
+			creditUsed:  eur(0),
+			wantUsed:    eur(0),
+			wantErr:     true,
+			wantErrIs:   customer.ErrBlocked,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			ctx := context.Background()
+			pending := newPendingOrder(t)
+			repo := ordermock.NewRepository(pending)
+			c, _ := customer.ReconstituteCustomer(pending.CustomerID(), "Jane Doe", customer.StatusActive, tt.blocked,
+				tt.creditLimit, tt.creditUsed, nil, 1)
+			customers := customer.NewMemoryRepository(c)
+			publisher := &eventPublisherMock{}
+			svc := NewService(&ordermock.TxMaker{}, publisher)
+			svc.newRepository = func(tx order.Tx) order.Repository { return repo }
+			svc.newCustomerService = func(tx order.Tx) *customer.Service { return customer.NewService(customers) }
+
+			// When
+			err := svc.SubmitOrder(ctx, string(pending.ID()))
+
+			// Then
+			stored, findErr := customers.FindByID(ctx, pending.CustomerID())
+			require.NoError(findErr)
+			require.Equal(tt.wantUsed, stored.CreditUsed())
+
+			if tt.wantErr {
+				require.Error(err)
+				require.True(errors.Is(err, tt.wantErrIs), "expected %v to wrap %v", err, tt.wantErrIs)
+				require.Empty(publisher.published)
+				return
+			}
+			require.NoError(err)
+			require.Len(publisher.published, 1)
+		})
+	}
+}
+
+func TestService_PayOrder(t *testing.T) {
+	eur := func(amount int64) order.Money {
+		m, _ := order.NewMoney(amount, "EUR")
+		return m
+	}
+
+	tests := []struct {
+		name      string
+		submit    bool
+		wantState order.OrderState
+		wantUsed  order.Money
+		wantErrIs error
+	}{
+		{
+			name:      "should pay submitted order and release its credit",
+			submit:    true,
+			wantState: order.OrderStatePaid,
+			wantUsed:  eur(0),
+		},
+		{
+			name:      "should not pay pending order",
+			wantState: order.OrderStatePending,
+			wantUsed:  eur(1000),
+			wantErrIs: order.ErrIllegalTransition,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			ctx := context.Background()
+			o := newPendingOrder(t)
+			if tt.submit {
+				require.NoError(o.Submit())
+			}
+			repo := ordermock.NewRepository(o)
+			c, _ := customer.ReconstituteCustomer(o.CustomerID(), "Jane Doe", customer.StatusActive, false,
+				eur(5000), eur(1000), nil, 1)
+			customers := customer.NewMemoryRepository(c)
+			publisher := &eventPublisherMock{}
+			svc := NewService(&ordermock.TxMaker{}, publisher)
+			svc.newRepository = func(tx order.Tx) order.Repository { return repo }
+			svc.newCustomerService = func(tx order.Tx) *customer.Service { return customer.NewService(customers) }
+
+			// When
+			err := svc.PayOrder(ctx, string(o.ID()))
+
+			// Then
+			stored, findErr := repo.FindByID(ctx, string(o.ID()))
+			require.NoError(findErr)
+			require.Equal(tt.wantState, stored.State())
+			storedCustomer, findErr := customers.FindByID(ctx, o.CustomerID())
+			require.NoError(findErr)
+			require.Equal(tt.wantUsed, storedCustomer.CreditUsed())
+
+			if tt.wantErrIs != nil {
+				require.True(errors.Is(err, tt.wantErrIs), "expected %v to wrap %v", err, tt.wantErrIs)
+				require.Empty(publisher.published)
+				return
+			}
+			require.NoError(err)
+			require.Len(publisher.published, 1)
+			require.Equal(order.EventTypeOrderPaid, publisher.published[0].EventType())
+		})
+	}
+}
+
+func TestService_RejectOrder(t *testing.T) {
+	eur := func(amount int64) order.Money {
+		m, _ := order.NewMoney(amount, "EUR")
+		return m
+	}
+
+	tests := []struct {
+		name          string
+		prepare       func(o *order.Order) error
+		wantState     order.OrderState
+		wantUsed      order.Money
+		wantPublished int
+	}{
+		{
+			name:          "should cancel submitted order and release its credit",
+			prepare:       (*order.Order).Submit,
+			wantState:     order.OrderStateCancelled,
+			wantUsed:      eur(0),
+			wantPublished: 1,
+		},
+		{
+			name: "should leave order that is no longer submitted as is",
+			prepare: func(o *order.Order) error {
+				if err := o.Submit(); err != nil {
+					return err
+				}
+				return o.Pay()
+			},
+			wantState: order.OrderStatePaid,
+			wantUsed:  eur(1000),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			ctx := context.Background()
+			o := newPendingOrder(t)
+			require.NoError(tt.prepare(o))
+			repo := ordermock.NewRepository(o)
+			c, _ := customer.ReconstituteCustomer(o.CustomerID(), "Jane Doe", customer.StatusActive, false,
+				eur(5000), eur(1000), nil, 1)
+			customers := customer.NewMemoryRepository(c)
+			publisher := &eventPublisherMock{}
+			svc := NewService(&ordermock.TxMaker{}, publisher)
+			svc.newRepository = func(tx order.Tx) order.Repository { return repo }
+			svc.newCustomerService = func(tx order.Tx) *customer.Service { return customer.NewService(customers) }
+
+			// When
+			err := svc.RejectOrder(ctx, string(o.ID()), "insufficient stock")
+
+			// Then
+			require.NoError(err)
+			stored, err := repo.FindByID(ctx, string(o.ID()))
+			require.NoError(err)
+			require.Equal(tt.wantState, stored.State())
+			storedCustomer, err := customers.FindByID(ctx, o.CustomerID())
+			require.NoError(err)
+			require.Equal(tt.wantUsed, storedCustomer.CreditUsed())
+			require.Len(publisher.published, tt.wantPublished)
+		})
+	}
+}
+
+// failingCommitTxMaker begins transactions whose commits fail. They're rolled back like a database
+// rolls back a transaction it can't commit.
+type failingCommitTxMaker struct {
+	txMaker
+	err error
+}
+
+func (m failingCommitTxMaker) BeginTransaction(ctx context.Context) (order.Tx, error) {
+	tx, err := m.txMaker.BeginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return failingCommitTx{Tx: tx, err: m.err}, nil
+}
+
+type failingCommitTx struct {
+	order.Tx
+	err error
+}
+
+func (tx failingCommitTx) Commit() error {
+	tx.Tx.Rollback()
+	return tx.err
+}
+
+func TestService_PublishesAfterCommit(t *testing.T) {
+	errAny := fmt.Errorf("any-error")
+
+	tests := []struct {
+		name      string
+		command   func(ctx context.Context, svc *Service, id order.ID) error
+		wantState order.OrderState // the state of the order when its events are published
+	}{
+		{
+			name: "create",
+			command: func(ctx context.Context, svc *Service, _ order.ID) error {
+				_, err := svc.CreateOrder(ctx, "0f8fad5b-d9cb-469f-a165-70867728950e", newPendingOrder(t).OrderItems())
+				return err
+			},
+			wantState: order.OrderStatePending,
+		},
+		{
+			name: "submit",
+			command: func(ctx context.Context, svc *Service, id order.ID) error {
+				return svc.SubmitOrder(ctx, string(id))
+			},
+			wantState: order.OrderStateSubmitted,
+		},
+		{
+			name: "cancel",
+			command: func(ctx context.Context, svc *Service, id order.ID) error {
+				return svc.CancelOrder(ctx, string(id), "changed my mind")
+			},
+			wantState: order.OrderStateCancelled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given a pending order
+			require := require.New(t)
+			ctx := context.Background()
+			db := newTestDB(t)
+			txMaker := order.NewTxMaker(sqltx.NewBeginner(db))
+			svc := NewService(txMaker, &eventPublisherMock{}, WithEventSourcing(0))
+			id, err := svc.CreateOrder(ctx, "0f8fad5b-d9cb-469f-a165-70867728950e", newPendingOrder(t).OrderItems())
+			require.NoError(err)
+
+			// When the command runs, but its transaction can't be committed
+			publisher := &eventPublisherMock{}
+			failing := NewService(failingCommitTxMaker{txMaker: txMaker, err: errAny}, publisher, WithEventSourcing(0))
+			err = tt.command(ctx, failing, id)
+
+			// Then the error is returned, and neither the order nor its events escape the transaction
+			require.True(errors.Is(err, errAny), "expected %v to wrap %v", err, errAny)
+			require.Empty(publisher.published)
+			stored, err := svc.GetOrder(ctx, string(id))
+			require.NoError(err)
+			require.Equal(order.OrderStatePending, stored.State())
+			require.Equal(1, stored.Version())
+			events, err := svc.ReadEvents(ctx, 0, 100)
+			require.NoError(err)
+			require.Len(events, 1)
+
+			// When the command runs and its transaction is committed
+			var publishedStates []order.OrderState
+			publisher = &eventPublisherMock{PublishFn: func(_ context.Context, e order.Event) error {
+				o, err := svc.GetOrder(ctx, string(e.AggregateID()))
+				if err != nil {
+					return err
+				}
+				publishedStates = append(publishedStates, o.State())
+				return nil
+			}}
+			committing := NewService(txMaker, publisher, WithEventSourcing(0))
+			require.NoError(tt.command(ctx, committing, id))
+
+			// Then the events are published once the changes they describe can be read
+			require.Len(publisher.published, 1)
+			require.Equal([]order.OrderState{tt.wantState}, publishedStates)
+		})
+	}
+}
+
+func TestService_PublishesAfterSharedCommit(t *testing.T) {
+	tests := []struct {
+		name          string
+		commit        bool
+		wantPublished []string
+		wantState     order.OrderState
+	}{
+		{
+			name:          "should publish once the shared transaction is committed",
+			commit:        true,
+			wantPublished: []string{order.EventTypeOrderSubmitted},
+			wantState:     order.OrderStateSubmitted,
+		},
+		{
+			name:      "should not publish when the shared transaction is rolled back",
+			wantState: order.OrderStatePending,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given a pending order
+			require := require.New(t)
+			ctx := context.Background()
+			db := newTestDB(t)
+			publisher := &eventPublisherMock{}
+			svc := NewService(order.NewTxMaker(sqltx.NewBeginner(db)), publisher)
+			id, err := svc.CreateOrder(ctx, "0f8fad5b-d9cb-469f-a165-70867728950e", newPendingOrder(t).OrderItems())
+			require.NoError(err)
+			publisher.published = nil
+
+			// When the order is submitted in a shared transaction
+			sharedCtx, shared, err := sqltx.Begin(ctx, db)
+			require.NoError(err)
+			require.NoError(svc.SubmitOrder(sharedCtx, string(id)))
+
+			// Then nothing is published before the shared transaction ends
+			require.Empty(publisher.published)
+
+			// When the shared transaction ends
+			if tt.commit {
+				require.NoError(shared.Commit())
+			} else {
+				require.NoError(shared.Rollback())
+			}
+
+			// Then
+			var published []string
+			for _, e := range publisher.published {
+				published = append(published, e.EventType())
+			}
+			require.Equal(tt.wantPublished, published)
+			stored, err := svc.GetOrder(ctx, string(id))
+			require.NoError(err)
+			require.Equal(tt.wantState, stored.State())
+		})
+	}
+}
+
+// conflictingRepository fails to upsert orders with a concurrency conflict the given number of times
+type conflictingRepository struct {
+	order.Repository
+	conflicts *int
+}
+
+func (r conflictingRepository) Upsert(ctx context.Context, o *order.Order) error {
+	if *r.conflicts > 0 {
+		*r.conflicts--
+		return order.ErrConcurrencyConflict
+	}
+	return r.Repository.Upsert(ctx, o)
+}
+
+func TestService_SubmitOrder_ConflictInSharedTransaction(t *testing.T) {
+	// Given a service that retries conflicts
+	require := require.New(t)
+	ctx := context.Background()
+	db := newTestDB(t)
+	publisher := &eventPublisherMock{}
+	svc := NewService(order.NewTxMaker(sqltx.NewBeginner(db)), publisher, WithConflictRetries(3))
+	id, err := svc.CreateOrder(ctx, "0f8fad5b-d9cb-469f-a165-70867728950e", newPendingOrder(t).OrderItems())
+	require.NoError(err)
+	publisher.published = nil
+	conflicts := 1
+	svc.newRepository = func(tx order.Tx) order.Repository {
+		return conflictingRepository{Repository: order.NewRepository(tx), conflicts: &conflicts}
+	}
+
+	// When the order is submitted in a shared transaction and conflicts
+	sharedCtx, shared, err := sqltx.Begin(ctx, db)
+	require.NoError(err)
+	err = svc.SubmitOrder(sharedCtx, string(id))
+
+	// Then it isn't retried in the shared transaction, which can't be committed
+	require.True(errors.Is(err, order.ErrConcurrencyConflict), "expected %v to wrap %v", err, order.ErrConcurrencyConflict)
+	require.True(shared.RollbackOnly())
+	err = svc.SubmitOrder(sharedCtx, string(id))
+	require.True(errors.Is(err, sqltx.ErrRollbackOnly), "expected %v to wrap %v", err, sqltx.ErrRollbackOnly)
+	require.NoError(shared.Rollback())
+	require.Empty(publisher.published)
+
+	// When it's submitted again on its own
+	err = svc.SubmitOrder(ctx, string(id))
+
+	// Then
+	require.NoError(err)
+	stored, err := svc.GetOrder(ctx, string(id))
+	require.NoError(err)
+	require.Equal(order.OrderStateSubmitted, stored.State())
+}
+
+func TestService_CreateOrder_TracesEvents(t *testing.T) {
+	// Given a request that's part of a flow
+	require := require.New(t)
+	metadata := order.Metadata{CorrelationID: "correlation-1", CausationID: "request-1"}
+	ctx := order.ContextWithMetadata(context.Background(), metadata)
+	repo := ordermock.NewRepository()
+	publisher := &eventPublisherMock{}
+	svc := NewService(&ordermock.TxMaker{}, publisher)
+	svc.newRepository = func(tx order.Tx) order.Repository { return repo }
+
+	// When an order is created and submitted
+	id, err := svc.CreateOrder(ctx, "0f8fad5b-d9cb-469f-a165-70867728950e", newPendingOrder(t).OrderItems())
+	require.NoError(err)
+	require.NoError(svc.SubmitOrder(ctx, string(id)))
+
+	// Then all its events are tied to the flow, including the creation
+	require.Len(publisher.published, 2)
+	require.Equal(order.EventTypeOrderCreated, publisher.published[0].EventType())
+	for _, e := range publisher.published {
+		require.Equal(metadata.CorrelationID, e.CorrelationID())
+		require.Equal(metadata.CausationID, e.CausationID())
+	}
+}
+
+func TestService_LogsPublishErrorsByDefault(t *testing.T) {
+	// Given a service without a publish error handler whose events can't be published
+	require := require.New(t)
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	pending := newPendingOrder(t)
+	repo := ordermock.NewRepository(pending)
+	publisher := &eventPublisherMock{PublishFn: func(ctx context.Context, msg order.Event) error { return errors.New("broker unavailable") }}
+	svc := NewService(&ordermock.TxMaker{}, publisher)
+	svc.newRepository = func(tx order.Tx) order.Repository { return repo }
+
+	// When
+	err := svc.SubmitOrder(context.Background(), string(pending.ID()))
+
+	// Then the order is submitted and the failure is logged
+	require.NoError(err)
+	require.Contains(logged.String(), order.EventTypeOrderSubmitted)
+	require.Contains(logged.String(), string(pending.ID()))
+	require.Contains(logged.String(), "broker unavailable")
+}
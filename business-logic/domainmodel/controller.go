@@ -3,40 +3,484 @@ package domainmodel
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/projection"
 )
 
 type (
-	RequestModel struct {
-		ID string `json:"id"`
+	MoneyModel struct {
+		Amount   string `json:"amount"` // in the major unit of the currency, e.g. 12.50
+		Currency string `json:"currency"`
+	}
+
+	OrderItemRequestModel struct {
+		ArticleNo string     `json:"articleNo"`
+		Quantity  int        `json:"quantity"`
+		UnitPrice MoneyModel `json:"unitPrice"`
+	}
+
+	CreateOrderRequestModel struct {
+		CustomerID string                  `json:"customerId"`
+		Items      []OrderItemRequestModel `json:"items"`
+	}
+
+	CancelOrderRequestModel struct {
+		Reason string `json:"reason"`
+	}
+
+	ChangeQuantityRequestModel struct {
+		Quantity int `json:"quantity"`
+	}
+
+	OrderItemResponseModel struct {
+		ArticleNo string     `json:"articleNo"`
+		Quantity  int        `json:"quantity"`
+		UnitPrice MoneyModel `json:"unitPrice"`
+		LineTotal MoneyModel `json:"lineTotal"`
+	}
+
+	DiscountResponseModel struct {
+		PromotionID string     `json:"promotionId"`
+		Description string     `json:"description"`
+		Target      string     `json:"target"`
+		ArticleNo   string     `json:"articleNo,omitempty"`
+		Amount      MoneyModel `json:"amount"`
+	}
+
+	TaxResponseModel struct {
+		Jurisdiction string     `json:"jurisdiction"`
+		Inclusive    bool       `json:"inclusive"`
+		Total        MoneyModel `json:"total"`
+	}
+
+	OrderResponseModel struct {
+		ID          string                   `json:"id"`
+		CustomerID  string                   `json:"customerId"`
+		State       string                   `json:"state"`
+		Items       []OrderItemResponseModel `json:"items"`
+		Subtotal    MoneyModel               `json:"subtotal"`
+		ShippingFee MoneyModel               `json:"shippingFee"`
+		Discounts   []DiscountResponseModel  `json:"discounts"`
+		Tax         *TaxResponseModel        `json:"tax,omitempty"`
+		Total       MoneyModel               `json:"total"`
+		Version     int                      `json:"version"`
+	}
+
+	OrderSummaryResponseModel struct {
+		OrderID   string     `json:"orderId"`
+		State     string     `json:"state"`
+		ItemCount int        `json:"itemCount"`
+		Total     MoneyModel `json:"total"`
+	}
+
+	CustomerOrdersResponseModel struct {
+		CustomerID string                      `json:"customerId"`
+		Orders     []OrderSummaryResponseModel `json:"orders"`
 	}
 )
 
-type orderAppService interface {
-	SubmitOrder(ctx context.Context, id string) error
-}
+const (
+	HeaderCorrelationID = "X-Correlation-ID"
+	HeaderRequestID     = "X-Request-ID"
+
+	maxRequestBodyBytes = 1 << 20
+)
+
+type (
+	orderAppService interface {
+		CreateOrder(ctx context.Context, customerID string, items []order.OrderItem) (order.ID, error)
+		GetOrder(ctx context.Context, id string) (*order.Order, error)
+		SubmitOrder(ctx context.Context, id string) error
+		CancelOrder(ctx context.Context, id, reason string) error
+		AddItem(ctx context.Context, id, articleNo string, quantity int, unitPrice order.Money) error
+		RemoveItem(ctx context.Context, id, articleNo string) error
+		ChangeItemQuantity(ctx context.Context, id, articleNo string, quantity int) error
+	}
 
-// OrderController is a simplified version of a controller since it doesn't handle errors.
+	orderQuerier interface {
+		OrdersByCustomer(ctx context.Context, customerID string) ([]projection.OrderSummary, error)
+	}
+)
+
+// OrderController exposes the order application service over HTTP. It routes the requests itself:
+//
+//	POST   /orders                             creates an order
+//	GET    /orders/{id}                        returns an order
+//	POST   /orders/{id}:submit                 submits an order
+//	POST   /orders/{id}:cancel                 cancels an order
+//	POST   /orders/{id}/items                  adds an item to an order
+//	PUT    /orders/{id}/items/{articleNo}      changes the quantity of an item
+//	DELETE /orders/{id}/items/{articleNo}      removes an item from an order
+//	GET    /customers/{id}/orders              lists the orders of a customer
+//
+// Wrap it with idempotency.Middleware to make retries safe, in which case the commands are
+// executed in the same transactions as the idempotency keys are stored as long as the application
+// service's txMaker begins its transactions with a sqltx.Beginner.
 type OrderController struct {
-	orderAppSvc orderAppService
+	orderAppSvc  orderAppService
+	orderQuerier orderQuerier
+}
+
+// NewController returns an OrderController. The orders of customers are listed from the read
+// model queried by orderQuerier, e.g. a projection.QueryService.
+func NewController(orderAppService orderAppService, orderQuerier orderQuerier) *OrderController {
+	return &OrderController{orderAppSvc: orderAppService, orderQuerier: orderQuerier}
+}
+
+func (c *OrderController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(segments) == 1 && segments[0] == "orders":
+		c.route(w, r, map[string]http.HandlerFunc{http.MethodPost: c.createOrder})
+
+	case len(segments) == 2 && segments[0] == "orders":
+		id, action, _ := strings.Cut(segments[1], ":")
+		switch action {
+		case "":
+			c.route(w, r, map[string]http.HandlerFunc{http.MethodGet: c.withID(id, c.getOrder)})
+		case "submit":
+			c.route(w, r, map[string]http.HandlerFunc{http.MethodPost: c.withID(id, c.submitOrder)})
+		case "cancel":
+			c.route(w, r, map[string]http.HandlerFunc{http.MethodPost: c.withID(id, c.cancelOrder)})
+		default:
+			apperror.NewStatusProblem(r, http.StatusNotFound, fmt.Sprintf("unknown action = %s", action)).Write(w)
+		}
+
+	case len(segments) == 3 && segments[0] == "orders" && segments[2] == "items":
+		c.route(w, r, map[string]http.HandlerFunc{http.MethodPost: c.withID(segments[1], c.addItem)})
+
+	case len(segments) == 4 && segments[0] == "orders" && segments[2] == "items":
+		articleNo := segments[3]
+		c.route(w, r, map[string]http.HandlerFunc{
+			http.MethodPut: c.withID(segments[1], func(w http.ResponseWriter, r *http.Request, id order.ID) {
+				c.changeItemQuantity(w, r, id, articleNo)
+			}),
+			http.MethodDelete: c.withID(segments[1], func(w http.ResponseWriter, r *http.Request, id order.ID) {
+				c.removeItem(w, r, id, articleNo)
+			}),
+		})
+
+	case len(segments) == 3 && segments[0] == "customers" && segments[2] == "orders":
+		c.route(w, r, map[string]http.HandlerFunc{http.MethodGet: func(w http.ResponseWriter, r *http.Request) {
+			c.customerOrders(w, r, segments[1])
+		}})
+
+	default:
+		apperror.NewStatusProblem(r, http.StatusNotFound, fmt.Sprintf("no resource at path = %s", r.URL.Path)).Write(w)
+	}
+}
+
+// route calls the handler of the request's method, or responds with 405 Method Not Allowed
+func (c *OrderController) route(w http.ResponseWriter, r *http.Request, handlers map[string]http.HandlerFunc) {
+	handler, ok := handlers[r.Method]
+	if !ok {
+		allowed := make([]string, 0, len(handlers))
+		for method := range handlers {
+			allowed = append(allowed, method)
+		}
+		sort.Strings(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		apperror.NewStatusProblem(r, http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not allowed for path = %s", r.Method, r.URL.Path)).Write(w)
+		return
+	}
+
+	handler(w, r)
 }
 
-func NewController(orderAppService orderAppService) *OrderController {
-	return &OrderController{orderAppSvc: orderAppService}
+// withID validates the order id in the path before calling the handler
+func (c *OrderController) withID(rawID string, handler func(w http.ResponseWriter, r *http.Request, id order.ID)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := order.NewID(rawID)
+		if err != nil {
+			apperror.WriteProblem(w, r, err)
+			return
+		}
+
+		handler(w, r, id)
+	}
 }
 
-// SubmitOrder submits the Order with the given id
-func (c *OrderController) SubmitOrder(w http.ResponseWriter, r *http.Request) {
-	// Bind request model
-	body, _ := io.ReadAll(r.Body)
-	var reqModel RequestModel
-	json.Unmarshal(body, &reqModel)
+func (c *OrderController) createOrder(w http.ResponseWriter, r *http.Request) {
+	var reqModel CreateOrderRequestModel
+	if err := bindJSON(w, r, &reqModel); err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	if _, err := order.NewCustomerID(reqModel.CustomerID); err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	items := make([]order.OrderItem, 0, len(reqModel.Items))
+	for _, itemModel := range reqModel.Items {
+		item, err := toOrderItem(itemModel)
+		if err != nil {
+			apperror.WriteProblem(w, r, err)
+			return
+		}
+		items = append(items, item)
+	}
+
+	id, err := c.orderAppSvc.CreateOrder(tracedContext(r), reqModel.CustomerID, items)
+	if err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/orders/"+string(id))
+	c.writeOrder(w, r, id, http.StatusCreated)
+}
+
+func (c *OrderController) getOrder(w http.ResponseWriter, r *http.Request, id order.ID) {
+	c.writeOrder(w, r, id, http.StatusOK)
+}
+
+func (c *OrderController) submitOrder(w http.ResponseWriter, r *http.Request, id order.ID) {
+	if err := c.orderAppSvc.SubmitOrder(tracedContext(r), string(id)); err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeOrder(w, r, id, http.StatusOK)
+}
+
+func (c *OrderController) cancelOrder(w http.ResponseWriter, r *http.Request, id order.ID) {
+	var reqModel CancelOrderRequestModel
+	if err := bindJSON(w, r, &reqModel); err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	if err := c.orderAppSvc.CancelOrder(tracedContext(r), string(id), reqModel.Reason); err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeOrder(w, r, id, http.StatusOK)
+}
+
+func (c *OrderController) addItem(w http.ResponseWriter, r *http.Request, id order.ID) {
+	var reqModel OrderItemRequestModel
+	if err := bindJSON(w, r, &reqModel); err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	item, err := toOrderItem(reqModel)
+	if err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	if err = c.orderAppSvc.AddItem(tracedContext(r), string(id), item.ArticleNo, item.Quantity, item.UnitPrice); err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeOrder(w, r, id, http.StatusOK)
+}
+
+func (c *OrderController) changeItemQuantity(w http.ResponseWriter, r *http.Request, id order.ID, articleNo string) {
+	var reqModel ChangeQuantityRequestModel
+	if err := bindJSON(w, r, &reqModel); err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	if err := c.orderAppSvc.ChangeItemQuantity(tracedContext(r), string(id), articleNo, reqModel.Quantity); err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeOrder(w, r, id, http.StatusOK)
+}
+
+func (c *OrderController) removeItem(w http.ResponseWriter, r *http.Request, id order.ID, articleNo string) {
+	if err := c.orderAppSvc.RemoveItem(tracedContext(r), string(id), articleNo); err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeOrder(w, r, id, http.StatusOK)
+}
+
+func (c *OrderController) customerOrders(w http.ResponseWriter, r *http.Request, customerID string) {
+	if _, err := order.NewCustomerID(customerID); err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	summaries, err := c.orderQuerier.OrdersByCustomer(r.Context(), customerID)
+	if err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	respModel := CustomerOrdersResponseModel{
+		CustomerID: customerID,
+		Orders:     make([]OrderSummaryResponseModel, 0, len(summaries)),
+	}
+	for _, summary := range summaries {
+		respModel.Orders = append(respModel.Orders, OrderSummaryResponseModel{
+			OrderID:   summary.OrderID,
+			State:     summary.State,
+			ItemCount: summary.ItemCount,
+			Total:     toMoneyModel(summary.Total),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, respModel)
+}
+
+// writeOrder responds with the current representation of the order
+func (c *OrderController) writeOrder(w http.ResponseWriter, r *http.Request, id order.ID, status int) {
+	o, err := c.orderAppSvc.GetOrder(r.Context(), string(id))
+	if err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	respModel, err := toOrderResponseModel(o)
+	if err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, status, respModel)
+}
+
+func toOrderItem(itemModel OrderItemRequestModel) (order.OrderItem, error) {
+	unitPrice, err := order.ParseMoney(itemModel.UnitPrice.Amount, order.Currency(itemModel.UnitPrice.Currency))
+	if err != nil {
+		return order.OrderItem{}, fmt.Errorf("could not parse unit price of article = %s: %w", itemModel.ArticleNo, err)
+	}
+
+	return order.NewOrderItem(itemModel.ArticleNo, itemModel.Quantity, unitPrice)
+}
+
+func toOrderResponseModel(o *order.Order) (OrderResponseModel, error) {
+	subtotal, err := o.Subtotal()
+	if err != nil {
+		return OrderResponseModel{}, err
+	}
+
+	total, err := o.Total()
+	if err != nil {
+		return OrderResponseModel{}, err
+	}
+
+	respModel := OrderResponseModel{
+		ID:          string(o.ID()),
+		CustomerID:  string(o.CustomerID()),
+		State:       o.State().String(),
+		Subtotal:    toMoneyModel(subtotal),
+		Items:       []OrderItemResponseModel{},
+		ShippingFee: toMoneyModel(o.ShippingFee()),
+		Discounts:   []DiscountResponseModel{},
+		Total:       toMoneyModel(total),
+		Version:     o.Version(),
+	}
+
+	for _, item := range o.OrderItems() {
+		lineTotal, err := item.LineTotal()
+		if err != nil {
+			return OrderResponseModel{}, err
+		}
+
+		respModel.Items = append(respModel.Items, OrderItemResponseModel{
+			ArticleNo: item.ArticleNo,
+			Quantity:  item.Quantity,
+			UnitPrice: toMoneyModel(item.UnitPrice),
+			LineTotal: toMoneyModel(lineTotal),
+		})
+	}
+
+	for _, discount := range o.Discounts() {
+		respModel.Discounts = append(respModel.Discounts, DiscountResponseModel{
+			PromotionID: discount.PromotionID,
+			Description: discount.Description,
+			Target:      string(discount.Target),
+			ArticleNo:   discount.ArticleNo,
+			Amount:      toMoneyModel(discount.Amount),
+		})
+	}
+
+	if tax, ok := o.Tax(); ok {
+		respModel.Tax = &TaxResponseModel{
+			Jurisdiction: tax.Jurisdiction,
+			Inclusive:    tax.Inclusive,
+			Total:        toMoneyModel(tax.Total),
+		}
+	}
+
+	return respModel, nil
+}
+
+func toMoneyModel(m order.Money) MoneyModel {
+	return MoneyModel{Amount: m.Decimal(), Currency: string(m.Currency())}
+}
+
+// tracedContext ties the domain events raised while handling the request to the request
+func tracedContext(r *http.Request) context.Context {
+	return order.ContextWithMetadata(r.Context(), eventMetadata(r))
+}
+
+// eventMetadata ties the domain events raised while handling the request to the request
+func eventMetadata(r *http.Request) order.Metadata {
+	return newEventMetadata(r.Header.Get(HeaderCorrelationID), r.Header.Get(HeaderRequestID))
+}
+
+// newEventMetadata generates a new correlation id if the client didn't send one. The causation id
+// defaults to the correlation id.
+func newEventMetadata(correlationID, causationID string) order.Metadata {
+	if correlationID == "" {
+		correlationID = uuid.NewString()
+	}
+
+	if causationID == "" {
+		causationID = correlationID
+	}
+
+	return order.Metadata{CorrelationID: correlationID, CausationID: causationID}
+}
+
+// bindJSON strictly decodes the request body, which must be a single JSON value no larger than
+// maxRequestBodyBytes, into v. Unknown fields are rejected so that misspelled fields don't go
+// unnoticed.
+func bindJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return apperror.Validation("INVALID_REQUEST", "request body must not be larger than %d bytes", maxRequestBodyBytes)
+		}
+		return apperror.Validation("INVALID_REQUEST", "could not decode request body: %v", err)
+	}
+
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return apperror.Validation("INVALID_REQUEST", "request body must contain a single JSON value")
+	}
+
+	return nil
+}
 
-	// Use orderAppSvc to coordinate the submission of the given Order
-	ctx := r.Context()
-	c.orderAppSvc.SubmitOrder(ctx, reqModel.ID)
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	body, _ := json.Marshal(v)
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(nil)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
 }
@@ -0,0 +1,282 @@
+package domainmodel
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+	ordermock "github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order/mock"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/projection"
+)
+
+type orderQuerierMock struct {
+	summaries []projection.OrderSummary
+}
+
+func (m *orderQuerierMock) OrdersByCustomer(_ context.Context, customerID string) ([]projection.OrderSummary, error) {
+	var summaries []projection.OrderSummary
+	for _, summary := range m.summaries {
+		if summary.CustomerID == customerID {
+			summaries = append(summaries, summary)
+		}
+	}
+
+	return summaries, nil
+}
+
+func TestOrderController(t *testing.T) {
+	const unknownID = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+
+	tests := []struct {
+		name       string
+		method     string
+		path       func(pending, cancelled order.ID) string
+		body       string
+		wantStatus int
+		wantState  string
+		wantItems  int
+		wantTotal  string
+	}{
+		{
+			name:       "should create order",
+			method:     http.MethodPost,
+			path:       func(_, _ order.ID) string { return "/orders" },
+			body:       `{"customerId": "0f8fad5b-d9cb-469f-a165-70867728950e", "items": [{"articleNo": "B2", "quantity": 2, "unitPrice": {"amount": "12.50", "currency": "EUR"}}]}`,
+			wantStatus: http.StatusCreated,
+			wantState:  "pending",
+			wantItems:  1,
+			wantTotal:  "25.00",
+		},
+		{
+			name:       "should not create order with malformed body",
+			method:     http.MethodPost,
+			path:       func(_, _ order.ID) string { return "/orders" },
+			body:       `{"customerId": `,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should not create order with unknown fields",
+			method:     http.MethodPost,
+			path:       func(_, _ order.ID) string { return "/orders" },
+			body:       `{"customerId": "0f8fad5b-d9cb-469f-a165-70867728950e", "customer": "typo", "items": []}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should not create order with trailing JSON values",
+			method:     http.MethodPost,
+			path:       func(_, _ order.ID) string { return "/orders" },
+			body:       `{"customerId": "0f8fad5b-d9cb-469f-a165-70867728950e", "items": []} {}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should not create order with too large body",
+			method:     http.MethodPost,
+			path:       func(_, _ order.ID) string { return "/orders" },
+			body:       `{"customerId": "0f8fad5b-d9cb-469f-a165-70867728950e", "items": []` + strings.Repeat(" ", maxRequestBodyBytes) + `}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should not create order for invalid customer id",
+			method:     http.MethodPost,
+			path:       func(_, _ order.ID) string { return "/orders" },
+			body:       `{"customerId": "not-a-uuid"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should not create order with invalid item",
+			method:     http.MethodPost,
+			path:       func(_, _ order.ID) string { return "/orders" },
+			body:       `{"customerId": "0f8fad5b-d9cb-469f-a165-70867728950e", "items": [{"articleNo": "B2", "quantity": 0, "unitPrice": {"amount": "12.50", "currency": "EUR"}}]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should get order",
+			method:     http.MethodGet,
+			path:       func(pending, _ order.ID) string { return "/orders/" + string(pending) },
+			wantStatus: http.StatusOK,
+			wantState:  "pending",
+			wantItems:  1,
+			wantTotal:  "10.00",
+		},
+		{
+			name:       "should not get unknown order",
+			method:     http.MethodGet,
+			path:       func(_, _ order.ID) string { return "/orders/" + unknownID },
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "should not get order by invalid id",
+			method:     http.MethodGet,
+			path:       func(_, _ order.ID) string { return "/orders/42" },
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should submit order",
+			method:     http.MethodPost,
+			path:       func(pending, _ order.ID) string { return "/orders/" + string(pending) + ":submit" },
+			wantStatus: http.StatusOK,
+			wantState:  "submitted",
+			wantItems:  1,
+			wantTotal:  "10.00",
+		},
+		{
+			name:       "should not submit cancelled order",
+			method:     http.MethodPost,
+			path:       func(_, cancelled order.ID) string { return "/orders/" + string(cancelled) + ":submit" },
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "should cancel order",
+			method:     http.MethodPost,
+			path:       func(pending, _ order.ID) string { return "/orders/" + string(pending) + ":cancel" },
+			body:       `{"reason": "changed my mind"}`,
+			wantStatus: http.StatusOK,
+			wantState:  "cancelled",
+			wantItems:  1,
+			wantTotal:  "10.00",
+		},
+		{
+			name:       "should add item",
+			method:     http.MethodPost,
+			path:       func(pending, _ order.ID) string { return "/orders/" + string(pending) + "/items" },
+			body:       `{"articleNo": "B2", "quantity": 1, "unitPrice": {"amount": "5", "currency": "EUR"}}`,
+			wantStatus: http.StatusOK,
+			wantState:  "pending",
+			wantItems:  2,
+			wantTotal:  "15.00",
+		},
+		{
+			name:       "should not add item with unparsable price",
+			method:     http.MethodPost,
+			path:       func(pending, _ order.ID) string { return "/orders/" + string(pending) + "/items" },
+			body:       `{"articleNo": "B2", "quantity": 1, "unitPrice": {"amount": "five", "currency": "EUR"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should not add item in other currency",
+			method:     http.MethodPost,
+			path:       func(pending, _ order.ID) string { return "/orders/" + string(pending) + "/items" },
+			body:       `{"articleNo": "B2", "quantity": 1, "unitPrice": {"amount": "5", "currency": "SEK"}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should change item quantity",
+			method:     http.MethodPut,
+			path:       func(pending, _ order.ID) string { return "/orders/" + string(pending) + "/items/A1" },
+			body:       `{"quantity": 3}`,
+			wantStatus: http.StatusOK,
+			wantState:  "pending",
+			wantItems:  1,
+			wantTotal:  "30.00",
+		},
+		{
+			name:       "should not change quantity of unknown item",
+			method:     http.MethodPut,
+			path:       func(pending, _ order.ID) string { return "/orders/" + string(pending) + "/items/Z9" },
+			body:       `{"quantity": 3}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "should remove item",
+			method:     http.MethodDelete,
+			path:       func(pending, _ order.ID) string { return "/orders/" + string(pending) + "/items/A1" },
+			wantStatus: http.StatusOK,
+			wantState:  "pending",
+		},
+		{
+			name:       "should not remove item of cancelled order",
+			method:     http.MethodDelete,
+			path:       func(_, cancelled order.ID) string { return "/orders/" + string(cancelled) + "/items/A1" },
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "should not allow other methods",
+			method:     http.MethodPatch,
+			path:       func(pending, _ order.ID) string { return "/orders/" + string(pending) },
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "should not find unknown action",
+			method:     http.MethodPost,
+			path:       func(pending, _ order.ID) string { return "/orders/" + string(pending) + ":pay" },
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			pending := newPendingOrder(t)
+			cancelled := newPendingOrder(t)
+			require.NoError(cancelled.Cancel("changed my mind"))
+			repo := ordermock.NewRepository(pending, cancelled)
+			svc := NewService(&ordermock.TxMaker{}, &eventPublisherMock{})
+			svc.newRepository = func(tx order.Tx) order.Repository { return repo }
+			controller := NewController(svc, &orderQuerierMock{})
+
+			// When
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path(pending.ID(), cancelled.ID()), strings.NewReader(tt.body))
+			controller.ServeHTTP(rec, req)
+
+			// Then
+			require.Equal(tt.wantStatus, rec.Code, rec.Body.String())
+			if tt.wantState == "" {
+				require.Equal(apperror.ProblemContentType, rec.Header().Get("Content-Type"))
+				var problem apperror.Problem
+				require.NoError(json.Unmarshal(rec.Body.Bytes(), &problem))
+				require.Equal(tt.wantStatus, problem.Status)
+				return
+			}
+
+			var respModel OrderResponseModel
+			require.NoError(json.Unmarshal(rec.Body.Bytes(), &respModel))
+			require.Equal(tt.wantState, respModel.State)
+			require.Len(respModel.Items, tt.wantItems)
+			if tt.wantTotal != "" {
+				require.Equal(MoneyModel{Amount: tt.wantTotal, Currency: "EUR"}, respModel.Total)
+			}
+			if tt.wantStatus == http.StatusCreated {
+				require.Equal("/orders/"+respModel.ID, rec.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestOrderController_CustomerOrders(t *testing.T) {
+	// Given
+	require := require.New(t)
+	const customerID = "0f8fad5b-d9cb-469f-a165-70867728950e"
+	total, err := order.NewMoney(2500, "EUR")
+	require.NoError(err)
+	querier := &orderQuerierMock{summaries: []projection.OrderSummary{
+		{OrderID: "o1", CustomerID: customerID, State: "pending", ItemCount: 2, Total: total},
+		{OrderID: "o2", CustomerID: "7c9e6679-7425-40de-944b-e07fc1f90ae7", State: "pending"},
+	}}
+	controller := NewController(NewService(&ordermock.TxMaker{}, &eventPublisherMock{}), querier)
+
+	// When
+	rec := httptest.NewRecorder()
+	controller.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/customers/"+customerID+"/orders", nil))
+
+	// Then
+	require.Equal(http.StatusOK, rec.Code)
+	var respModel CustomerOrdersResponseModel
+	require.NoError(json.Unmarshal(rec.Body.Bytes(), &respModel))
+	require.Equal([]OrderSummaryResponseModel{
+		{OrderID: "o1", State: "pending", ItemCount: 2, Total: MoneyModel{Amount: "25.00", Currency: "EUR"}},
+	}, respModel.Orders)
+
+	// When the customer id is invalid
+	rec = httptest.NewRecorder()
+	controller.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/customers/42/orders", nil))
+
+	// Then
+	require.Equal(http.StatusBadRequest, rec.Code)
+}
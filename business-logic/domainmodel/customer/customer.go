@@ -0,0 +1,322 @@
+// Package customer contains the Customer aggregate, which orders reference by their customer id,
+// and the domain service that decides whether a customer may submit an order.
+package customer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+var (
+	// ErrBlocked is returned when a blocked customer tries to use credit
+	ErrBlocked = apperror.New(apperror.CategoryPreconditionFailed, "CUSTOMER_BLOCKED", "customer is blocked")
+
+	// ErrClosed is returned when a closed customer tries to use credit
+	ErrClosed = apperror.New(apperror.CategoryPreconditionFailed, "CUSTOMER_CLOSED", "customer is closed")
+
+	// ErrCreditLimitExceeded is returned when an order total exceeds the remaining credit of the customer
+	ErrCreditLimitExceeded = apperror.New(apperror.CategoryPreconditionFailed, "CUSTOMER_CREDIT_LIMIT_EXCEEDED", "credit limit exceeded")
+)
+
+type Status int
+
+const (
+	StatusClosed Status = 0
+	StatusActive Status = 1
+)
+
+// NewStatus is a factory function for instantiating a Status value object
+func NewStatus(status int) (Status, error) {
+	switch s := Status(status); s {
+	case StatusClosed, StatusActive:
+		return s, nil
+	default:
+		return 0, fmt.Errorf("invalid customer status = %d", status)
+	}
+}
+
+func (s Status) String() string {
+	switch s {
+	case StatusClosed:
+		return "closed"
+	case StatusActive:
+		return "active"
+	default:
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+}
+
+// Address is where a customer's orders can be shipped to
+type Address struct {
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2,omitempty"`
+	PostalCode string `json:"postalCode"`
+	City       string `json:"city"`
+	Region     string `json:"region,omitempty"` // the state or province, if the country has any
+	Country    string `json:"country"`          // ISO 3166-1 alpha-2 country code, e.g. SE
+}
+
+// NewAddress is a factory function for instantiating an Address value object
+func NewAddress(line1, line2, postalCode, city, region, country string) (Address, error) {
+	// validate input
+	if line1 == "" || postalCode == "" || city == "" {
+		return Address{}, fmt.Errorf("address must have a street, postal code and city")
+	}
+
+	if len(country) != 2 {
+		return Address{}, fmt.Errorf("invalid country = %q", country)
+	}
+
+	// return valid value object
+	return Address{
+		Line1:      line1,
+		Line2:      line2,
+		PostalCode: postalCode,
+		City:       city,
+		Region:     strings.ToUpper(region),
+		Country:    strings.ToUpper(country),
+	}, nil
+}
+
+// Customer is an aggregate root and entity. Customers buy on credit, which means the totals of
+// their submitted orders are charged against their credit limit until the orders are paid or
+// cancelled.
+type Customer struct {
+	id          order.CustomerID // the entity's unique id, which orders reference
+	name        string
+	status      Status
+	blocked     bool        // blocked customers keep their orders but can't submit new ones
+	creditLimit order.Money // the most credit the customer may use
+	creditUsed  order.Money // the credit used by orders that haven't been paid or cancelled
+	addresses   []Address
+	version     int // the aggregate version which is incremented by every command
+
+	loadedVersion int // the version the customer was loaded at, which is 0 for new customers
+}
+
+// NewCustomer is a factory function for creating a new Customer entity which begins its life cycle
+func NewCustomer(name string, creditLimit order.Money) (*Customer, error) {
+	id, err := order.NewCustomerID(uuid.NewString())
+	if err != nil {
+		return nil, err
+	}
+
+	// enforce business rules
+	if name == "" {
+		return nil, fmt.Errorf("customer must have a name")
+	}
+
+	if creditLimit.Currency() == "" || creditLimit.IsNegative() {
+		return nil, fmt.Errorf("invalid credit limit")
+	}
+
+	return &Customer{
+		id:          id,
+		name:        name,
+		status:      StatusActive,
+		creditLimit: creditLimit,
+		creditUsed:  order.Zero(creditLimit.Currency()),
+		version:     1,
+	}, nil
+}
+
+// ReconstituteCustomer is a factory function for instantiating a Customer entity in the middle of
+// its life cycle. The version is the aggregate version the customer was stored at.
+func ReconstituteCustomer(id order.CustomerID, name string, status Status, blocked bool, creditLimit, creditUsed order.Money,
+	addresses []Address, version int) (*Customer, error) {
+	return &Customer{
+		id:            id,
+		name:          name,
+		status:        status,
+		blocked:       blocked,
+		creditLimit:   creditLimit,
+		creditUsed:    creditUsed,
+		addresses:     addresses,
+		version:       version,
+		loadedVersion: version,
+	}, nil
+}
+
+func (c *Customer) ID() order.CustomerID {
+	return c.id
+}
+
+func (c *Customer) Name() string {
+	return c.name
+}
+
+func (c *Customer) Status() Status {
+	return c.status
+}
+
+func (c *Customer) IsBlocked() bool {
+	return c.blocked
+}
+
+func (c *Customer) CreditLimit() order.Money {
+	return c.creditLimit
+}
+
+func (c *Customer) CreditUsed() order.Money {
+	return c.creditUsed
+}
+
+// RemainingCredit is the credit the customer may still use
+func (c *Customer) RemainingCredit() (order.Money, error) {
+	return c.creditLimit.Sub(c.creditUsed)
+}
+
+// Addresses returns a copy of the slice to avoid leaking a reference to Customer's internal slice
+func (c *Customer) Addresses() []Address {
+	addresses := make([]Address, len(c.addresses))
+	copy(addresses, c.addresses)
+
+	return addresses
+}
+
+// Version is the aggregate version
+func (c *Customer) Version() int {
+	return c.version
+}
+
+// Block is an aggregate command that stops the customer from using credit
+func (c *Customer) Block() {
+	if c.blocked {
+		return
+	}
+
+	c.blocked = true
+	c.version++
+}
+
+// Unblock is an aggregate command that lets a blocked customer use credit again
+func (c *Customer) Unblock() {
+	if !c.blocked {
+		return
+	}
+
+	c.blocked = false
+	c.version++
+}
+
+// Close is an aggregate command that ends the customer's life cycle. Customers can't be closed
+// while they have orders that use credit.
+func (c *Customer) Close() error {
+	// check business rules and invariants
+	if c.status == StatusClosed {
+		return nil
+	}
+
+	if !c.creditUsed.IsZero() {
+		return fmt.Errorf("could not close customer = %s with %s of credit used", c.id, c.creditUsed)
+	}
+
+	c.status = StatusClosed
+	c.version++
+
+	return nil
+}
+
+// ChangeCreditLimit is an aggregate command that sets the credit limit of the customer. Lowering
+// it below the credit used is allowed, which stops the customer from using more credit.
+func (c *Customer) ChangeCreditLimit(limit order.Money) error {
+	// check business rules and invariants
+	if limit.IsNegative() {
+		return fmt.Errorf("credit limit must not be negative")
+	}
+
+	if limit.Currency() != c.creditLimit.Currency() {
+		return fmt.Errorf("could not change credit limit of customer = %s: %w", c.id, order.ErrCurrencyMismatch)
+	}
+
+	c.creditLimit = limit
+	c.version++
+
+	return nil
+}
+
+// AddAddress is an aggregate command that adds an address the customer's orders can be shipped to
+func (c *Customer) AddAddress(address Address) error {
+	address, err := NewAddress(address.Line1, address.Line2, address.PostalCode, address.City, address.Region, address.Country)
+	if err != nil {
+		return err
+	}
+
+	// check business rules and invariants
+	for _, existing := range c.addresses {
+		if existing == address {
+			return nil
+		}
+	}
+
+	c.addresses = append(c.Addresses(), address)
+	c.version++
+
+	return nil
+}
+
+// ReserveCredit is an aggregate command that charges the amount against the customer's credit
+// limit. Closed and blocked customers can't use credit, and neither can customers whose remaining
+// credit is less than the amount.
+func (c *Customer) ReserveCredit(amount order.Money) error {
+	// check business rules and invariants
+	if c.status != StatusActive {
+		return fmt.Errorf("could not reserve credit of customer = %s: %w", c.id, ErrClosed)
+	}
+
+	if c.blocked {
+		return fmt.Errorf("could not reserve credit of customer = %s: %w", c.id, ErrBlocked)
+	}
+
+	if amount.IsNegative() {
+		return fmt.Errorf("reserved credit must not be negative")
+	}
+
+	remaining, err := c.RemainingCredit()
+	if err != nil {
+		return err
+	}
+
+	comparison, err := amount.Compare(remaining)
+	if err != nil {
+		return fmt.Errorf("could not reserve credit of customer = %s: %w", c.id, err)
+	}
+
+	if comparison > 0 {
+		return fmt.Errorf("could not reserve %s of credit with %s remaining for customer = %s: %w", amount, remaining, c.id, ErrCreditLimitExceeded)
+	}
+
+	if c.creditUsed, err = c.creditUsed.Add(amount); err != nil {
+		return err
+	}
+	c.version++
+
+	return nil
+}
+
+// ReleaseCredit is an aggregate command that gives back credit reserved for an order, which is
+// done when the order is paid or cancelled
+func (c *Customer) ReleaseCredit(amount order.Money) error {
+	// check business rules and invariants
+	if amount.IsNegative() {
+		return fmt.Errorf("released credit must not be negative")
+	}
+
+	used, err := c.creditUsed.Sub(amount)
+	if err != nil {
+		return fmt.Errorf("could not release credit of customer = %s: %w", c.id, err)
+	}
+
+	if used.IsNegative() {
+		return fmt.Errorf("could not release %s of credit with %s used by customer = %s", amount, c.creditUsed, c.id)
+	}
+
+	c.creditUsed = used
+	c.version++
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package customer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+func eur(amount int64) order.Money {
+	m, _ := order.NewMoney(amount, "EUR")
+	return m
+}
+
+func TestCustomer_ReserveCredit(t *testing.T) {
+	sek, _ := order.NewMoney(100, "SEK")
+
+	tests := []struct {
+		name      string
+		status    Status
+		blocked   bool
+		used      order.Money
+		amount    order.Money
+		wantUsed  order.Money
+		wantErr   bool
+		wantErrIs error
+	}{
+		{
+			name:     "should reserve credit within limit",
+			status:   StatusActive,
+			used:     eur(2000),
+			amount:   eur(3000),
+			wantUsed: eur(5000),
+		},
+		{
+			name:     "should reserve all remaining credit",
+			status:   StatusActive,
+			used:     eur(2000),
+			amount:   eur(8000),
+			wantUsed: eur(10000),
+		},
+		{
+			name:     "should reserve nothing for order without total",
+			status:   StatusActive,
+			used:     eur(2000),
+			amount:   order.Money{},
+			wantUsed: eur(2000),
+		},
+		{
+			name:      "should not reserve more than remaining credit",
+			status:    StatusActive,
+			used:      eur(2000),
+			amount:    eur(8001),
+			wantErr:   true,
+			wantErrIs: ErrCreditLimitExceeded,
+		},
+		{
+			name:      "should not reserve credit of blocked customer",
+			status:    StatusActive,
+			blocked:   true,
+			used:      eur(0),
+			amount:    eur(1),
+			wantErr:   true,
+			wantErrIs: ErrBlocked,
+		},
+		{
+			name:      "should not reserve credit of closed customer",
+			status:    StatusClosed,
+			used:      eur(0),
+			amount:    eur(1),
+			wantErr:   true,
+			wantErrIs: ErrClosed,
+		},
+		{
+			name:      "should not reserve credit in another currency",
+			status:    StatusActive,
+			used:      eur(0),
+			amount:    sek,
+			wantErr:   true,
+			wantErrIs: order.ErrCurrencyMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			customerID, _ := order.NewCustomerID("0f8fad5b-d9cb-469f-a165-70867728950e")
+			c, _ := ReconstituteCustomer(customerID, "Jane Doe", tt.status, tt.blocked, eur(10000), tt.used, nil, 3)
+
+			// When
+			err := c.ReserveCredit(tt.amount)
+
+			// Then
+			if tt.wantErr {
+				require.Error(err)
+				if tt.wantErrIs != nil {
+					require.True(errors.Is(err, tt.wantErrIs), "expected %v to wrap %v", err, tt.wantErrIs)
+				}
+				require.Equal(tt.used, c.CreditUsed())
+				require.Equal(3, c.Version())
+				return
+			}
+			require.NoError(err)
+			require.Equal(tt.wantUsed, c.CreditUsed())
+			require.Equal(4, c.Version())
+		})
+	}
+}
+
+func TestCustomer(t *testing.T) {
+	// Given
+	require := require.New(t)
+	c, err := NewCustomer("Jane Doe", eur(10000))
+	require.NoError(err)
+
+	// When credit is reserved and released
+	require.NoError(c.ReserveCredit(eur(4000)))
+	require.NoError(c.ReleaseCredit(eur(1000)))
+
+	// Then
+	remaining, err := c.RemainingCredit()
+	require.NoError(err)
+	require.Equal(eur(7000), remaining)
+	require.Error(c.ReleaseCredit(eur(3001)), "more credit than used must not be released")
+
+	// When the credit limit is lowered below the credit used
+	require.NoError(c.ChangeCreditLimit(eur(2000)))
+
+	// Then no more credit can be reserved
+	require.True(errors.Is(c.ReserveCredit(eur(1)), ErrCreditLimitExceeded))
+
+	// When the customer is blocked and unblocked
+	c.Block()
+	require.True(c.IsBlocked())
+	c.Unblock()
+
+	// Then
+	require.False(c.IsBlocked())
+
+	// When the customer is closed with credit used
+	err = c.Close()
+
+	// Then
+	require.Error(err)
+	require.NoError(c.ReleaseCredit(eur(3000)))
+	require.NoError(c.Close())
+	require.Equal(StatusClosed, c.Status())
+
+	// When an address is added twice
+	address, err := NewAddress("Storgatan 1", "", "111 22", "Stockholm", "", "se")
+	require.NoError(err)
+	require.NoError(c.AddAddress(address))
+	require.NoError(c.AddAddress(address))
+
+	// Then it's only added once
+	require.Equal([]Address{address}, c.Addresses())
+	require.Equal("SE", c.Addresses()[0].Country)
+	require.Error(c.AddAddress(Address{Line1: "Storgatan 1"}))
+
+	_, err = NewCustomer("Jane Doe", order.Money{})
+	require.Error(err, "credit limit must have a currency")
+}
@@ -0,0 +1,141 @@
+package customer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+type (
+	// Tx is the database transaction the SQL repository runs in
+	Tx interface {
+		ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+		QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	}
+
+	Repository interface {
+		FindByID(ctx context.Context, id order.CustomerID) (*Customer, error)
+		Upsert(ctx context.Context, customer *Customer) error
+	}
+)
+
+const (
+	customerFindByID = "SELECT id, name, status, blocked, currency, credit_limit, credit_used, addresses, version FROM customers WHERE id = $1;"
+	customerInsert   = "INSERT INTO customers (id, name, status, blocked, currency, credit_limit, credit_used, addresses, version) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);"
+	customerUpdate   = "UPDATE customers SET name = $1, status = $2, blocked = $3, currency = $4, credit_limit = $5, credit_used = $6, addresses = $7, version = $8 WHERE id = $9 AND version = $10;"
+)
+
+// ErrNotFound is returned when there is no customer with the given id
+var ErrNotFound = apperror.New(apperror.CategoryNotFound, "CUSTOMER_NOT_FOUND", "customer not found")
+
+// repository implements the Repository interface by storing the current state of customers in the
+// customers table. The addresses are stored as JSON in the addresses column.
+type repository struct {
+	tx Tx
+}
+
+// NewRepository returns a repository that uses the transaction it's given, which means customers
+// are stored atomically with the orders they're changed for
+func NewRepository(tx Tx) Repository {
+	return &repository{tx: tx}
+}
+
+func (r *repository) FindByID(ctx context.Context, id order.CustomerID) (*Customer, error) {
+	var (
+		rowID          string
+		rowName        string
+		rowStatus      int
+		rowBlocked     bool
+		rowCurrency    string
+		rowCreditLimit int64
+		rowCreditUsed  int64
+		rowAddresses   []byte
+		rowVersion     int
+	)
+	err := r.tx.QueryRowContext(ctx, customerFindByID, string(id)).Scan(&rowID, &rowName, &rowStatus, &rowBlocked,
+		&rowCurrency, &rowCreditLimit, &rowCreditUsed, &rowAddresses, &rowVersion)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("could not find customer by id = %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not query customer by id = %s: %w", id, err)
+	}
+
+	var addresses []Address
+	if err = json.Unmarshal(rowAddresses, &addresses); err != nil {
+		return nil, fmt.Errorf("could not unmarshal addresses of customer by id = %s: %w", id, err)
+	}
+
+	// delegate creation/validation/business rules to factories/constructors
+	customerID, err := order.NewCustomerID(rowID)
+	if err != nil {
+		return nil, err
+	}
+	status, err := NewStatus(rowStatus)
+	if err != nil {
+		return nil, fmt.Errorf("customer by id = %s has an invalid status: %w", id, err)
+	}
+	currency, err := order.NewCurrency(rowCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("customer by id = %s has an invalid currency: %w", id, err)
+	}
+	creditLimit, err := order.NewMoney(rowCreditLimit, currency)
+	if err != nil {
+		return nil, err
+	}
+	creditUsed, err := order.NewMoney(rowCreditUsed, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return ReconstituteCustomer(customerID, rowName, status, rowBlocked, creditLimit, creditUsed, addresses, rowVersion)
+}
+
+// Upsert inserts new customers and updates existing ones. Updates only succeed if the customer is
+// still at the version it was loaded at, otherwise order.ErrConcurrencyConflict is returned so
+// the command that changed the customer can be retried like any other conflict.
+func (r *repository) Upsert(ctx context.Context, customer *Customer) error {
+	if customer.version == customer.loadedVersion {
+		return nil
+	}
+
+	addresses := customer.Addresses()
+	rowAddresses, err := json.Marshal(addresses)
+	if err != nil {
+		return fmt.Errorf("could not marshal addresses of customer = %s: %w", customer.id, err)
+	}
+
+	if customer.loadedVersion == 0 {
+		_, err = r.tx.ExecContext(ctx, customerInsert, string(customer.id), customer.name, int(customer.status), customer.blocked,
+			string(customer.creditLimit.Currency()), customer.creditLimit.Amount(), customer.creditUsed.Amount(),
+			string(rowAddresses), customer.version)
+		if err != nil {
+			return fmt.Errorf("could not insert customer = %s: %w", customer.id, err)
+		}
+
+		return nil
+	}
+
+	result, err := r.tx.ExecContext(ctx, customerUpdate, customer.name, int(customer.status), customer.blocked,
+		string(customer.creditLimit.Currency()), customer.creditLimit.Amount(), customer.creditUsed.Amount(),
+		string(rowAddresses), customer.version, string(customer.id), customer.loadedVersion)
+	if err != nil {
+		return fmt.Errorf("could not update customer = %s: %w", customer.id, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not update customer = %s: %w", customer.id, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("customer = %s is no longer at version = %d: %w", customer.id, customer.loadedVersion, order.ErrConcurrencyConflict)
+	}
+
+	return nil
+}
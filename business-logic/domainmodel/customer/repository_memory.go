@@ -0,0 +1,57 @@
+package customer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+// memoryRepository implements the Repository interface in memory. It stores copies of customers,
+// so changes made to a customer aren't visible to others until it's upserted.
+type memoryRepository struct {
+	mu        sync.Mutex
+	customers map[order.CustomerID]Customer
+}
+
+func NewMemoryRepository(customers ...*Customer) Repository {
+	r := &memoryRepository{customers: make(map[order.CustomerID]Customer)}
+	for _, customer := range customers {
+		r.store(customer)
+	}
+
+	return r
+}
+
+func (r *memoryRepository) FindByID(ctx context.Context, id order.CustomerID) (*Customer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.customers[id]
+	if !ok {
+		return nil, fmt.Errorf("could not find customer by id = %s: %w", id, ErrNotFound)
+	}
+	stored.addresses = stored.Addresses()
+
+	return &stored, nil
+}
+
+func (r *memoryRepository) Upsert(ctx context.Context, customer *Customer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stored, ok := r.customers[customer.id]; ok && stored.version != customer.loadedVersion {
+		return fmt.Errorf("customer = %s is no longer at version = %d: %w", customer.id, customer.loadedVersion, order.ErrConcurrencyConflict)
+	}
+	r.store(customer)
+
+	return nil
+}
+
+func (r *memoryRepository) store(customer *Customer) {
+	stored := *customer
+	stored.addresses = customer.Addresses()
+	stored.loadedVersion = stored.version
+	r.customers[customer.id] = stored
+}
@@ -0,0 +1,99 @@
+package customer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/migrations"
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "customer.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	runner, err := migrations.NewRunner(db, migrations.SQLite)
+	require.NoError(t, err)
+	require.NoError(t, runner.Up(context.Background()))
+
+	return db
+}
+
+// beginTestTx begins a transaction that is rolled back when the test ends
+func beginTestTx(t *testing.T, db *sql.DB) *sql.Tx {
+	t.Helper()
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	t.Cleanup(func() { tx.Rollback() })
+
+	return tx
+}
+
+func TestRepository(t *testing.T) {
+	repositories := map[string]func(t *testing.T) Repository{
+		"memory": func(t *testing.T) Repository { return NewMemoryRepository() },
+		"sql":    func(t *testing.T) Repository { return NewRepository(beginTestTx(t, openTestDB(t))) },
+	}
+	for name, newRepository := range repositories {
+		t.Run(name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			ctx := context.Background()
+			repo := newRepository(t)
+			c, err := NewCustomer("Jane Doe", eur(10000))
+			require.NoError(err)
+			address, err := NewAddress("Main St 1", "", "94016", "San Francisco", "ca", "US")
+			require.NoError(err)
+			require.NoError(c.AddAddress(address))
+
+			// When a new customer is upserted
+			require.NoError(repo.Upsert(ctx, c))
+
+			// Then it's inserted
+			found, err := repo.FindByID(ctx, c.ID())
+			require.NoError(err)
+			require.Equal("Jane Doe", found.Name())
+			require.Equal(StatusActive, found.Status())
+			require.False(found.IsBlocked())
+			require.Equal(eur(10000), found.CreditLimit())
+			require.Equal(eur(0), found.CreditUsed())
+			require.Equal([]Address{address}, found.Addresses())
+			require.Equal(2, found.Version())
+
+			stale, err := repo.FindByID(ctx, c.ID())
+			require.NoError(err)
+
+			// When it's changed
+			require.NoError(found.ReserveCredit(eur(2500)))
+			found.Block()
+			require.NoError(repo.Upsert(ctx, found))
+
+			// Then it's updated
+			updated, err := repo.FindByID(ctx, c.ID())
+			require.NoError(err)
+			require.Equal(eur(2500), updated.CreditUsed())
+			require.True(updated.IsBlocked())
+			require.Equal(4, updated.Version())
+
+			// When a stale copy is upserted
+			require.NoError(stale.ReserveCredit(eur(1)))
+			err = repo.Upsert(ctx, stale)
+
+			// Then
+			require.True(errors.Is(err, order.ErrConcurrencyConflict), "expected %v to wrap %v", err, order.ErrConcurrencyConflict)
+
+			// When the customer doesn't exist
+			_, err = repo.FindByID(ctx, order.CustomerID("7c9e6679-7425-40de-944b-e07fc1f90ae7"))
+
+			// Then
+			require.True(errors.Is(err, ErrNotFound), "expected %v to wrap %v", err, ErrNotFound)
+		})
+	}
+}
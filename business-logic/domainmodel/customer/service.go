@@ -0,0 +1,49 @@
+package customer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+// Service is a domain service that decides whether customers may submit orders. It's a domain
+// service since the decision involves two aggregates, the order and its customer.
+type Service struct {
+	customers Repository
+}
+
+func NewService(customers Repository) *Service {
+	return &Service{customers: customers}
+}
+
+// ReserveCredit charges the total of the order against the credit of its customer, which is
+// refused if the customer is blocked or closed or if the total exceeds the remaining credit. It's
+// meant to be called when the order is submitted, in the same transaction.
+func (s *Service) ReserveCredit(ctx context.Context, o *order.Order) error {
+	return s.changeCredit(ctx, o, (*Customer).ReserveCredit)
+}
+
+// ReleaseCredit gives back the credit reserved for the order when it was submitted. It's meant to
+// be called when a submitted order is paid or cancelled, in the same transaction.
+func (s *Service) ReleaseCredit(ctx context.Context, o *order.Order) error {
+	return s.changeCredit(ctx, o, (*Customer).ReleaseCredit)
+}
+
+func (s *Service) changeCredit(ctx context.Context, o *order.Order, command func(c *Customer, amount order.Money) error) error {
+	total, err := o.Total()
+	if err != nil {
+		return err
+	}
+
+	customer, err := s.customers.FindByID(ctx, o.CustomerID())
+	if err != nil {
+		return err
+	}
+
+	if err = command(customer, total); err != nil {
+		return fmt.Errorf("could not change credit for order = %s: %w", o.ID(), err)
+	}
+
+	return s.customers.Upsert(ctx, customer)
+}
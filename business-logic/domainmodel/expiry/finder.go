@@ -0,0 +1,63 @@
+package expiry
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+const (
+	ordersSelectStale    = "SELECT id FROM orders WHERE state = $1 AND created_at < $2 ORDER BY created_at LIMIT $3;"
+	summariesSelectStale = "SELECT order_id FROM order_summaries WHERE state = $1 AND created_at < $2 ORDER BY created_at LIMIT $3;"
+)
+
+// Finder finds the ids of orders that have been pending since before the given time
+type Finder interface {
+	FindPending(ctx context.Context, createdBefore time.Time, limit int) ([]string, error)
+}
+
+// FinderFunc lets ordinary functions be used as finders
+type FinderFunc func(ctx context.Context, createdBefore time.Time, limit int) ([]string, error)
+
+func (f FinderFunc) FindPending(ctx context.Context, createdBefore time.Time, limit int) ([]string, error) {
+	return f(ctx, createdBefore, limit)
+}
+
+// NewOrdersFinder returns a finder that finds orders in the orders table, which is where orders
+// are stored unless they're stored with event sourcing
+func NewOrdersFinder(db *sql.DB) Finder {
+	return FinderFunc(func(ctx context.Context, createdBefore time.Time, limit int) ([]string, error) {
+		return queryIDs(ctx, db, ordersSelectStale, int(order.OrderStatePending), createdBefore.UTC().Format(order.CreatedAtLayout), limit)
+	})
+}
+
+// NewSummariesFinder returns a finder that finds orders in the order_summaries read model, which
+// is where orders stored with event sourcing can be found. The read model lags behind, so some of
+// the orders found may no longer be pending.
+func NewSummariesFinder(db *sql.DB) Finder {
+	return FinderFunc(func(ctx context.Context, createdBefore time.Time, limit int) ([]string, error) {
+		return queryIDs(ctx, db, summariesSelectStale, order.OrderStatePending.String(), createdBefore.UTC().Format(order.CreatedAtLayout), limit)
+	})
+}
+
+func queryIDs(ctx context.Context, db *sql.DB, query string, args ...any) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not query pending orders: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("could not scan pending order: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
@@ -0,0 +1,54 @@
+package expiry
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	// leaseAcquire takes the lease if it's free, expired or already held by the owner, in which
+	// case it's renewed. Nothing is changed if someone else holds it.
+	leaseAcquire = "INSERT INTO scheduler_leases (name, owner, expires_at) VALUES ($1, $2, $3) ON CONFLICT (name) DO UPDATE SET owner = excluded.owner, expires_at = excluded.expires_at WHERE scheduler_leases.owner = excluded.owner OR scheduler_leases.expires_at <= $4;"
+	leaseRelease = "DELETE FROM scheduler_leases WHERE name = $1 AND owner = $2;"
+
+	// leaseTimestampLayout has a fixed width, so timestamps in UTC can be compared as text
+	leaseTimestampLayout = "2006-01-02T15:04:05.000000000Z07:00"
+)
+
+// lease makes sure only one instance at a time does the work it's named after. It expires after
+// its duration, so the work is taken over by another instance if its holder crashes.
+type lease struct {
+	db       *sql.DB
+	name     string
+	owner    string
+	duration time.Duration
+	now      func() time.Time
+}
+
+// acquire takes or renews the lease and returns whether the owner holds it
+func (l *lease) acquire(ctx context.Context) (bool, error) {
+	at := l.now().UTC()
+	result, err := l.db.ExecContext(ctx, leaseAcquire, l.name, l.owner,
+		at.Add(l.duration).Format(leaseTimestampLayout), at.Format(leaseTimestampLayout))
+	if err != nil {
+		return false, fmt.Errorf("could not acquire lease = %s: %w", l.name, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("could not acquire lease = %s: %w", l.name, err)
+	}
+
+	return affected == 1, nil
+}
+
+// release gives up the lease so another instance can take it without waiting for it to expire
+func (l *lease) release(ctx context.Context) error {
+	if _, err := l.db.ExecContext(ctx, leaseRelease, l.name, l.owner); err != nil {
+		return fmt.Errorf("could not release lease = %s: %w", l.name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+/*
+Package expiry cancels orders that have been pending for too long. A Scheduler sweeps the orders
+every interval and cancels the ones older than its time to live with the order.Cancel aggregate
+command, giving order.CancelReasonOrderExpired as the reason.
+
+Any number of instances may run a scheduler against the same database. Only the instance holding
+the sweep lease sweeps, the others skip their turn. The lease expires if it isn't renewed, so
+another instance takes over if the one holding it crashes. Sweeping twice is harmless anyway,
+since orders are only cancelled while they're still pending.
+*/
+package expiry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	LeaseName = "order-expiry"
+
+	defaultBatchSize     = 100
+	defaultLeaseDuration = time.Minute
+)
+
+// ErrNotLeader is returned by Sweep when another instance holds the sweep lease
+var ErrNotLeader = errors.New("sweep lease is held by another instance")
+
+type orderExpirer interface {
+	// ExpireOrder cancels the order if it's still pending, see domainmodel.Service.ExpireOrder
+	ExpireOrder(ctx context.Context, id string) error
+}
+
+// Scheduler cancels orders that have been pending for longer than its time to live
+type Scheduler struct {
+	finder    Finder
+	orders    orderExpirer
+	ttl       time.Duration
+	batchSize int
+	lease     *lease
+	now       func() time.Time
+	onError   func(ctx context.Context, err error)
+}
+
+type SchedulerOption func(s *Scheduler)
+
+// WithClock makes the scheduler tell the time with now instead of time.Now
+func WithClock(now func() time.Time) SchedulerOption {
+	return func(s *Scheduler) {
+		s.now = now
+	}
+}
+
+// WithOwner sets the name the scheduler holds the sweep lease under, which is random by default
+func WithOwner(owner string) SchedulerOption {
+	return func(s *Scheduler) {
+		s.lease.owner = owner
+	}
+}
+
+// WithLeaseDuration sets how long the sweep lease is held before it must be renewed. It should be
+// longer than it takes to expire a batch of orders.
+func WithLeaseDuration(duration time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		s.lease.duration = duration
+	}
+}
+
+// WithBatchSize sets how many orders are expired between renewals of the sweep lease
+func WithBatchSize(batchSize int) SchedulerOption {
+	return func(s *Scheduler) {
+		s.batchSize = batchSize
+	}
+}
+
+// WithErrorHandler makes the scheduler report the orders it can't expire, and the sweeps that
+// fail when it runs, to onError instead of logging them
+func WithErrorHandler(onError func(ctx context.Context, err error)) SchedulerOption {
+	return func(s *Scheduler) {
+		s.onError = onError
+	}
+}
+
+func NewScheduler(db *sql.DB, finder Finder, orders orderExpirer, ttl time.Duration, opts ...SchedulerOption) *Scheduler {
+	s := &Scheduler{
+		finder:    finder,
+		orders:    orders,
+		ttl:       ttl,
+		batchSize: defaultBatchSize,
+		lease:     &lease{db: db, name: LeaseName, owner: uuid.NewString(), duration: defaultLeaseDuration},
+		now:       time.Now,
+		onError:   func(ctx context.Context, err error) { log.Printf("order expiry: %v", err) },
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.lease.now = s.now
+
+	return s
+}
+
+// Run sweeps the orders every interval until ctx is done. Turns where another instance holds the
+// sweep lease are skipped, and sweeps that fail are reported to the error handler and tried again
+// the next turn.
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Sweep(ctx); err != nil && !errors.Is(err, ErrNotLeader) && ctx.Err() == nil {
+			s.onError(ctx, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep cancels the orders that have been pending for longer than the time to live and returns
+// how many it expired. Orders that can't be expired are reported to the error handler and skipped,
+// so they don't hold back the others. They're found again by the next sweep.
+func (s *Scheduler) Sweep(ctx context.Context) (expired int, err error) {
+	acquired, err := s.lease.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if !acquired {
+		return 0, ErrNotLeader
+	}
+	defer func() {
+		if releaseErr := s.lease.release(ctx); releaseErr != nil && err == nil {
+			err = releaseErr
+		}
+	}()
+
+	// orders found by a finder that lags behind may still be found after they're expired, and
+	// orders that failed are found again, so the batches are made larger to get past them
+	seen := make(map[string]bool)
+	var failed int
+	for {
+		limit := s.batchSize + failed
+		ids, err := s.finder.FindPending(ctx, s.now().Add(-s.ttl), limit)
+		if err != nil {
+			return expired, err
+		}
+
+		var found int
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			found++
+
+			if err = s.orders.ExpireOrder(ctx, id); err != nil {
+				s.onError(ctx, fmt.Errorf("could not expire order by id = %s: %w", id, err))
+				failed++
+				continue
+			}
+			expired++
+		}
+
+		if len(ids) < limit || found == 0 {
+			return expired, nil
+		}
+
+		// the lease is renewed between batches, so it's lost if the sweep takes too long
+		if acquired, err = s.lease.acquire(ctx); err != nil {
+			return expired, err
+		}
+		if !acquired {
+			return expired, ErrNotLeader
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package expiry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/migrations"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/sqltx"
+	_ "modernc.org/sqlite"
+)
+
+// fakeClock is a clock that only moves when it's told to
+type fakeClock struct {
+	mu sync.Mutex
+	at time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.at
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.at = c.at.Add(d)
+}
+
+type eventPublisherMock struct {
+	mu        sync.Mutex
+	published []order.Event
+}
+
+func (m *eventPublisherMock) Publish(ctx context.Context, msg order.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.published = append(m.published, msg)
+
+	return nil
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "expiry.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	runner, err := migrations.NewRunner(db, migrations.SQLite)
+	require.NoError(t, err)
+	require.NoError(t, runner.Up(context.Background()))
+
+	return db
+}
+
+// storeOrder stores a new order in the orders table, after running the commands against it
+func storeOrder(t *testing.T, db *sql.DB, commands ...func(o *order.Order) error) *order.Order {
+	t.Helper()
+	unitPrice, _ := order.NewMoney(1000, "EUR")
+	o, err := order.NewOrder("0f8fad5b-d9cb-469f-a165-70867728950e", []order.OrderItem{{ArticleNo: "A1", Quantity: 1, UnitPrice: unitPrice}}, order.OrderStatePending)
+	require.NoError(t, err)
+	for _, command := range commands {
+		require.NoError(t, command(o))
+	}
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, order.NewRepository(tx).Upsert(context.Background(), o))
+	require.NoError(t, tx.Commit())
+
+	return o
+}
+
+func findOrder(t *testing.T, db *sql.DB, id order.ID) *order.Order {
+	t.Helper()
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	o, err := order.NewRepository(tx).FindByID(context.Background(), string(id))
+	require.NoError(t, err)
+
+	return o
+}
+
+func TestScheduler_Sweep(t *testing.T) {
+	// Given
+	require := require.New(t)
+	ctx := context.Background()
+	db := openTestDB(t)
+	clock := &fakeClock{at: time.Now()}
+	publisher := &eventPublisherMock{}
+	svc := domainmodel.NewService(order.NewTxMaker(sqltx.NewBeginner(db)), publisher)
+	scheduler := NewScheduler(db, NewOrdersFinder(db), svc, time.Hour, WithClock(clock.Now), WithBatchSize(1))
+
+	stale := []*order.Order{storeOrder(t, db), storeOrder(t, db)}
+	submitted := storeOrder(t, db, (*order.Order).Submit)
+
+	// When the orders aren't older than the time to live
+	clock.Advance(30 * time.Minute)
+	expired, err := scheduler.Sweep(ctx)
+
+	// Then none of them are expired
+	require.NoError(err)
+	require.Zero(expired)
+	require.Empty(publisher.published)
+
+	// When the pending orders are older than the time to live
+	clock.Advance(time.Hour)
+	expired, err = scheduler.Sweep(ctx)
+
+	// Then they're cancelled
+	require.NoError(err)
+	require.Equal(2, expired)
+	for _, o := range stale {
+		require.Equal(order.OrderStateCancelled, findOrder(t, db, o.ID()).State())
+	}
+	require.Equal(order.OrderStateSubmitted, findOrder(t, db, submitted.ID()).State())
+
+	require.Len(publisher.published, 2)
+	for _, e := range publisher.published {
+		require.Equal(order.OrderCancelled{Reason: order.CancelReasonOrderExpired}, e.Payload())
+	}
+
+	// When the orders are swept again
+	expired, err = scheduler.Sweep(ctx)
+
+	// Then there's nothing left to expire
+	require.NoError(err)
+	require.Zero(expired)
+}
+
+// failingExpirer fails to expire the orders with the given ids
+type failingExpirer struct {
+	orderExpirer
+	failing map[string]bool
+}
+
+func (e failingExpirer) ExpireOrder(ctx context.Context, id string) error {
+	if e.failing[id] {
+		return errAny
+	}
+	return e.orderExpirer.ExpireOrder(ctx, id)
+}
+
+var errAny = errors.New("any error")
+
+func TestScheduler_Sweep_Failures(t *testing.T) {
+	// Given an order that can't be expired, which is older than the others
+	require := require.New(t)
+	ctx := context.Background()
+	db := openTestDB(t)
+	clock := &fakeClock{at: time.Now()}
+	svc := domainmodel.NewService(order.NewTxMaker(sqltx.NewBeginner(db)), &eventPublisherMock{})
+	failing := storeOrder(t, db)
+	clock.Advance(time.Second)
+	stale := []*order.Order{storeOrder(t, db), storeOrder(t, db)}
+	var reported []error
+	scheduler := NewScheduler(db, NewOrdersFinder(db), failingExpirer{orderExpirer: svc, failing: map[string]bool{string(failing.ID()): true}},
+		time.Hour, WithClock(clock.Now), WithBatchSize(1), WithErrorHandler(func(ctx context.Context, err error) {
+			reported = append(reported, err)
+		}))
+
+	// When
+	clock.Advance(2 * time.Hour)
+	expired, err := scheduler.Sweep(ctx)
+
+	// Then the failure is reported and the other orders are expired anyway
+	require.NoError(err)
+	require.Equal(2, expired)
+	for _, o := range stale {
+		require.Equal(order.OrderStateCancelled, findOrder(t, db, o.ID()).State())
+	}
+	require.Equal(order.OrderStatePending, findOrder(t, db, failing.ID()).State())
+	require.Len(reported, 1)
+	require.True(errors.Is(reported[0], errAny), "expected %v to wrap %v", reported[0], errAny)
+	require.Contains(reported[0].Error(), string(failing.ID()))
+}
+
+func TestScheduler_Run(t *testing.T) {
+	// Given a finder that fails once
+	require := require.New(t)
+	db := openTestDB(t)
+	var (
+		mu       sync.Mutex
+		sweeps   int
+		reported []error
+	)
+	finder := FinderFunc(func(ctx context.Context, createdBefore time.Time, limit int) ([]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		sweeps++
+		if sweeps == 1 {
+			return nil, errAny
+		}
+		return nil, nil
+	})
+	svc := domainmodel.NewService(order.NewTxMaker(sqltx.NewBeginner(db)), &eventPublisherMock{})
+	scheduler := NewScheduler(db, finder, svc, time.Hour, WithErrorHandler(func(ctx context.Context, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, err)
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	// When
+	go func() { done <- scheduler.Run(ctx, time.Millisecond) }()
+
+	// Then the failed sweep is reported and the scheduler keeps sweeping
+	require.Eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return sweeps >= 3
+	}, 5*time.Second, time.Millisecond)
+	cancel()
+	require.NoError(<-done)
+	require.Len(reported, 1)
+	require.True(errors.Is(reported[0], errAny), "expected %v to wrap %v", reported[0], errAny)
+}
+
+func TestScheduler_Lease(t *testing.T) {
+	// Given
+	require := require.New(t)
+	ctx := context.Background()
+	db := openTestDB(t)
+	clock := &fakeClock{at: time.Now()}
+	expirer := domainmodel.NewService(order.NewTxMaker(sqltx.NewBeginner(db)), &eventPublisherMock{})
+	other := NewScheduler(db, NewOrdersFinder(db), expirer, time.Hour, WithClock(clock.Now), WithOwner("other"))
+
+	var otherErr error
+	finder := FinderFunc(func(ctx context.Context, createdBefore time.Time, limit int) ([]string, error) {
+		_, otherErr = other.Sweep(ctx)
+		return nil, nil
+	})
+	scheduler := NewScheduler(db, finder, expirer, time.Hour, WithClock(clock.Now), WithOwner("leader"),
+		WithLeaseDuration(time.Minute))
+
+	// When another instance sweeps while the scheduler sweeps
+	_, err := scheduler.Sweep(ctx)
+
+	// Then only the scheduler sweeps
+	require.NoError(err)
+	require.True(errors.Is(otherErr, ErrNotLeader), "expected %v to wrap %v", otherErr, ErrNotLeader)
+
+	// And the lease is released afterwards
+	_, err = other.Sweep(ctx)
+	require.NoError(err)
+
+	// When the holder of the lease crashes without releasing it
+	crashed := &lease{db: db, name: LeaseName, owner: "crashed", duration: time.Minute, now: clock.Now}
+	acquired, err := crashed.acquire(ctx)
+	require.NoError(err)
+	require.True(acquired)
+	_, err = other.Sweep(ctx)
+
+	// Then no one else sweeps until the lease expires
+	require.True(errors.Is(err, ErrNotLeader), "expected %v to wrap %v", err, ErrNotLeader)
+
+	clock.Advance(time.Minute)
+	_, err = other.Sweep(ctx)
+	require.NoError(err)
+}
+
+func TestSummariesFinder(t *testing.T) {
+	// Given
+	require := require.New(t)
+	ctx := context.Background()
+	db := openTestDB(t)
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for id, state := range map[string]string{"stale": "pending", "submitted": "submitted"} {
+		_, err := db.Exec("INSERT INTO order_summaries (order_id, customer_id, state, item_count, currency, total, created_at, updated_at) VALUES ($1, 'customer-id', $2, 1, 'EUR', 1000, $3, $3);",
+			id, state, createdAt.Format(order.CreatedAtLayout))
+		require.NoError(err)
+	}
+	finder := NewSummariesFinder(db)
+
+	// When
+	before, err := finder.FindPending(ctx, createdAt, 10)
+	require.NoError(err)
+	after, err := finder.FindPending(ctx, createdAt.Add(500*time.Millisecond), 10)
+	require.NoError(err)
+
+	// Then
+	require.Empty(before)
+	require.Equal([]string{"stale"}, after)
+}
@@ -0,0 +1,308 @@
+package domainmodel
+
+import (
+	"context"
+	"time"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/orderpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Metadata keys of the gRPC counterparts of HeaderCorrelationID and HeaderRequestID
+const (
+	MetadataCorrelationID = "x-correlation-id"
+	MetadataRequestID     = "x-request-id"
+)
+
+const (
+	defaultPollInterval = time.Second
+	streamBatchSize     = 100
+)
+
+type eventStreamingAppService interface {
+	orderAppService
+	ReadEvents(ctx context.Context, afterPosition int64, limit int) ([]order.RecordedEvent, error)
+}
+
+// OrderServer exposes the order application service over gRPC. It shares the application service
+// with the OrderController, so both APIs run the same business logic.
+type OrderServer struct {
+	orderpb.UnimplementedOrderServiceServer
+
+	orderAppSvc  eventStreamingAppService
+	orderQuerier orderQuerier
+	pollInterval time.Duration
+}
+
+type OrderServerOption func(s *OrderServer)
+
+// WithPollInterval sets how often followed event streams check for new events
+func WithPollInterval(interval time.Duration) OrderServerOption {
+	return func(s *OrderServer) {
+		s.pollInterval = interval
+	}
+}
+
+// NewOrderServer returns an OrderServer. The orders of customers are listed from the read model
+// queried by orderQuerier, e.g. a projection.QueryService.
+func NewOrderServer(orderAppService eventStreamingAppService, orderQuerier orderQuerier, opts ...OrderServerOption) *OrderServer {
+	s := &OrderServer{
+		orderAppSvc:  orderAppService,
+		orderQuerier: orderQuerier,
+		pollInterval: defaultPollInterval,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// NewGRPCServer returns a gRPC server with the order server registered on it. The errors returned
+// by the order server are mapped to gRPC statuses by the apperror interceptors.
+func NewGRPCServer(orderServer *OrderServer, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(apperror.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(apperror.StreamServerInterceptor()),
+	)
+
+	server := grpc.NewServer(opts...)
+	orderpb.RegisterOrderServiceServer(server, orderServer)
+
+	return server
+}
+
+func (s *OrderServer) CreateOrder(ctx context.Context, req *orderpb.CreateOrderRequest) (*orderpb.Order, error) {
+	if _, err := order.NewCustomerID(req.GetCustomerId()); err != nil {
+		return nil, err
+	}
+
+	items := make([]order.OrderItem, 0, len(req.GetItems()))
+	for _, itemMsg := range req.GetItems() {
+		item, err := toOrderItem(OrderItemRequestModel{
+			ArticleNo: itemMsg.GetArticleNo(),
+			Quantity:  int(itemMsg.GetQuantity()),
+			UnitPrice: MoneyModel{Amount: itemMsg.GetUnitPrice().GetAmount(), Currency: itemMsg.GetUnitPrice().GetCurrency()},
+		})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	id, err := s.orderAppSvc.CreateOrder(tracedGRPCContext(ctx), req.GetCustomerId(), items)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getOrder(ctx, id)
+}
+
+func (s *OrderServer) GetOrder(ctx context.Context, req *orderpb.GetOrderRequest) (*orderpb.Order, error) {
+	id, err := order.NewID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getOrder(ctx, id)
+}
+
+func (s *OrderServer) SubmitOrder(ctx context.Context, req *orderpb.SubmitOrderRequest) (*orderpb.Order, error) {
+	id, err := order.NewID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.orderAppSvc.SubmitOrder(tracedGRPCContext(ctx), string(id)); err != nil {
+		return nil, err
+	}
+
+	return s.getOrder(ctx, id)
+}
+
+func (s *OrderServer) CancelOrder(ctx context.Context, req *orderpb.CancelOrderRequest) (*orderpb.Order, error) {
+	id, err := order.NewID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.orderAppSvc.CancelOrder(tracedGRPCContext(ctx), string(id), req.GetReason()); err != nil {
+		return nil, err
+	}
+
+	return s.getOrder(ctx, id)
+}
+
+func (s *OrderServer) ListCustomerOrders(ctx context.Context, req *orderpb.ListCustomerOrdersRequest) (*orderpb.ListCustomerOrdersResponse, error) {
+	if _, err := order.NewCustomerID(req.GetCustomerId()); err != nil {
+		return nil, err
+	}
+
+	summaries, err := s.orderQuerier.OrdersByCustomer(ctx, req.GetCustomerId())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &orderpb.ListCustomerOrdersResponse{Orders: make([]*orderpb.OrderSummary, 0, len(summaries))}
+	for _, summary := range summaries {
+		resp.Orders = append(resp.Orders, &orderpb.OrderSummary{
+			OrderId:   summary.OrderID,
+			State:     summary.State,
+			ItemCount: int32(summary.ItemCount),
+			Total:     toMoneyMessage(toMoneyModel(summary.Total)),
+		})
+	}
+
+	return resp, nil
+}
+
+// StreamOrderEvents sends the stored events after the requested position in batches. Followed
+// streams poll for new events until the client cancels them.
+func (s *OrderServer) StreamOrderEvents(req *orderpb.StreamOrderEventsRequest, stream orderpb.OrderService_StreamOrderEventsServer) error {
+	if req.GetOrderId() != "" {
+		if _, err := order.NewID(req.GetOrderId()); err != nil {
+			return err
+		}
+	}
+
+	ctx := stream.Context()
+	position := req.GetAfterPosition()
+	for {
+		events, err := s.orderAppSvc.ReadEvents(ctx, position, streamBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, recorded := range events {
+			position = recorded.Position
+			if req.GetOrderId() != "" && string(recorded.Event.AggregateID()) != req.GetOrderId() {
+				continue
+			}
+
+			msg, err := toOrderEventMessage(recorded)
+			if err != nil {
+				return err
+			}
+
+			if err = stream.Send(msg); err != nil {
+				return err
+			}
+		}
+
+		// there may be more events to catch up on if the batch was full
+		if len(events) == streamBatchSize {
+			continue
+		}
+
+		if !req.GetFollow() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
+
+func (s *OrderServer) getOrder(ctx context.Context, id order.ID) (*orderpb.Order, error) {
+	o, err := s.orderAppSvc.GetOrder(ctx, string(id))
+	if err != nil {
+		return nil, err
+	}
+
+	respModel, err := toOrderResponseModel(o)
+	if err != nil {
+		return nil, err
+	}
+
+	return toOrderMessage(respModel), nil
+}
+
+// toOrderMessage maps the HTTP representation of an order to its protobuf message, so that both
+// APIs represent orders the same way
+func toOrderMessage(respModel OrderResponseModel) *orderpb.Order {
+	msg := &orderpb.Order{
+		Id:          respModel.ID,
+		CustomerId:  respModel.CustomerID,
+		State:       respModel.State,
+		Subtotal:    toMoneyMessage(respModel.Subtotal),
+		ShippingFee: toMoneyMessage(respModel.ShippingFee),
+		Total:       toMoneyMessage(respModel.Total),
+		Version:     int32(respModel.Version),
+	}
+
+	for _, item := range respModel.Items {
+		msg.Items = append(msg.Items, &orderpb.OrderItem{
+			ArticleNo: item.ArticleNo,
+			Quantity:  int32(item.Quantity),
+			UnitPrice: toMoneyMessage(item.UnitPrice),
+			LineTotal: toMoneyMessage(item.LineTotal),
+		})
+	}
+
+	for _, discount := range respModel.Discounts {
+		msg.Discounts = append(msg.Discounts, &orderpb.Discount{
+			PromotionId: discount.PromotionID,
+			Description: discount.Description,
+			Target:      discount.Target,
+			ArticleNo:   discount.ArticleNo,
+			Amount:      toMoneyMessage(discount.Amount),
+		})
+	}
+
+	if respModel.Tax != nil {
+		msg.Tax = &orderpb.Tax{
+			Jurisdiction: respModel.Tax.Jurisdiction,
+			Inclusive:    respModel.Tax.Inclusive,
+			Total:        toMoneyMessage(respModel.Tax.Total),
+		}
+	}
+
+	return msg
+}
+
+func toMoneyMessage(m MoneyModel) *orderpb.Money {
+	return &orderpb.Money{Amount: m.Amount, Currency: m.Currency}
+}
+
+func toOrderEventMessage(recorded order.RecordedEvent) (*orderpb.OrderEvent, error) {
+	data, err := order.MarshalEvent(recorded.Event)
+	if err != nil {
+		return nil, err
+	}
+
+	return &orderpb.OrderEvent{
+		Position:      recorded.Position,
+		EventId:       recorded.Event.EventID(),
+		EventType:     recorded.Event.EventType(),
+		OrderId:       string(recorded.Event.AggregateID()),
+		OrderVersion:  int32(recorded.Event.AggregateVersion()),
+		OccurredAt:    timestamppb.New(recorded.Event.OccurredAt()),
+		CorrelationId: recorded.Event.CorrelationID(),
+		CausationId:   recorded.Event.CausationID(),
+		Data:          data,
+	}, nil
+}
+
+// tracedGRPCContext ties the domain events raised while handling the call to the call, like
+// tracedContext does for HTTP requests
+func tracedGRPCContext(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+
+		return ""
+	}
+
+	return order.ContextWithMetadata(ctx, newEventMetadata(first(MetadataCorrelationID), first(MetadataRequestID)))
+}
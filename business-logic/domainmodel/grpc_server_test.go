@@ -0,0 +1,242 @@
+package domainmodel
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/orderpb"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/projection"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/sqltx"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const grpcCustomerID = "0f8fad5b-d9cb-469f-a165-70867728950e"
+
+// newTestOrderClient serves an OrderServer backed by an event sourced application service over an
+// in-process listener
+func newTestOrderClient(t *testing.T) (orderpb.OrderServiceClient, *sql.DB) {
+	t.Helper()
+	db := newTestDB(t)
+	// followed event streams read while commands write, which sqlite only allows on one connection
+	db.SetMaxOpenConns(1)
+
+	svc := NewService(order.NewTxMaker(sqltx.NewBeginner(db)), &eventPublisherMock{}, WithEventSourcing(0))
+	server := NewGRPCServer(NewOrderServer(svc, projection.NewQueryService(db), WithPollInterval(10*time.Millisecond)))
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return orderpb.NewOrderServiceClient(conn), db
+}
+
+func createTestOrder(t *testing.T, ctx context.Context, client orderpb.OrderServiceClient) *orderpb.Order {
+	t.Helper()
+	created, err := client.CreateOrder(ctx, &orderpb.CreateOrderRequest{
+		CustomerId: grpcCustomerID,
+		Items: []*orderpb.OrderItem{
+			{ArticleNo: "A1", Quantity: 2, UnitPrice: &orderpb.Money{Amount: "12.50", Currency: "EUR"}},
+		},
+	})
+	require.NoError(t, err)
+
+	return created
+}
+
+func TestOrderServer_Commands(t *testing.T) {
+	// Given
+	require := require.New(t)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), MetadataCorrelationID, "correlation-1")
+	client, db := newTestOrderClient(t)
+
+	// When an order is created
+	created := createTestOrder(t, ctx, client)
+
+	// Then
+	require.Equal("pending", created.State)
+	require.Equal(grpcCustomerID, created.CustomerId)
+	require.Len(created.Items, 1)
+	require.Equal("25.00", created.Items[0].LineTotal.Amount)
+	require.Equal(&orderpb.Money{Amount: "25.00", Currency: "EUR"}, created.Total)
+
+	// When it's submitted and cancelled
+	submitted, err := client.SubmitOrder(ctx, &orderpb.SubmitOrderRequest{Id: created.Id})
+	require.NoError(err)
+	require.Equal("submitted", submitted.State)
+
+	cancelled, err := client.CancelOrder(ctx, &orderpb.CancelOrderRequest{Id: created.Id, Reason: "changed my mind"})
+	require.NoError(err)
+	require.Equal("cancelled", cancelled.State)
+
+	// Then it's returned in its latest state
+	got, err := client.GetOrder(ctx, &orderpb.GetOrderRequest{Id: created.Id})
+	require.NoError(err)
+	require.Equal("cancelled", got.State)
+	require.Equal(int32(3), got.Version)
+
+	// When the orders of the customer are listed
+	require.NoError(projection.NewRunner(db, projection.OrderSummaries{}).CatchUp(ctx))
+	list, err := client.ListCustomerOrders(ctx, &orderpb.ListCustomerOrdersRequest{CustomerId: grpcCustomerID})
+
+	// Then
+	require.NoError(err)
+	require.Len(list.Orders, 1)
+	require.Equal(created.Id, list.Orders[0].OrderId)
+	require.Equal("cancelled", list.Orders[0].State)
+	require.Equal(int32(2), list.Orders[0].ItemCount)
+}
+
+func TestOrderServer_Errors(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestOrderClient(t)
+	cancelled := createTestOrder(t, ctx, client)
+	_, err := client.CancelOrder(ctx, &orderpb.CancelOrderRequest{Id: cancelled.Id, Reason: "changed my mind"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		call       func() error
+		wantCode   codes.Code
+		wantReason string
+	}{
+		{
+			name: "should not find unknown order",
+			call: func() error {
+				_, err := client.GetOrder(ctx, &orderpb.GetOrderRequest{Id: "7c9e6679-7425-40de-944b-e07fc1f90ae7"})
+				return err
+			},
+			wantCode:   codes.NotFound,
+			wantReason: "ORDER_NOT_FOUND",
+		},
+		{
+			name: "should reject invalid id",
+			call: func() error {
+				_, err := client.GetOrder(ctx, &orderpb.GetOrderRequest{Id: "42"})
+				return err
+			},
+			wantCode:   codes.InvalidArgument,
+			wantReason: "ORDER_INVALID_ID",
+		},
+		{
+			name: "should reject invalid item",
+			call: func() error {
+				_, err := client.CreateOrder(ctx, &orderpb.CreateOrderRequest{
+					CustomerId: grpcCustomerID,
+					Items:      []*orderpb.OrderItem{{ArticleNo: "A1", UnitPrice: &orderpb.Money{Amount: "1", Currency: "EUR"}}},
+				})
+				return err
+			},
+			wantCode:   codes.InvalidArgument,
+			wantReason: "ORDER_INVALID_ITEM",
+		},
+		{
+			name: "should not submit cancelled order",
+			call: func() error {
+				_, err := client.SubmitOrder(ctx, &orderpb.SubmitOrderRequest{Id: cancelled.Id})
+				return err
+			},
+			wantCode:   codes.Aborted,
+			wantReason: "ORDER_ILLEGAL_TRANSITION",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+
+			err := tt.call()
+
+			st, ok := status.FromError(err)
+			require.True(ok)
+			require.Equal(tt.wantCode, st.Code(), st.Message())
+			require.Len(st.Details(), 1)
+			info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+			require.True(ok)
+			require.Equal(tt.wantReason, info.Reason)
+		})
+	}
+}
+
+func TestOrderServer_StreamOrderEvents(t *testing.T) {
+	// Given
+	require := require.New(t)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), MetadataCorrelationID, "correlation-1")
+	client, _ := newTestOrderClient(t)
+	other := createTestOrder(t, ctx, client)
+	created := createTestOrder(t, ctx, client)
+	_, err := client.SubmitOrder(ctx, &orderpb.SubmitOrderRequest{Id: created.Id})
+	require.NoError(err)
+
+	// When the events of an order are streamed
+	stream, err := client.StreamOrderEvents(ctx, &orderpb.StreamOrderEventsRequest{OrderId: created.Id})
+	require.NoError(err)
+
+	// Then the stream ends once it has caught up
+	var events []*orderpb.OrderEvent
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			require.True(errors.Is(err, io.EOF), "expected end of stream, got %v", err)
+			break
+		}
+		events = append(events, event)
+	}
+	require.Len(events, 2)
+	require.Equal(order.EventTypeOrderCreated, events[0].EventType)
+	require.Equal(order.EventTypeOrderSubmitted, events[1].EventType)
+	require.Equal(created.Id, events[1].OrderId)
+	require.Equal(int32(2), events[1].OrderVersion)
+	require.Equal("correlation-1", events[1].CorrelationId)
+	require.Less(events[0].Position, events[1].Position)
+
+	recorded, err := order.UnmarshalEvent(events[1].Data)
+	require.NoError(err)
+	require.Equal(events[1].EventId, recorded.EventID())
+
+	// When all events are followed from the last position
+	followCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	followed, err := client.StreamOrderEvents(followCtx, &orderpb.StreamOrderEventsRequest{AfterPosition: events[1].Position, Follow: true})
+	require.NoError(err)
+	_, err = client.CancelOrder(ctx, &orderpb.CancelOrderRequest{Id: other.Id, Reason: "changed my mind"})
+	require.NoError(err)
+
+	// Then events stored after the stream was opened are streamed
+	event, err := followed.Recv()
+	require.NoError(err)
+	require.Equal(order.EventTypeOrderCancelled, event.EventType)
+	require.Equal(other.Id, event.OrderId)
+
+	// When the client cancels the stream
+	cancel()
+
+	// Then it ends
+	_, err = followed.Recv()
+	require.Equal(codes.Canceled, status.Code(err))
+
+	// When the order id is invalid
+	invalid, err := client.StreamOrderEvents(ctx, &orderpb.StreamOrderEventsRequest{OrderId: "42"})
+	require.NoError(err)
+	_, err = invalid.Recv()
+
+	// Then
+	require.Equal(codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,98 @@
+package inventory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+)
+
+type (
+	// Tx is the database transaction the SQL repository runs in
+	Tx interface {
+		ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+		QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	}
+
+	Repository interface {
+		FindByArticleNo(ctx context.Context, articleNo string) (*Stock, error)
+		Upsert(ctx context.Context, stock *Stock) error
+	}
+)
+
+const (
+	stockFindByArticleNo = "SELECT article_no, on_hand, reserved, version FROM stock WHERE article_no = $1;"
+	stockInsert          = "INSERT INTO stock (article_no, on_hand, reserved, version) VALUES ($1, $2, $3, $4);"
+	stockUpdate          = "UPDATE stock SET on_hand = $1, reserved = $2, version = $3 WHERE article_no = $4 AND version = $5;"
+)
+
+var (
+	// ErrNotFound is returned when an article isn't stocked
+	ErrNotFound = apperror.New(apperror.CategoryNotFound, "STOCK_NOT_FOUND", "stock not found")
+
+	// ErrConcurrencyConflict is returned when stock was changed by someone else since it was loaded
+	ErrConcurrencyConflict = apperror.New(apperror.CategoryConflict, "STOCK_CONCURRENCY_CONFLICT", "stock was changed concurrently")
+)
+
+// repository implements the Repository interface by storing the current state of stock in the
+// stock table
+type repository struct {
+	tx Tx
+}
+
+// NewRepository returns a repository that uses the transaction it's given
+func NewRepository(tx Tx) Repository {
+	return &repository{tx: tx}
+}
+
+func (r *repository) FindByArticleNo(ctx context.Context, articleNo string) (*Stock, error) {
+	var (
+		rowArticleNo string
+		rowOnHand    int
+		rowReserved  int
+		rowVersion   int
+	)
+	err := r.tx.QueryRowContext(ctx, stockFindByArticleNo, articleNo).Scan(&rowArticleNo, &rowOnHand, &rowReserved, &rowVersion)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("could not find stock of article = %s: %w", articleNo, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not query stock of article = %s: %w", articleNo, err)
+	}
+
+	return ReconstituteStock(rowArticleNo, rowOnHand, rowReserved, rowVersion)
+}
+
+// Upsert inserts new stock and updates existing stock. Updates only succeed if the stock is still
+// at the version it was loaded at, otherwise ErrConcurrencyConflict is returned.
+func (r *repository) Upsert(ctx context.Context, stock *Stock) error {
+	if stock.version == stock.loadedVersion {
+		return nil
+	}
+
+	if stock.loadedVersion == 0 {
+		if _, err := r.tx.ExecContext(ctx, stockInsert, stock.articleNo, stock.onHand, stock.reserved, stock.version); err != nil {
+			return fmt.Errorf("could not insert stock of article = %s: %w", stock.articleNo, err)
+		}
+
+		return nil
+	}
+
+	result, err := r.tx.ExecContext(ctx, stockUpdate, stock.onHand, stock.reserved, stock.version, stock.articleNo, stock.loadedVersion)
+	if err != nil {
+		return fmt.Errorf("could not update stock of article = %s: %w", stock.articleNo, err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not update stock of article = %s: %w", stock.articleNo, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("stock of article = %s is no longer at version = %d: %w", stock.articleNo, stock.loadedVersion, ErrConcurrencyConflict)
+	}
+
+	return nil
+}
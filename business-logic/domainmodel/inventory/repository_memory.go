@@ -0,0 +1,53 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// memoryRepository implements the Repository interface in memory. It stores copies of stock, so
+// changes made to stock aren't visible to others until it's upserted.
+type memoryRepository struct {
+	mu    sync.Mutex
+	stock map[string]Stock
+}
+
+func NewMemoryRepository(stock ...*Stock) Repository {
+	r := &memoryRepository{stock: make(map[string]Stock)}
+	for _, s := range stock {
+		r.store(s)
+	}
+
+	return r
+}
+
+func (r *memoryRepository) FindByArticleNo(ctx context.Context, articleNo string) (*Stock, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.stock[articleNo]
+	if !ok {
+		return nil, fmt.Errorf("could not find stock of article = %s: %w", articleNo, ErrNotFound)
+	}
+
+	return &stored, nil
+}
+
+func (r *memoryRepository) Upsert(ctx context.Context, stock *Stock) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stored, ok := r.stock[stock.articleNo]; ok && stored.version != stock.loadedVersion {
+		return fmt.Errorf("stock of article = %s is no longer at version = %d: %w", stock.articleNo, stock.loadedVersion, ErrConcurrencyConflict)
+	}
+	r.store(stock)
+
+	return nil
+}
+
+func (r *memoryRepository) store(stock *Stock) {
+	stored := *stock
+	stored.loadedVersion = stored.version
+	r.stock[stock.articleNo] = stored
+}
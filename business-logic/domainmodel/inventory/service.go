@@ -0,0 +1,61 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+// Service changes the stock of all items of an order at once. Either the stock of every item is
+// changed or none is, since nothing is stored unless every item succeeds.
+type Service struct {
+	stock Repository
+}
+
+func NewService(stock Repository) *Service {
+	return &Service{stock: stock}
+}
+
+// Reserve sets aside stock for the items. ErrInsufficientStock is returned if any of the articles
+// doesn't have enough stock available, which includes articles that aren't stocked at all.
+func (s *Service) Reserve(ctx context.Context, items []order.OrderItem) error {
+	return s.change(ctx, items, (*Stock).Reserve)
+}
+
+// Release gives back stock reserved for the items
+func (s *Service) Release(ctx context.Context, items []order.OrderItem) error {
+	return s.change(ctx, items, (*Stock).Release)
+}
+
+// Fulfil takes stock reserved for the items off the shelf
+func (s *Service) Fulfil(ctx context.Context, items []order.OrderItem) error {
+	return s.change(ctx, items, (*Stock).Fulfil)
+}
+
+func (s *Service) change(ctx context.Context, items []order.OrderItem, command func(stock *Stock, quantity int) error) error {
+	changed := make([]*Stock, 0, len(items))
+	for _, item := range items {
+		stock, err := s.stock.FindByArticleNo(ctx, item.ArticleNo)
+		if errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("article = %s isn't stocked: %w", item.ArticleNo, ErrInsufficientStock)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = command(stock, item.Quantity); err != nil {
+			return err
+		}
+		changed = append(changed, stock)
+	}
+
+	for _, stock := range changed {
+		if err := s.stock.Upsert(ctx, stock); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package inventory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/migrations"
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "inventory.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	runner, err := migrations.NewRunner(db, migrations.SQLite)
+	require.NoError(t, err)
+	require.NoError(t, runner.Up(context.Background()))
+
+	return db
+}
+
+// beginTestTx begins a transaction that is rolled back when the test ends
+func beginTestTx(t *testing.T, db *sql.DB) *sql.Tx {
+	t.Helper()
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	t.Cleanup(func() { tx.Rollback() })
+
+	return tx
+}
+
+func TestService(t *testing.T) {
+	repositories := map[string]func(t *testing.T) Repository{
+		"memory": func(t *testing.T) Repository { return NewMemoryRepository() },
+		"sql":    func(t *testing.T) Repository { return NewRepository(beginTestTx(t, openTestDB(t))) },
+	}
+	for name, newRepository := range repositories {
+		t.Run(name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			ctx := context.Background()
+			repo := newRepository(t)
+			for articleNo, onHand := range map[string]int{"A1": 5, "B2": 1} {
+				stock, err := NewStock(articleNo, onHand)
+				require.NoError(err)
+				require.NoError(repo.Upsert(ctx, stock))
+			}
+			svc := NewService(repo)
+			items := []order.OrderItem{{ArticleNo: "A1", Quantity: 2}, {ArticleNo: "B2", Quantity: 1}}
+
+			// When
+			err := svc.Reserve(ctx, items)
+
+			// Then
+			require.NoError(err)
+			requireStock(t, repo, "A1", 5, 2)
+			requireStock(t, repo, "B2", 1, 1)
+
+			// When one of the items doesn't have enough stock
+			err = svc.Reserve(ctx, items)
+
+			// Then none of the stock is reserved
+			require.True(errors.Is(err, ErrInsufficientStock), "expected %v to wrap %v", err, ErrInsufficientStock)
+			requireStock(t, repo, "A1", 5, 2)
+
+			// When an article isn't stocked
+			err = svc.Reserve(ctx, []order.OrderItem{{ArticleNo: "C3", Quantity: 1}})
+
+			// Then
+			require.True(errors.Is(err, ErrInsufficientStock), "expected %v to wrap %v", err, ErrInsufficientStock)
+
+			// When the items are shipped
+			require.NoError(svc.Fulfil(ctx, items))
+
+			// Then
+			requireStock(t, repo, "A1", 3, 0)
+			requireStock(t, repo, "B2", 0, 0)
+
+			// When stale stock is upserted
+			stale, err := repo.FindByArticleNo(ctx, "A1")
+			require.NoError(err)
+			require.NoError(svc.Reserve(ctx, []order.OrderItem{{ArticleNo: "A1", Quantity: 1}}))
+			require.NoError(stale.Reserve(1))
+			err = repo.Upsert(ctx, stale)
+
+			// Then
+			require.True(errors.Is(err, ErrConcurrencyConflict), "expected %v to wrap %v", err, ErrConcurrencyConflict)
+		})
+	}
+}
+
+func requireStock(t *testing.T, repo Repository, articleNo string, onHand, reserved int) {
+	t.Helper()
+	stock, err := repo.FindByArticleNo(context.Background(), articleNo)
+	require.NoError(t, err)
+	require.Equal(t, onHand, stock.OnHand(), "on hand of article = %s", articleNo)
+	require.Equal(t, reserved, stock.Reserved(), "reserved of article = %s", articleNo)
+}
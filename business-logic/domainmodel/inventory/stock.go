@@ -0,0 +1,123 @@
+// Package inventory keeps track of the stock of every article. Stock is reserved for orders when
+// they're submitted and taken off the shelf when they're shipped.
+package inventory
+
+import (
+	"fmt"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+)
+
+// ErrInsufficientStock is returned when there isn't enough stock available to reserve
+var ErrInsufficientStock = apperror.New(apperror.CategoryPreconditionFailed, "INVENTORY_INSUFFICIENT_STOCK", "insufficient stock")
+
+// Stock is an aggregate root and entity which holds the stock of a single article. Available
+// stock is what's on hand minus what's reserved for orders that haven't been shipped yet.
+type Stock struct {
+	articleNo string // the entity's unique id
+	onHand    int    // the number of items on the shelf
+	reserved  int    // the number of items on the shelf that are reserved for orders
+	version   int    // the aggregate version which is incremented by every command
+
+	loadedVersion int // the version the stock was loaded at, which is 0 for new stock
+}
+
+// NewStock is a factory function for creating the Stock entity of an article that isn't stocked yet
+func NewStock(articleNo string, onHand int) (*Stock, error) {
+	// enforce business rules
+	if articleNo == "" {
+		return nil, fmt.Errorf("stock must have an article number")
+	}
+
+	if onHand < 0 {
+		return nil, fmt.Errorf("stock on hand must not be negative")
+	}
+
+	return &Stock{articleNo: articleNo, onHand: onHand, version: 1}, nil
+}
+
+// ReconstituteStock is a factory function for instantiating a Stock entity in the middle of its
+// life cycle. The version is the aggregate version the stock was stored at.
+func ReconstituteStock(articleNo string, onHand, reserved, version int) (*Stock, error) {
+	return &Stock{articleNo: articleNo, onHand: onHand, reserved: reserved, version: version, loadedVersion: version}, nil
+}
+
+func (s *Stock) ArticleNo() string {
+	return s.articleNo
+}
+
+func (s *Stock) OnHand() int {
+	return s.onHand
+}
+
+func (s *Stock) Reserved() int {
+	return s.reserved
+}
+
+// Available is the stock that can still be reserved
+func (s *Stock) Available() int {
+	return s.onHand - s.reserved
+}
+
+// Version is the aggregate version
+func (s *Stock) Version() int {
+	return s.version
+}
+
+// Restock is an aggregate command that puts more items of the article on the shelf
+func (s *Stock) Restock(quantity int) error {
+	// check business rules and invariants
+	if quantity <= 0 {
+		return fmt.Errorf("restocked quantity must be positive")
+	}
+
+	s.onHand += quantity
+	s.version++
+
+	return nil
+}
+
+// Reserve is an aggregate command that sets aside items of the article for an order
+func (s *Stock) Reserve(quantity int) error {
+	// check business rules and invariants
+	if quantity <= 0 {
+		return fmt.Errorf("reserved quantity must be positive")
+	}
+
+	if quantity > s.Available() {
+		return fmt.Errorf("could not reserve %d of article = %s with %d available: %w", quantity, s.articleNo, s.Available(), ErrInsufficientStock)
+	}
+
+	s.reserved += quantity
+	s.version++
+
+	return nil
+}
+
+// Release is an aggregate command that gives back items reserved for an order that won't be shipped
+func (s *Stock) Release(quantity int) error {
+	// check business rules and invariants
+	if quantity <= 0 || quantity > s.reserved {
+		return fmt.Errorf("could not release %d of article = %s with %d reserved", quantity, s.articleNo, s.reserved)
+	}
+
+	s.reserved -= quantity
+	s.version++
+
+	return nil
+}
+
+// Fulfil is an aggregate command that takes items reserved for an order off the shelf when the
+// order is shipped
+func (s *Stock) Fulfil(quantity int) error {
+	// check business rules and invariants
+	if quantity <= 0 || quantity > s.reserved {
+		return fmt.Errorf("could not fulfil %d of article = %s with %d reserved", quantity, s.articleNo, s.reserved)
+	}
+
+	s.reserved -= quantity
+	s.onHand -= quantity
+	s.version++
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package inventory
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStock(t *testing.T) {
+	tests := []struct {
+		name         string
+		onHand       int
+		reserved     int
+		command      func(s *Stock) error
+		wantOnHand   int
+		wantReserved int
+		wantErr      bool
+		wantErrIs    error
+	}{
+		{
+			name:         "should reserve available stock",
+			onHand:       5,
+			reserved:     2,
+			command:      func(s *Stock) error { return s.Reserve(3) },
+			wantOnHand:   5,
+			wantReserved: 5,
+		},
+		{
+			name:      "should not reserve more than available",
+			onHand:    5,
+			reserved:  2,
+			command:   func(s *Stock) error { return s.Reserve(4) },
+			wantErr:   true,
+			wantErrIs: ErrInsufficientStock,
+		},
+		{
+			name:         "should release reserved stock",
+			onHand:       5,
+			reserved:     2,
+			command:      func(s *Stock) error { return s.Release(2) },
+			wantOnHand:   5,
+			wantReserved: 0,
+		},
+		{
+			name:     "should not release more than reserved",
+			onHand:   5,
+			reserved: 2,
+			command:  func(s *Stock) error { return s.Release(3) },
+			wantErr:  true,
+		},
+		{
+			name:         "should fulfil reserved stock",
+			onHand:       5,
+			reserved:     2,
+			command:      func(s *Stock) error { return s.Fulfil(2) },
+			wantOnHand:   3,
+			wantReserved: 0,
+		},
+		{
+			name:     "should not fulfil stock that isn't reserved",
+			onHand:   5,
+			reserved: 0,
+			command:  func(s *Stock) error { return s.Fulfil(1) },
+			wantErr:  true,
+		},
+		{
+			name:         "should restock",
+			onHand:       5,
+			reserved:     2,
+			command:      func(s *Stock) error { return s.Restock(10) },
+			wantOnHand:   15,
+			wantReserved: 2,
+		},
+		{
+			name:    "should not restock nothing",
+			onHand:  5,
+			command: func(s *Stock) error { return s.Restock(0) },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			s, _ := ReconstituteStock("A1", tt.onHand, tt.reserved, 1)
+
+			// When
+			err := tt.command(s)
+
+			// Then
+			if tt.wantErr {
+				require.Error(err)
+				if tt.wantErrIs != nil {
+					require.True(errors.Is(err, tt.wantErrIs), "expected %v to wrap %v", err, tt.wantErrIs)
+				}
+				require.Equal(tt.onHand, s.OnHand())
+				require.Equal(tt.reserved, s.Reserved())
+				require.Equal(1, s.Version())
+				return
+			}
+			require.NoError(err)
+			require.Equal(tt.wantOnHand, s.OnHand())
+			require.Equal(tt.wantReserved, s.Reserved())
+			require.Equal(2, s.Version())
+		})
+	}
+}
@@ -12,28 +12,56 @@ type Order struct {
 	customerID CustomerID  // another aggregate root's id, also implemented as a value object
 	orderItems []OrderItem // the items this order consists of, which are a slice of value objects
 	state      OrderState  // the state of the order which is a value type
+	pricing    Pricing     // what's added to and taken off the subtotal, which is a value object
+	version    int         // the aggregate version which is incremented by every domain event
 
-	events []Event // A slice of domain events
+	events   []Event  // A slice of domain events
+	metadata Metadata // ties the domain events to the flow they're raised in
+}
+
+// OrderOption configures an Order created by NewOrder
+type OrderOption func(o *Order)
+
+// WithMetadata ties the domain events of a new Order, including OrderCreated, to the flow it's
+// created in. It's the counterpart of Trace for orders that don't exist yet.
+func WithMetadata(metadata Metadata) OrderOption {
+	return func(o *Order) {
+		o.metadata = metadata
+	}
 }
 
 // NewOrder is a factory function for creating a new Order entity which begins its life cycle
-func NewOrder(customerID CustomerID, orderItems []OrderItem, state OrderState) (*Order, error) {
+func NewOrder(customerID CustomerID, orderItems []OrderItem, state OrderState, opts ...OrderOption) (*Order, error) {
 	orderID, err := NewID(uuid.NewString())
 	if err != nil {
 		return nil, err
 	}
 
-	return ReconstituteOrder(orderID, customerID, orderItems, state)
+	// enforce business rules, an order contains every article once and is paid in a single currency
+	orderItems, err = mergeItems(orderItems)
+	if err != nil {
+		return nil, err
+	}
+
+	order := &Order{}
+	for _, opt := range opts {
+		opt(order)
+	}
+	order.raise(OrderCreated{OrderID: orderID, CustomerID: customerID, Items: orderItems, State: state})
+
+	return order, nil
 }
 
 // ReconstituteOrder is a factory function for instantiating an Order entity in the middle of
-// its life cycle.
-func ReconstituteOrder(id ID, customerID CustomerID, orderItems []OrderItem, state OrderState) (*Order, error) {
+// its life cycle. The version is the aggregate version the order was stored at.
+func ReconstituteOrder(id ID, customerID CustomerID, orderItems []OrderItem, state OrderState, pricing Pricing, version int) (*Order, error) {
 	return &Order{
 		id:         id,
 		customerID: customerID,
 		orderItems: orderItems,
 		state:      state,
+		pricing:    pricing,
+		version:    version,
 	}, nil
 }
 
@@ -50,7 +78,7 @@ func (o *Order) CustomerID() CustomerID {
 // GetOrderItems returns a copy of the slice to avoid leaking a reference to Order's
 // internal slice.
 func (o *Order) OrderItems() []OrderItem {
-	orderItems := make([]OrderItem, 0, len(o.orderItems))
+	orderItems := make([]OrderItem, len(o.orderItems))
 	copy(orderItems, o.orderItems)
 
 	return orderItems
@@ -61,41 +89,124 @@ func (o *Order) State() OrderState {
 	return o.state
 }
 
+// Subtotal is the sum of the line totals of the order items. It's zero without a currency if the
+// order has no items.
+func (o *Order) Subtotal() (Money, error) {
+	var subtotal Money
+	for _, item := range o.orderItems {
+		lineTotal, err := item.LineTotal()
+		if err != nil {
+			return Money{}, fmt.Errorf("could not calculate subtotal of order = %s: %w", o.id, err)
+		}
+
+		if subtotal, err = subtotal.Add(lineTotal); err != nil {
+			return Money{}, fmt.Errorf("could not calculate subtotal of order = %s: %w", o.id, err)
+		}
+	}
+
+	return subtotal, nil
+}
+
+// Total is the amount the customer is charged for the order, which is the subtotal plus the
+// shipping fee minus the discounts
+func (o *Order) Total() (Money, error) {
+	return o.totalWith(o.pricing)
+}
+
+// Version is the aggregate version
+func (o *Order) Version() int {
+	return o.version
+}
+
 // Events returns a slice of domain events that should be published by the application service
 func (o *Order) Events() []Event {
-	domainMsgs := make([]Event, 0, len(o.events))
+	domainMsgs := make([]Event, len(o.events))
 	copy(domainMsgs, o.events)
 
 	return domainMsgs
 }
 
+// Trace sets the metadata of the domain events raised from now on
+func (o *Order) Trace(metadata Metadata) {
+	o.metadata = metadata
+}
+
 // Submit is an aggregate command
 func (o *Order) Submit() error {
-	// check business rules and invariants
-	if o.state != OrderStatePending {
-		return fmt.Errorf("expected state pending found = %d", o.state)
+	total, err := o.Total()
+	if err != nil {
+		return err
 	}
-	o.state = OrderStateSubmitted
 
-	// add domain event to domain event queue
-	submitEvent := NewEvent("submit")
-	o.events = append(o.events, submitEvent)
-
-	return nil
+	return o.transitionTo(OrderStateSubmitted, OrderSubmitted{
+		OrderID:    o.id,
+		CustomerID: o.customerID,
+		Items:      o.OrderItems(),
+		Discounts:  o.Discounts(),
+		Total:      total,
+	})
 }
 
+// CancelReasonOrderExpired is the reason of orders cancelled for being pending for too long
+const CancelReasonOrderExpired = "OrderExpired"
+
 // Cancel is an aggregate command
-func (o *Order) Cancel() error {
+func (o *Order) Cancel(reason string) error {
+	return o.transitionTo(OrderStateCancelled, OrderCancelled{Reason: reason})
+}
+
+// Pay is an aggregate command
+func (o *Order) Pay() error {
+	return o.transitionTo(OrderStatePaid, OrderPaid{})
+}
+
+// StartPicking is an aggregate command
+func (o *Order) StartPicking() error {
+	return o.transitionTo(OrderStatePicking, OrderPickingStarted{})
+}
+
+// Ship is an aggregate command
+func (o *Order) Ship() error {
+	return o.transitionTo(OrderStateShipped, OrderShipped{})
+}
+
+// Deliver is an aggregate command
+func (o *Order) Deliver() error {
+	return o.transitionTo(OrderStateDelivered, OrderDelivered{})
+}
+
+// Return is an aggregate command
+func (o *Order) Return() error {
+	return o.transitionTo(OrderStateReturned, OrderReturned{})
+}
+
+// Refund is an aggregate command
+func (o *Order) Refund() error {
+	return o.transitionTo(OrderStateRefunded, OrderRefunded{})
+}
+
+// transitionTo changes the state of the order and adds the domain event to the domain event queue
+// if the state machine allows it
+func (o *Order) transitionTo(state OrderState, payload EventPayload) error {
 	// check business rules and invariants
-	if o.state != OrderStatePending {
-		return fmt.Errorf("expected state pending found = %d", o.state)
+	if err := checkTransition(o, state); err != nil {
+		return err
 	}
 
-	o.state = OrderStateCancelled
-
-	// add domain event to domain event queue
-	cancelMsg := NewEvent("cancel")
-	o.events = append(o.events, cancelMsg)
+	// add domain event to domain event queue, which also applies the change of state
+	o.raise(payload)
 
 	return nil
 }
+
+// raise applies a new domain event to the order and adds it to the domain event queue
+func (o *Order) raise(payload EventPayload) {
+	id := o.id
+	if created, ok := payload.(OrderCreated); ok {
+		id = created.OrderID
+	}
+
+	event := newEvent(id, o.version+1, o.metadata, payload)
+	o.apply(event)
+	o.events = append(o.events, event)
+}
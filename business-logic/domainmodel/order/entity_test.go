@@ -0,0 +1,78 @@
+package order
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrder_Subtotal(t *testing.T) {
+	customerID := CustomerID("0f8fad5b-d9cb-469f-a165-70867728950e")
+	eur := func(amount int64) Money { return Money{amount: amount, currency: "EUR"} }
+
+	tests := []struct {
+		name         string
+		items        []OrderItem
+		wantSubtotal Money
+		wantErr      error
+	}{
+		{
+			name: "should sum line totals",
+			items: []OrderItem{
+				{ArticleNo: "A1", Quantity: 2, UnitPrice: eur(1250)},
+				{ArticleNo: "B2", Quantity: 3, UnitPrice: eur(199)},
+			},
+			wantSubtotal: eur(3097),
+		},
+		{
+			name:         "should be zero without items",
+			wantSubtotal: Money{},
+		},
+		{
+			name: "should not mix currencies",
+			items: []OrderItem{
+				{ArticleNo: "A1", Quantity: 2, UnitPrice: eur(1250)},
+				{ArticleNo: "B2", Quantity: 3, UnitPrice: Money{amount: 199, currency: "SEK"}},
+			},
+			wantErr: ErrCurrencyMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+
+			o, err := NewOrder(customerID, tt.items, OrderStatePending)
+
+			if tt.wantErr != nil {
+				require.True(errors.Is(err, tt.wantErr), "expected %v to wrap %v", err, tt.wantErr)
+				return
+			}
+			require.NoError(err)
+
+			subtotal, err := o.Subtotal()
+			require.NoError(err)
+			require.Equal(tt.wantSubtotal, subtotal)
+
+			total, err := o.Total()
+			require.NoError(err)
+			require.Equal(tt.wantSubtotal, total)
+		})
+	}
+}
+
+func TestNewOrderItem_UnitPrice(t *testing.T) {
+	require := require.New(t)
+
+	_, err := NewOrderItem("A1", 1, Money{})
+	require.Error(err)
+
+	_, err = NewOrderItem("A1", 1, Money{amount: -1, currency: "EUR"})
+	require.Error(err)
+
+	item, err := NewOrderItem("A1", 3, Money{amount: 1250, currency: "EUR"})
+	require.NoError(err)
+	lineTotal, err := item.LineTotal()
+	require.NoError(err)
+	require.Equal(Money{amount: 3750, currency: "EUR"}, lineTotal)
+}
@@ -1,7 +1,303 @@
 package order
 
-type Event string
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
 
-func NewEvent(msg string) Event {
-	return Event(msg)
+	"github.com/google/uuid"
+)
+
+// EventSchemaVersion is the version of the JSON representation of events written by MarshalEvent.
+// It must be bumped whenever the representation changes in a way older readers can't handle.
+const EventSchemaVersion = 1
+
+const (
+	EventTypeOrderCreated             = "order.created"
+	EventTypeOrderSubmitted           = "order.submitted"
+	EventTypeOrderCancelled           = "order.cancelled"
+	EventTypeOrderPaid                = "order.paid"
+	EventTypeOrderPickingStarted      = "order.picking-started"
+	EventTypeOrderShipped             = "order.shipped"
+	EventTypeOrderDelivered           = "order.delivered"
+	EventTypeOrderReturned            = "order.returned"
+	EventTypeOrderRefunded            = "order.refunded"
+	EventTypeOrderShippingCharged     = "order.shipping-charged"
+	EventTypeOrderDiscountsApplied    = "order.discounts-applied"
+	EventTypeOrderTaxApplied          = "order.tax-applied"
+	EventTypeOrderItemAdded           = "order.item-added"
+	EventTypeOrderItemRemoved         = "order.item-removed"
+	EventTypeOrderItemQuantityChanged = "order.item-quantity-changed"
+)
+
+// now is the clock used to timestamp events. It's a variable so tests can replace it.
+var now = time.Now
+
+// Event is a domain event raised by the Order aggregate
+type Event interface {
+	EventID() string
+	EventType() string
+	AggregateID() ID
+	AggregateVersion() int // the version of the aggregate after the event
+	OccurredAt() time.Time
+	CorrelationID() string // identifies the overall flow the event is part of
+	CausationID() string   // identifies the message that caused the event
+	Payload() EventPayload
+}
+
+// EventPayload is the event specific data of a domain event. Subscribers type switch on it.
+type EventPayload interface {
+	EventType() string
+}
+
+// Payloads of the events raised by the Order aggregate
+type (
+	OrderCreated struct {
+		OrderID    ID          `json:"orderId"`
+		CustomerID CustomerID  `json:"customerId"`
+		Items      []OrderItem `json:"items"`
+		State      OrderState  `json:"state"`
+	}
+
+	OrderSubmitted struct {
+		OrderID    ID          `json:"orderId"`
+		CustomerID CustomerID  `json:"customerId"`
+		Items      []OrderItem `json:"items"`
+		Discounts  []Discount  `json:"discounts,omitempty"`
+		Total      Money       `json:"total"`
+	}
+
+	OrderCancelled struct {
+		Reason string `json:"reason"`
+	}
+
+	OrderShippingCharged struct {
+		Fee   Money `json:"fee"`
+		Total Money `json:"total"` // the total of the order after the fee is charged
+	}
+
+	OrderDiscountsApplied struct {
+		Discounts []Discount `json:"discounts"` // replace the discounts applied before
+		Total     Money      `json:"total"`     // the total of the order after the discounts are applied
+	}
+
+	OrderTaxApplied struct {
+		Tax   TaxBreakdown `json:"tax"`
+		Total Money        `json:"total"` // the total of the order after the tax is applied
+	}
+
+	// The item events remove the discounts and tax of the order. Their totals are the totals of the
+	// order afterwards.
+	OrderItemAdded struct {
+		Item  OrderItem `json:"item"` // merged with the item of the same article, if any
+		Total Money     `json:"total"`
+	}
+
+	OrderItemRemoved struct {
+		ArticleNo string `json:"articleNo"`
+		Quantity  int    `json:"quantity"` // the quantity that was removed
+		Total     Money  `json:"total"`
+	}
+
+	OrderItemQuantityChanged struct {
+		ArticleNo        string `json:"articleNo"`
+		Quantity         int    `json:"quantity"`
+		PreviousQuantity int    `json:"previousQuantity"`
+		Total            Money  `json:"total"`
+	}
+
+	OrderPaid           struct{}
+	OrderPickingStarted struct{}
+	OrderShipped        struct{}
+	OrderDelivered      struct{}
+	OrderReturned       struct{}
+	OrderRefunded       struct{}
+)
+
+func (OrderCreated) EventType() string             { return EventTypeOrderCreated }
+func (OrderSubmitted) EventType() string           { return EventTypeOrderSubmitted }
+func (OrderCancelled) EventType() string           { return EventTypeOrderCancelled }
+func (OrderPaid) EventType() string                { return EventTypeOrderPaid }
+func (OrderPickingStarted) EventType() string      { return EventTypeOrderPickingStarted }
+func (OrderShipped) EventType() string             { return EventTypeOrderShipped }
+func (OrderDelivered) EventType() string           { return EventTypeOrderDelivered }
+func (OrderReturned) EventType() string            { return EventTypeOrderReturned }
+func (OrderRefunded) EventType() string            { return EventTypeOrderRefunded }
+func (OrderShippingCharged) EventType() string     { return EventTypeOrderShippingCharged }
+func (OrderDiscountsApplied) EventType() string    { return EventTypeOrderDiscountsApplied }
+func (OrderTaxApplied) EventType() string          { return EventTypeOrderTaxApplied }
+func (OrderItemAdded) EventType() string           { return EventTypeOrderItemAdded }
+func (OrderItemRemoved) EventType() string         { return EventTypeOrderItemRemoved }
+func (OrderItemQuantityChanged) EventType() string { return EventTypeOrderItemQuantityChanged }
+
+// payloadFactories is used when deserializing events to instantiate the payload of each event type
+var payloadFactories = map[string]func() EventPayload{
+	EventTypeOrderCreated:             func() EventPayload { return &OrderCreated{} },
+	EventTypeOrderSubmitted:           func() EventPayload { return &OrderSubmitted{} },
+	EventTypeOrderCancelled:           func() EventPayload { return &OrderCancelled{} },
+	EventTypeOrderPaid:                func() EventPayload { return &OrderPaid{} },
+	EventTypeOrderPickingStarted:      func() EventPayload { return &OrderPickingStarted{} },
+	EventTypeOrderShipped:             func() EventPayload { return &OrderShipped{} },
+	EventTypeOrderDelivered:           func() EventPayload { return &OrderDelivered{} },
+	EventTypeOrderReturned:            func() EventPayload { return &OrderReturned{} },
+	EventTypeOrderRefunded:            func() EventPayload { return &OrderRefunded{} },
+	EventTypeOrderShippingCharged:     func() EventPayload { return &OrderShippingCharged{} },
+	EventTypeOrderDiscountsApplied:    func() EventPayload { return &OrderDiscountsApplied{} },
+	EventTypeOrderTaxApplied:          func() EventPayload { return &OrderTaxApplied{} },
+	EventTypeOrderItemAdded:           func() EventPayload { return &OrderItemAdded{} },
+	EventTypeOrderItemRemoved:         func() EventPayload { return &OrderItemRemoved{} },
+	EventTypeOrderItemQuantityChanged: func() EventPayload { return &OrderItemQuantityChanged{} },
+}
+
+// Metadata ties events to the flow they're part of
+type Metadata struct {
+	CorrelationID string
+	CausationID   string
+}
+
+type metadataCtxKey struct{}
+
+// ContextWithMetadata returns a copy of ctx carrying the metadata
+func ContextWithMetadata(ctx context.Context, m Metadata) context.Context {
+	return context.WithValue(ctx, metadataCtxKey{}, m)
+}
+
+// MetadataFromContext returns the metadata carried by ctx, if any
+func MetadataFromContext(ctx context.Context) Metadata {
+	m, _ := ctx.Value(metadataCtxKey{}).(Metadata)
+	return m
+}
+
+// event implements the Event interface
+type event struct {
+	id               string
+	aggregateID      ID
+	aggregateVersion int
+	occurredAt       time.Time
+	metadata         Metadata
+	payload          EventPayload
+}
+
+func newEvent(aggregateID ID, aggregateVersion int, metadata Metadata, payload EventPayload) *event {
+	return &event{
+		id:               uuid.NewString(),
+		aggregateID:      aggregateID,
+		aggregateVersion: aggregateVersion,
+		occurredAt:       now().UTC(),
+		metadata:         metadata,
+		payload:          payload,
+	}
+}
+
+func (e *event) EventID() string       { return e.id }
+func (e *event) EventType() string     { return e.payload.EventType() }
+func (e *event) AggregateID() ID       { return e.aggregateID }
+func (e *event) AggregateVersion() int { return e.aggregateVersion }
+func (e *event) OccurredAt() time.Time { return e.occurredAt }
+func (e *event) CorrelationID() string { return e.metadata.CorrelationID }
+func (e *event) CausationID() string   { return e.metadata.CausationID }
+func (e *event) Payload() EventPayload { return e.payload }
+
+// jsonEvent is the serialized form of an Event
+type jsonEvent struct {
+	SchemaVersion    int             `json:"schemaVersion"`
+	EventID          string          `json:"eventId"`
+	EventType        string          `json:"eventType"`
+	AggregateID      string          `json:"aggregateId"`
+	AggregateVersion int             `json:"aggregateVersion"`
+	OccurredAt       time.Time       `json:"occurredAt"`
+	CorrelationID    string          `json:"correlationId,omitempty"`
+	CausationID      string          `json:"causationId,omitempty"`
+	Payload          json.RawMessage `json:"payload"`
+}
+
+// MarshalEvent serializes the event as JSON so it can be stored and published
+func MarshalEvent(e Event) ([]byte, error) {
+	payload, err := json.Marshal(e.Payload())
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal payload of event type = %s: %w", e.EventType(), err)
+	}
+
+	return json.Marshal(jsonEvent{
+		SchemaVersion:    EventSchemaVersion,
+		EventID:          e.EventID(),
+		EventType:        e.EventType(),
+		AggregateID:      string(e.AggregateID()),
+		AggregateVersion: e.AggregateVersion(),
+		OccurredAt:       e.OccurredAt(),
+		CorrelationID:    e.CorrelationID(),
+		CausationID:      e.CausationID(),
+		Payload:          payload,
+	})
+}
+
+// UnmarshalEvent deserializes an event serialized by MarshalEvent
+func UnmarshalEvent(data []byte) (Event, error) {
+	var raw jsonEvent
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("could not unmarshal event: %w", err)
+	}
+
+	if raw.SchemaVersion < 1 || raw.SchemaVersion > EventSchemaVersion {
+		return nil, fmt.Errorf("unsupported event schema version = %d", raw.SchemaVersion)
+	}
+
+	newPayload, ok := payloadFactories[raw.EventType]
+	if !ok {
+		return nil, fmt.Errorf("unknown event type = %s", raw.EventType)
+	}
+
+	payload := newPayload()
+	if err := json.Unmarshal(raw.Payload, payload); err != nil {
+		return nil, fmt.Errorf("could not unmarshal payload of event type = %s: %w", raw.EventType, err)
+	}
+
+	return &event{
+		id:               raw.EventID,
+		aggregateID:      ID(raw.AggregateID),
+		aggregateVersion: raw.AggregateVersion,
+		occurredAt:       raw.OccurredAt,
+		metadata:         Metadata{CorrelationID: raw.CorrelationID, CausationID: raw.CausationID},
+		payload:          derefPayload(payload),
+	}, nil
+}
+
+// derefPayload turns the pointer used for unmarshaling back into the value type events are
+// raised with, so subscribers only need to handle one of them
+func derefPayload(payload EventPayload) EventPayload {
+	switch p := payload.(type) {
+	case *OrderCreated:
+		return *p
+	case *OrderSubmitted:
+		return *p
+	case *OrderCancelled:
+		return *p
+	case *OrderPaid:
+		return *p
+	case *OrderPickingStarted:
+		return *p
+	case *OrderShipped:
+		return *p
+	case *OrderDelivered:
+		return *p
+	case *OrderReturned:
+		return *p
+	case *OrderRefunded:
+		return *p
+	case *OrderShippingCharged:
+		return *p
+	case *OrderDiscountsApplied:
+		return *p
+	case *OrderTaxApplied:
+		return *p
+	case *OrderItemAdded:
+		return *p
+	case *OrderItemRemoved:
+		return *p
+	case *OrderItemQuantityChanged:
+		return *p
+	default:
+		return payload
+	}
 }
@@ -0,0 +1,180 @@
+package order
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+)
+
+// ErrConcurrencyConflict is returned when an aggregate is stored based on a version that has
+// since been changed by someone else. The command may be retried after reloading the aggregate.
+var ErrConcurrencyConflict = apperror.New(apperror.CategoryConflict, "ORDER_CONCURRENCY_CONFLICT", "order was changed concurrently")
+
+// RecordedEvent is an event together with its position in the global order of all events
+type RecordedEvent struct {
+	Position int64
+	Event    Event
+}
+
+// EventStore is an append-only store of the event streams of orders
+type EventStore interface {
+	// Append adds events to the stream of the aggregate if its current version is expectedVersion,
+	// otherwise ErrConcurrencyConflict is returned.
+	Append(ctx context.Context, aggregateID ID, expectedVersion int, events []Event) error
+
+	// ReadStream returns the events of the aggregate from the given version, inclusive, in order
+	ReadStream(ctx context.Context, aggregateID ID, fromVersion int) ([]Event, error)
+
+	// ReadAll returns at most limit events of all aggregates after the given position, in order
+	ReadAll(ctx context.Context, afterPosition int64, limit int) ([]RecordedEvent, error)
+}
+
+// ReplayOrder is a factory function for instantiating an Order entity in the middle of its life
+// cycle by applying its event history. It's the event sourcing counterpart of ReconstituteOrder.
+func ReplayOrder(history []Event) (*Order, error) {
+	if len(history) == 0 {
+		return nil, fmt.Errorf("cannot replay order without events")
+	}
+
+	if _, ok := history[0].Payload().(OrderCreated); !ok {
+		return nil, fmt.Errorf("expected first event to be %s found = %s", EventTypeOrderCreated, history[0].EventType())
+	}
+
+	return replayOnto(&Order{}, history)
+}
+
+// replayOnto applies the history to the order, which must be at the version before the first event
+func replayOnto(o *Order, history []Event) (*Order, error) {
+	for _, e := range history {
+		if e.AggregateVersion() != o.version+1 {
+			return nil, fmt.Errorf("expected event with version = %d found = %d", o.version+1, e.AggregateVersion())
+		}
+
+		if o.version > 0 && e.AggregateID() != o.id {
+			return nil, fmt.Errorf("expected event of order = %s found = %s", o.id, e.AggregateID())
+		}
+
+		o.apply(e)
+	}
+
+	return o, nil
+}
+
+// apply changes the state of the order according to the event. It must not check any business
+// rules since those are checked by the aggregate commands before events are raised, and events
+// that have happened can't be refused.
+func (o *Order) apply(e Event) {
+	if created, ok := e.Payload().(OrderCreated); ok {
+		o.id = created.OrderID
+		o.customerID = created.CustomerID
+		o.orderItems = append([]OrderItem(nil), created.Items...)
+	}
+
+	o.applyItemChange(e.Payload())
+
+	switch p := e.Payload().(type) {
+	case OrderShippingCharged:
+		o.pricing.ShippingFee = p.Fee
+		o.pricing.Tax = nil
+	case OrderDiscountsApplied:
+		o.pricing.Discounts = append([]Discount(nil), p.Discounts...)
+		o.pricing.Tax = nil
+	case OrderTaxApplied:
+		tax := p.Tax
+		o.pricing.Tax = &tax
+	}
+
+	if state, ok := StateAfter(e.Payload()); ok {
+		o.state = state
+	}
+
+	o.version = e.AggregateVersion()
+}
+
+// StateAfter returns the state an order is in after the event, if the event changes it
+func StateAfter(payload EventPayload) (OrderState, bool) {
+	switch p := payload.(type) {
+	case OrderCreated:
+		return p.State, true
+	case OrderSubmitted:
+		return OrderStateSubmitted, true
+	case OrderCancelled:
+		return OrderStateCancelled, true
+	case OrderPaid:
+		return OrderStatePaid, true
+	case OrderPickingStarted:
+		return OrderStatePicking, true
+	case OrderShipped:
+		return OrderStateShipped, true
+	case OrderDelivered:
+		return OrderStateDelivered, true
+	case OrderReturned:
+		return OrderStateReturned, true
+	case OrderRefunded:
+		return OrderStateRefunded, true
+	default:
+		return 0, false
+	}
+}
+
+// eventSourcedRepository implements the Repository interface by storing the events of orders
+// instead of their current state
+type eventSourcedRepository struct {
+	store         EventStore
+	snapshots     SnapshotStore
+	snapshotEvery int
+}
+
+// NewEventSourcedRepository returns a Repository backed by the event store
+func NewEventSourcedRepository(store EventStore, opts ...EventSourcedRepositoryOption) Repository {
+	r := &eventSourcedRepository{store: store}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+func (r *eventSourcedRepository) FindByID(ctx context.Context, id string) (*Order, error) {
+	order, err := r.load(ctx, ID(id))
+	if err != nil {
+		return nil, err
+	}
+
+	if order == nil {
+		history, err := r.store.ReadStream(ctx, ID(id), 1)
+		if err != nil {
+			return nil, fmt.Errorf("could not read events of order by id = %s: %w", id, err)
+		}
+
+		if len(history) == 0 {
+			return nil, fmt.Errorf("could not find order by id = %s: %w", id, ErrNotFound)
+		}
+
+		return ReplayOrder(history)
+	}
+
+	// only the events after the snapshot need to be replayed
+	history, err := r.store.ReadStream(ctx, ID(id), order.version+1)
+	if err != nil {
+		return nil, fmt.Errorf("could not read events of order by id = %s: %w", id, err)
+	}
+
+	return replayOnto(order, history)
+}
+
+// Upsert appends the events raised since the order was loaded or created
+func (r *eventSourcedRepository) Upsert(ctx context.Context, order *Order) error {
+	events := order.Events()
+	if len(events) == 0 {
+		return nil
+	}
+
+	expectedVersion := order.Version() - len(events)
+	if err := r.store.Append(ctx, order.ID(), expectedVersion, events); err != nil {
+		return err
+	}
+
+	return r.snapshotIfDue(ctx, order, expectedVersion)
+}
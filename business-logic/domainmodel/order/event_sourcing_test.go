@@ -0,0 +1,176 @@
+package order
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/migrations"
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t testing.TB) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "order.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	runner, err := migrations.NewRunner(db, migrations.SQLite)
+	require.NoError(t, err)
+	require.NoError(t, runner.Up(context.Background()))
+
+	return db
+}
+
+// beginTestTx begins a transaction that is rolled back when the test ends
+func beginTestTx(t testing.TB, db *sql.DB) *sql.Tx {
+	t.Helper()
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	t.Cleanup(func() { tx.Rollback() })
+
+	return tx
+}
+
+func newTestOrder(t testing.TB) *Order {
+	t.Helper()
+	customerID, _ := NewCustomerID("0f8fad5b-d9cb-469f-a165-70867728950e")
+	unitPrice, _ := NewMoney(1250, "EUR")
+	item, _ := NewOrderItem("A1", 2, unitPrice)
+	o, err := NewOrder(customerID, []OrderItem{item}, OrderStatePending)
+	require.NoError(t, err)
+
+	return o
+}
+
+func TestEventStore(t *testing.T) {
+	stores := map[string]func(t *testing.T) EventStore{
+		"memory": func(t *testing.T) EventStore { return NewMemoryEventStore() },
+		"sql":    func(t *testing.T) EventStore { return NewSQLEventStore(beginTestTx(t, openTestDB(t))) },
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			ctx := context.Background()
+			store := newStore(t)
+			first, second := newTestOrder(t), newTestOrder(t)
+			require.NoError(first.Submit())
+
+			// When
+			require.NoError(store.Append(ctx, first.ID(), 0, first.Events()))
+			require.NoError(store.Append(ctx, second.ID(), 0, second.Events()))
+
+			// Then the stream can be read from a version
+			stream, err := store.ReadStream(ctx, first.ID(), 2)
+			require.NoError(err)
+			require.Len(stream, 1)
+			require.Equal(EventTypeOrderSubmitted, stream[0].EventType())
+
+			// Then all events can be read in global order
+			all, err := store.ReadAll(ctx, 0, 10)
+			require.NoError(err)
+			require.Len(all, 3)
+			for i, recorded := range all {
+				require.Equal(int64(i+1), recorded.Position)
+			}
+			require.Equal(second.ID(), all[2].Event.AggregateID())
+
+			page, err := store.ReadAll(ctx, 1, 1)
+			require.NoError(err)
+			require.Len(page, 1)
+			require.Equal(int64(2), page[0].Position)
+
+			// Then appending based on a stale version is a concurrency conflict
+			require.NoError(second.Cancel("duplicate"))
+			err = store.Append(ctx, second.ID(), 0, second.Events()[1:])
+			require.True(errors.Is(err, ErrConcurrencyConflict))
+		})
+	}
+}
+
+func TestReplayOrder(t *testing.T) {
+	// Given
+	require := require.New(t)
+	o := newTestOrder(t)
+	require.NoError(o.Submit())
+	require.NoError(o.Pay())
+
+	// When
+	replayed, err := ReplayOrder(o.Events())
+
+	// Then
+	require.NoError(err)
+	require.Equal(o.ID(), replayed.ID())
+	require.Equal(o.CustomerID(), replayed.CustomerID())
+	require.Equal(o.OrderItems(), replayed.OrderItems())
+	require.Equal(OrderStatePaid, replayed.State())
+	require.Equal(3, replayed.Version())
+	require.Empty(replayed.Events(), "replayed events must not be published again")
+
+	_, err = ReplayOrder(o.Events()[1:])
+	require.Error(err, "history must start with the order being created")
+}
+
+func TestEventSourcedRepository(t *testing.T) {
+	// Given
+	require := require.New(t)
+	ctx := context.Background()
+	repo := NewEventSourcedRepository(NewMemoryEventStore())
+	o := newTestOrder(t)
+	require.NoError(repo.Upsert(ctx, o))
+
+	// When
+	found, err := repo.FindByID(ctx, string(o.ID()))
+	require.NoError(err)
+	require.NoError(found.Submit())
+	require.NoError(repo.Upsert(ctx, found))
+
+	// Then
+	found, err = repo.FindByID(ctx, string(o.ID()))
+	require.NoError(err)
+	require.Equal(OrderStateSubmitted, found.State())
+	require.Equal(2, found.Version())
+
+	// Then a concurrent change based on the first version conflicts
+	require.NoError(o.Cancel("changed my mind"))
+	require.True(errors.Is(repo.Upsert(ctx, o), ErrConcurrencyConflict))
+
+	_, err = repo.FindByID(ctx, "unknown")
+	require.Error(err)
+}
+
+// staleVersionTx reads version 0 of every order, as if the versions were read before a concurrent
+// append committed
+type staleVersionTx struct {
+	*sql.Tx
+}
+
+func (tx staleVersionTx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if query == eventStoreSelectVersion {
+		return tx.Tx.QueryRowContext(ctx, "SELECT 0;")
+	}
+	return tx.Tx.QueryRowContext(ctx, query, args...)
+}
+
+func TestSQLEventStore_Append_ConcurrentAppends(t *testing.T) {
+	// Given an order whose first version was appended concurrently
+	require := require.New(t)
+	ctx := context.Background()
+	db := openTestDB(t)
+	o := newTestOrder(t)
+	tx := beginTestTx(t, db)
+	require.NoError(NewSQLEventStore(tx).Append(ctx, o.ID(), 0, o.Events()))
+	require.NoError(tx.Commit())
+	store := NewSQLEventStore(staleVersionTx{Tx: beginTestTx(t, db)})
+
+	// When
+	err := store.Append(ctx, o.ID(), 0, o.Events())
+
+	// Then
+	require.True(errors.Is(err, ErrConcurrencyConflict), "expected %v to wrap %v", err, ErrConcurrencyConflict)
+}
@@ -0,0 +1,72 @@
+package order
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalEvent(t *testing.T) {
+	fixedNow := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return fixedNow }
+	defer func() { now = time.Now }()
+
+	items := []OrderItem{{ArticleNo: "A1", Quantity: 2, UnitPrice: Money{amount: 1250, currency: "EUR"}}}
+	discounts := []Discount{{PromotionID: "summer", Description: "10% off", Target: DiscountTargetItems, ArticleNo: "A1", Amount: Money{amount: 250, currency: "EUR"}}}
+
+	tests := []struct {
+		name        string
+		command     func(o *Order) error
+		wantPayload EventPayload
+	}{
+		{
+			name:        "should round trip order submitted",
+			command:     (*Order).Submit,
+			wantPayload: OrderSubmitted{OrderID: "order-id", CustomerID: "customer-id", Items: items, Total: Money{amount: 2500, currency: "EUR"}},
+		},
+		{
+			name:        "should round trip order cancelled",
+			command:     func(o *Order) error { return o.Cancel("out of stock") },
+			wantPayload: OrderCancelled{Reason: "out of stock"},
+		},
+		{
+			name:        "should round trip order discounts applied",
+			command:     func(o *Order) error { return o.ApplyDiscounts(discounts) },
+			wantPayload: OrderDiscountsApplied{Discounts: discounts, Total: Money{amount: 2250, currency: "EUR"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			o, _ := ReconstituteOrder("order-id", "customer-id", items, OrderStatePending, Pricing{}, 1)
+			o.Trace(Metadata{CorrelationID: "correlation-id", CausationID: "causation-id"})
+			require.NoError(tt.command(o))
+			events := o.Events()
+			require.Len(events, 1)
+
+			// When
+			data, err := MarshalEvent(events[0])
+			require.NoError(err)
+			got, err := UnmarshalEvent(data)
+			require.NoError(err)
+
+			// Then
+			require.Equal(events[0].EventID(), got.EventID())
+			require.Equal(tt.wantPayload.EventType(), got.EventType())
+			require.Equal(ID("order-id"), got.AggregateID())
+			require.Equal(2, got.AggregateVersion())
+			require.Equal(fixedNow, got.OccurredAt())
+			require.Equal("correlation-id", got.CorrelationID())
+			require.Equal("causation-id", got.CausationID())
+			require.Equal(tt.wantPayload, got.Payload())
+		})
+	}
+}
+
+func TestUnmarshalEvent_RejectsUnsupportedSchemaVersion(t *testing.T) {
+	_, err := UnmarshalEvent([]byte(`{"schemaVersion":2,"eventType":"order.paid","payload":{}}`))
+
+	require.Error(t, err)
+}
@@ -0,0 +1,82 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// memoryEventStore implements the EventStore interface in memory. It's safe for concurrent use and
+// is useful in tests and for running the examples without a database.
+type memoryEventStore struct {
+	mu      sync.RWMutex
+	all     []RecordedEvent
+	streams map[ID][]Event
+}
+
+func NewMemoryEventStore() EventStore {
+	return &memoryEventStore{streams: make(map[ID][]Event)}
+}
+
+func (s *memoryEventStore) Append(ctx context.Context, aggregateID ID, expectedVersion int, events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream := s.streams[aggregateID]
+	if currentVersion := len(stream); currentVersion != expectedVersion {
+		return fmt.Errorf("expected version = %d found = %d: %w", expectedVersion, currentVersion, ErrConcurrencyConflict)
+	}
+
+	for i, e := range events {
+		if e.AggregateID() != aggregateID || e.AggregateVersion() != expectedVersion+i+1 {
+			return fmt.Errorf("event = %s doesn't follow version = %d of order = %s", e.EventID(), expectedVersion+i, aggregateID)
+		}
+	}
+
+	for _, e := range events {
+		s.all = append(s.all, RecordedEvent{Position: int64(len(s.all) + 1), Event: e})
+	}
+	s.streams[aggregateID] = append(stream, events...)
+
+	return nil
+}
+
+func (s *memoryEventStore) ReadStream(ctx context.Context, aggregateID ID, fromVersion int) ([]Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stream := s.streams[aggregateID]
+	if fromVersion < 1 {
+		fromVersion = 1
+	}
+	if fromVersion > len(stream) {
+		return nil, nil
+	}
+
+	events := make([]Event, len(stream)-fromVersion+1)
+	copy(events, stream[fromVersion-1:])
+
+	return events, nil
+}
+
+func (s *memoryEventStore) ReadAll(ctx context.Context, afterPosition int64, limit int) ([]RecordedEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if afterPosition < 0 {
+		afterPosition = 0
+	}
+	if afterPosition >= int64(len(s.all)) {
+		return nil, nil
+	}
+
+	remaining := s.all[afterPosition:]
+	if limit < len(remaining) {
+		remaining = remaining[:limit]
+	}
+
+	events := make([]RecordedEvent, len(remaining))
+	copy(events, remaining)
+
+	return events, nil
+}
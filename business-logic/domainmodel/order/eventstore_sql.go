@@ -0,0 +1,137 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/sqltx"
+)
+
+const (
+	eventStoreSelectVersion = "SELECT COALESCE(MAX(aggregate_version), 0) FROM order_events WHERE aggregate_id = $1;"
+	eventStoreNextPositions = "UPDATE order_event_sequence SET position = position + $1 WHERE id = 1 RETURNING position;"
+	eventStoreInsert        = "INSERT INTO order_events (position, event_id, aggregate_id, aggregate_version, event_type, occurred_at, data) VALUES ($1, $2, $3, $4, $5, $6, $7);"
+	eventStoreSelectStream  = "SELECT data FROM order_events WHERE aggregate_id = $1 AND aggregate_version >= $2 ORDER BY aggregate_version;"
+	eventStoreSelectAll     = "SELECT position, data FROM order_events WHERE position > $1 ORDER BY position LIMIT $2;"
+
+	timestampLayout = time.RFC3339Nano
+)
+
+// sqlEventStore implements the EventStore interface using the order_events table. It uses the
+// transaction it's given, which means events are appended atomically with any other changes
+// made in the same transaction.
+type sqlEventStore struct {
+	tx Tx
+}
+
+func NewSQLEventStore(tx Tx) EventStore {
+	return &sqlEventStore{tx: tx}
+}
+
+func (s *sqlEventStore) Append(ctx context.Context, aggregateID ID, expectedVersion int, events []Event) error {
+	var currentVersion int
+	if err := s.tx.QueryRowContext(ctx, eventStoreSelectVersion, string(aggregateID)).Scan(&currentVersion); err != nil {
+		return fmt.Errorf("could not read version of order = %s: %w", aggregateID, err)
+	}
+
+	if currentVersion != expectedVersion {
+		return fmt.Errorf("expected version = %d found = %d: %w", expectedVersion, currentVersion, ErrConcurrencyConflict)
+	}
+
+	for i, e := range events {
+		if e.AggregateID() != aggregateID || e.AggregateVersion() != expectedVersion+i+1 {
+			return fmt.Errorf("event = %s doesn't follow version = %d of order = %s", e.EventID(), expectedVersion+i, aggregateID)
+		}
+	}
+
+	return s.insert(ctx, aggregateID, events)
+}
+
+// insert stores the events at the end of the global order without checking that they follow the
+// stream of the aggregate. Events of the same version of an aggregate are still refused.
+func (s *sqlEventStore) insert(ctx context.Context, aggregateID ID, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	// Positions are taken from a single row sequence rather than a database specific one so the
+	// same schema works with every database. Updating the row locks it until the transaction ends,
+	// which serializes appends and keeps the global order gapless and in commit order.
+	var lastPosition int64
+	if err := s.tx.QueryRowContext(ctx, eventStoreNextPositions, len(events)).Scan(&lastPosition); err != nil {
+		return fmt.Errorf("could not reserve event positions: %w", err)
+	}
+	position := lastPosition - int64(len(events))
+
+	for _, e := range events {
+		data, err := MarshalEvent(e)
+		if err != nil {
+			return err
+		}
+
+		position++
+		_, err = s.tx.ExecContext(ctx, eventStoreInsert, position, e.EventID(), string(e.AggregateID()),
+			e.AggregateVersion(), e.EventType(), e.OccurredAt().Format(timestampLayout), string(data))
+		if sqltx.IsUniqueViolation(err) {
+			// a concurrent append of the same version got there first
+			return fmt.Errorf("version = %d of order = %s already exists: %w", e.AggregateVersion(), aggregateID, ErrConcurrencyConflict)
+		}
+		if err != nil {
+			return fmt.Errorf("could not append event = %s to order = %s: %w", e.EventID(), aggregateID, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *sqlEventStore) ReadStream(ctx context.Context, aggregateID ID, fromVersion int) ([]Event, error) {
+	rows, err := s.tx.QueryContext(ctx, eventStoreSelectStream, string(aggregateID), fromVersion)
+	if err != nil {
+		return nil, fmt.Errorf("could not read events of order = %s: %w", aggregateID, err)
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("could not scan event of order = %s: %w", aggregateID, err)
+		}
+
+		e, err := UnmarshalEvent([]byte(data))
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+func (s *sqlEventStore) ReadAll(ctx context.Context, afterPosition int64, limit int) ([]RecordedEvent, error) {
+	rows, err := s.tx.QueryContext(ctx, eventStoreSelectAll, afterPosition, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not read events after position = %d: %w", afterPosition, err)
+	}
+	defer rows.Close()
+
+	var events []RecordedEvent
+	for rows.Next() {
+		var (
+			position int64
+			data     string
+		)
+		if err := rows.Scan(&position, &data); err != nil {
+			return nil, fmt.Errorf("could not scan event after position = %d: %w", afterPosition, err)
+		}
+
+		e, err := UnmarshalEvent([]byte(data))
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, RecordedEvent{Position: position, Event: e})
+	}
+
+	return events, rows.Err()
+}
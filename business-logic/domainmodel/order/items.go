@@ -0,0 +1,198 @@
+package order
+
+import (
+	"fmt"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+)
+
+const (
+	// MaxOrderItems is the number of different articles an order may contain
+	MaxOrderItems = 50
+
+	// MaxItemQuantity is the quantity of a single article an order may contain
+	MaxItemQuantity = 100
+)
+
+var (
+	// ErrItemNotFound is returned when an order doesn't contain the article
+	ErrItemNotFound = apperror.New(apperror.CategoryNotFound, "ORDER_ITEM_NOT_FOUND", "order item not found")
+
+	// ErrTooManyItems is returned when an order would contain more than MaxOrderItems articles
+	ErrTooManyItems = apperror.New(apperror.CategoryPreconditionFailed, "ORDER_TOO_MANY_ITEMS", "too many order items")
+)
+
+// AddItem is an aggregate command that adds an item to the order. If the order already contains
+// the article, the quantities are merged into a single item, which requires the unit prices to be
+// the same. The discounts and tax of the order are removed since they must be applied again.
+func (o *Order) AddItem(item OrderItem) error {
+	// check business rules and invariants
+	if o.state != OrderStatePending {
+		return fmt.Errorf("could not add item to order = %s: %w", o.id, ErrNotPending)
+	}
+
+	item, err := NewOrderItem(item.ArticleNo, item.Quantity, item.UnitPrice)
+	if err != nil {
+		return err
+	}
+
+	items, err := mergeItem(o.orderItems, item)
+	if err != nil {
+		return fmt.Errorf("could not add item to order = %s: %w", o.id, err)
+	}
+
+	total, err := o.totalWithItems(items)
+	if err != nil {
+		return err
+	}
+
+	o.raise(OrderItemAdded{Item: item, Total: total})
+
+	return nil
+}
+
+// RemoveItem is an aggregate command that removes the article from the order. The discounts and
+// tax of the order are removed since they must be applied again.
+func (o *Order) RemoveItem(articleNo string) error {
+	// check business rules and invariants
+	if o.state != OrderStatePending {
+		return fmt.Errorf("could not remove item from order = %s: %w", o.id, ErrNotPending)
+	}
+
+	i := o.indexOfItem(articleNo)
+	if i < 0 {
+		return fmt.Errorf("could not remove article = %s from order = %s: %w", articleNo, o.id, ErrItemNotFound)
+	}
+
+	items := append(append([]OrderItem(nil), o.orderItems[:i]...), o.orderItems[i+1:]...)
+	total, err := o.totalWithItems(items)
+	if err != nil {
+		return err
+	}
+
+	o.raise(OrderItemRemoved{ArticleNo: articleNo, Quantity: o.orderItems[i].Quantity, Total: total})
+
+	return nil
+}
+
+// ChangeQuantity is an aggregate command that changes the quantity of the article in the order.
+// The discounts and tax of the order are removed since they must be applied again.
+func (o *Order) ChangeQuantity(articleNo string, quantity int) error {
+	// check business rules and invariants
+	if o.state != OrderStatePending {
+		return fmt.Errorf("could not change quantity of item in order = %s: %w", o.id, ErrNotPending)
+	}
+
+	i := o.indexOfItem(articleNo)
+	if i < 0 {
+		return fmt.Errorf("could not change quantity of article = %s in order = %s: %w", articleNo, o.id, ErrItemNotFound)
+	}
+
+	item, err := NewOrderItem(articleNo, quantity, o.orderItems[i].UnitPrice)
+	if err != nil {
+		return err
+	}
+
+	items := append([]OrderItem(nil), o.orderItems...)
+	items[i] = item
+	total, err := o.totalWithItems(items)
+	if err != nil {
+		return err
+	}
+
+	o.raise(OrderItemQuantityChanged{ArticleNo: articleNo, Quantity: quantity, PreviousQuantity: o.orderItems[i].Quantity, Total: total})
+
+	return nil
+}
+
+// totalWithItems calculates the total of the order as if it had the given items. Discounts and
+// tax aren't part of it since they're removed whenever the items change.
+func (o *Order) totalWithItems(items []OrderItem) (Money, error) {
+	changed := Order{id: o.id, orderItems: items, pricing: Pricing{ShippingFee: o.pricing.ShippingFee}}
+	return changed.Total()
+}
+
+func (o *Order) indexOfItem(articleNo string) int {
+	for i, item := range o.orderItems {
+		if item.ArticleNo == articleNo {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// mergeItems merges items with the same article number into a single item
+func mergeItems(items []OrderItem) ([]OrderItem, error) {
+	merged := make([]OrderItem, 0, len(items))
+	for _, item := range items {
+		var err error
+		if merged, err = mergeItem(merged, item); err != nil {
+			return nil, err
+		}
+	}
+
+	return merged, nil
+}
+
+// mergeItem returns a copy of the items with the item added to them, either by adding its
+// quantity to the item with the same article number or by appending it
+func mergeItem(items []OrderItem, item OrderItem) ([]OrderItem, error) {
+	merged := append([]OrderItem(nil), items...)
+	if len(merged) > 0 && merged[0].UnitPrice.Currency() != item.UnitPrice.Currency() {
+		return nil, fmt.Errorf("order items must be priced in the same currency: %w", ErrCurrencyMismatch)
+	}
+
+	for i, existing := range merged {
+		if existing.ArticleNo != item.ArticleNo {
+			continue
+		}
+
+		if existing.UnitPrice != item.UnitPrice {
+			return nil, fmt.Errorf("article = %s is already in the order at another unit price: %w", item.ArticleNo, ErrInvalidOrderItem)
+		}
+
+		if existing.Quantity+item.Quantity > MaxItemQuantity {
+			return nil, fmt.Errorf("quantity of article = %s must not exceed %d: %w", item.ArticleNo, MaxItemQuantity, ErrInvalidOrderItem)
+		}
+		merged[i].Quantity += item.Quantity
+
+		return merged, nil
+	}
+
+	if len(merged) >= MaxOrderItems {
+		return nil, fmt.Errorf("order must not contain more than %d items: %w", MaxOrderItems, ErrTooManyItems)
+	}
+
+	return append(merged, item), nil
+}
+
+// applyItemChange changes the items of the order according to the event. The discounts and tax are
+// removed since they're calculated from the items.
+func (o *Order) applyItemChange(payload EventPayload) {
+	// the items are copied so that slices shared with the order's creator are never changed
+	items := append([]OrderItem(nil), o.orderItems...)
+	switch p := payload.(type) {
+	case OrderItemAdded:
+		if i := o.indexOfItem(p.Item.ArticleNo); i >= 0 {
+			items[i].Quantity += p.Item.Quantity
+		} else {
+			items = append(items, p.Item)
+		}
+	case OrderItemRemoved:
+		if i := o.indexOfItem(p.ArticleNo); i >= 0 {
+			items = append(items[:i], items[i+1:]...)
+		}
+	case OrderItemQuantityChanged:
+		if i := o.indexOfItem(p.ArticleNo); i >= 0 {
+			items[i].Quantity = p.Quantity
+		}
+	default:
+		return
+	}
+
+	o.orderItems = items
+
+	o.pricing.Discounts = nil
+	o.pricing.Tax = nil
+}
@@ -0,0 +1,204 @@
+package order
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrder_ItemCommands(t *testing.T) {
+	eur := func(amount int64) Money { return Money{amount: amount, currency: "EUR"} }
+	a1 := OrderItem{ArticleNo: "A1", Quantity: 2, UnitPrice: eur(1000)}
+	b2 := OrderItem{ArticleNo: "B2", Quantity: 1, UnitPrice: eur(500)}
+
+	tests := []struct {
+		name      string
+		state     OrderState
+		items     []OrderItem
+		command   func(o *Order) error
+		wantItems []OrderItem
+		wantEvent EventPayload
+		wantErr   bool
+		wantErrIs error
+	}{
+		{
+			name:      "should add item",
+			state:     OrderStatePending,
+			items:     []OrderItem{a1},
+			command:   func(o *Order) error { return o.AddItem(b2) },
+			wantItems: []OrderItem{a1, b2},
+			wantEvent: OrderItemAdded{Item: b2, Total: eur(2500)},
+		},
+		{
+			name:      "should merge item with same article number",
+			state:     OrderStatePending,
+			items:     []OrderItem{a1},
+			command:   func(o *Order) error { return o.AddItem(OrderItem{ArticleNo: "A1", Quantity: 3, UnitPrice: eur(1000)}) },
+			wantItems: []OrderItem{{ArticleNo: "A1", Quantity: 5, UnitPrice: eur(1000)}},
+			wantEvent: OrderItemAdded{Item: OrderItem{ArticleNo: "A1", Quantity: 3, UnitPrice: eur(1000)}, Total: eur(5000)},
+		},
+		{
+			name:    "should not merge item with another unit price",
+			state:   OrderStatePending,
+			items:   []OrderItem{a1},
+			command: func(o *Order) error { return o.AddItem(OrderItem{ArticleNo: "A1", Quantity: 3, UnitPrice: eur(900)}) },
+			wantErr: true,
+		},
+		{
+			name:    "should not merge item beyond quantity cap",
+			state:   OrderStatePending,
+			items:   []OrderItem{a1},
+			command: func(o *Order) error { return o.AddItem(OrderItem{ArticleNo: "A1", Quantity: 99, UnitPrice: eur(1000)}) },
+			wantErr: true,
+		},
+		{
+			name:    "should not add invalid item",
+			state:   OrderStatePending,
+			items:   []OrderItem{a1},
+			command: func(o *Order) error { return o.AddItem(OrderItem{ArticleNo: "A1", Quantity: 0, UnitPrice: eur(1000)}) },
+			wantErr: true,
+		},
+		{
+			name:  "should not add item in another currency",
+			state: OrderStatePending,
+			items: []OrderItem{a1},
+			command: func(o *Order) error {
+				return o.AddItem(OrderItem{ArticleNo: "B2", Quantity: 1, UnitPrice: Money{amount: 1, currency: "SEK"}})
+			},
+			wantErr:   true,
+			wantErrIs: ErrCurrencyMismatch,
+		},
+		{
+			name:      "should not add more than max items",
+			state:     OrderStatePending,
+			items:     itemsOfArticles(MaxOrderItems),
+			command:   func(o *Order) error { return o.AddItem(OrderItem{ArticleNo: "X", Quantity: 1, UnitPrice: eur(1)}) },
+			wantErr:   true,
+			wantErrIs: ErrTooManyItems,
+		},
+		{
+			name:      "should not add item to submitted order",
+			state:     OrderStateSubmitted,
+			items:     []OrderItem{a1},
+			command:   func(o *Order) error { return o.AddItem(b2) },
+			wantErr:   true,
+			wantErrIs: ErrNotPending,
+		},
+		{
+			name:      "should remove item",
+			state:     OrderStatePending,
+			items:     []OrderItem{a1, b2},
+			command:   func(o *Order) error { return o.RemoveItem("A1") },
+			wantItems: []OrderItem{b2},
+			wantEvent: OrderItemRemoved{ArticleNo: "A1", Quantity: 2, Total: eur(500)},
+		},
+		{
+			name:      "should not remove missing item",
+			state:     OrderStatePending,
+			items:     []OrderItem{a1},
+			command:   func(o *Order) error { return o.RemoveItem("B2") },
+			wantErr:   true,
+			wantErrIs: ErrItemNotFound,
+		},
+		{
+			name:      "should not remove item from cancelled order",
+			state:     OrderStateCancelled,
+			items:     []OrderItem{a1},
+			command:   func(o *Order) error { return o.RemoveItem("A1") },
+			wantErr:   true,
+			wantErrIs: ErrNotPending,
+		},
+		{
+			name:      "should change quantity",
+			state:     OrderStatePending,
+			items:     []OrderItem{a1, b2},
+			command:   func(o *Order) error { return o.ChangeQuantity("B2", 4) },
+			wantItems: []OrderItem{a1, {ArticleNo: "B2", Quantity: 4, UnitPrice: eur(500)}},
+			wantEvent: OrderItemQuantityChanged{ArticleNo: "B2", Quantity: 4, PreviousQuantity: 1, Total: eur(4000)},
+		},
+		{
+			name:    "should not change quantity beyond cap",
+			state:   OrderStatePending,
+			items:   []OrderItem{a1},
+			command: func(o *Order) error { return o.ChangeQuantity("A1", MaxItemQuantity+1) },
+			wantErr: true,
+		},
+		{
+			name:    "should not change quantity to zero",
+			state:   OrderStatePending,
+			items:   []OrderItem{a1},
+			command: func(o *Order) error { return o.ChangeQuantity("A1", 0) },
+			wantErr: true,
+		},
+		{
+			name:      "should not change quantity of missing item",
+			state:     OrderStatePending,
+			items:     []OrderItem{a1},
+			command:   func(o *Order) error { return o.ChangeQuantity("B2", 1) },
+			wantErr:   true,
+			wantErrIs: ErrItemNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			discounts := []Discount{{PromotionID: "p1", Target: DiscountTargetItems, Amount: eur(1)}}
+			o, _ := ReconstituteOrder("order-id", "customer-id", append([]OrderItem(nil), tt.items...), tt.state, Pricing{Discounts: discounts}, 1)
+
+			// When
+			err := tt.command(o)
+
+			// Then
+			if tt.wantErr {
+				require.Error(err)
+				if tt.wantErrIs != nil {
+					require.True(errors.Is(err, tt.wantErrIs), "expected %v to wrap %v", err, tt.wantErrIs)
+				}
+				require.Equal(tt.items, o.OrderItems())
+				require.Empty(o.Events())
+				return
+			}
+			require.NoError(err)
+			require.Equal(tt.wantItems, o.OrderItems())
+			require.Empty(o.Discounts())
+
+			events := o.Events()
+			require.Len(events, 1)
+			require.Equal(tt.wantEvent, events[0].Payload())
+
+			// And replaying the event gives the same items
+			replayed, _ := ReconstituteOrder("order-id", "customer-id", append([]OrderItem(nil), tt.items...), tt.state, Pricing{}, 1)
+			_, err = replayOnto(replayed, events)
+			require.NoError(err)
+			require.Equal(tt.wantItems, replayed.OrderItems())
+		})
+	}
+}
+
+func TestNewOrder_MergesItems(t *testing.T) {
+	require := require.New(t)
+	unitPrice := Money{amount: 1000, currency: "EUR"}
+
+	o, err := NewOrder("customer-id", []OrderItem{
+		{ArticleNo: "A1", Quantity: 2, UnitPrice: unitPrice},
+		{ArticleNo: "A1", Quantity: 3, UnitPrice: unitPrice},
+	}, OrderStatePending)
+
+	require.NoError(err)
+	require.Equal([]OrderItem{{ArticleNo: "A1", Quantity: 5, UnitPrice: unitPrice}}, o.OrderItems())
+
+	_, err = NewOrder("customer-id", itemsOfArticles(MaxOrderItems+1), OrderStatePending)
+	require.True(errors.Is(err, ErrTooManyItems))
+}
+
+func itemsOfArticles(n int) []OrderItem {
+	items := make([]OrderItem, 0, n)
+	for i := 0; i < n; i++ {
+		items = append(items, OrderItem{ArticleNo: fmt.Sprintf("A%d", i), Quantity: 1, UnitPrice: Money{amount: 1, currency: "EUR"}})
+	}
+
+	return items
+}
@@ -0,0 +1,181 @@
+// Package mock contains useful mock objects for when writing tests for code that uses the
+// order package.
+package mock
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+// ErrNotSupported is returned by the SQL methods of Tx, which isn't backed by a database
+var ErrNotSupported = errors.New("not supported by mock transaction")
+
+// Repository is an in-memory order.Repository. It stores copies of orders, so changes made to an
+// order aren't visible to others until it's upserted, and it checks versions on upsert just like
+// the SQL repository. UpsertFn, if set, is called instead of storing the order.
+type Repository struct {
+	UpsertFn func(ctx context.Context, o *order.Order) error
+
+	mu     sync.Mutex
+	orders map[order.ID]order.Snapshot
+}
+
+func NewRepository(orders ...*order.Order) *Repository {
+	r := &Repository{orders: make(map[order.ID]order.Snapshot)}
+	for _, o := range orders {
+		if err := r.store(o); err != nil {
+			panic(err)
+		}
+	}
+
+	return r
+}
+
+func (r *Repository) FindByID(ctx context.Context, id string) (*order.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot, ok := r.orders[order.ID(id)]
+	if !ok {
+		return nil, fmt.Errorf("could not find order by id = %s: %w", id, order.ErrNotFound)
+	}
+
+	return order.RestoreSnapshot(snapshot)
+}
+
+func (r *Repository) Upsert(ctx context.Context, o *order.Order) error {
+	if r.UpsertFn != nil {
+		return r.UpsertFn(ctx, o)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loadedVersion := o.Version() - len(o.Events())
+	if stored, ok := r.orders[o.ID()]; ok && stored.AggregateVersion != loadedVersion {
+		return fmt.Errorf("order = %s is no longer at version = %d: %w", o.ID(), loadedVersion, order.ErrConcurrencyConflict)
+	}
+
+	return r.store(o)
+}
+
+func (r *Repository) store(o *order.Order) error {
+	snapshot, err := order.TakeSnapshot(o)
+	if err != nil {
+		return err
+	}
+	r.orders[o.ID()] = snapshot
+
+	return nil
+}
+
+// Tx is an order.Tx that records whether it was committed or rolled back. CommitErr, if set, is
+// returned by Commit.
+type Tx struct {
+	CommitErr error
+
+	Committed  bool
+	RolledBack bool
+}
+
+func (m *Tx) Commit() error {
+	if m.Committed || m.RolledBack {
+		return sql.ErrTxDone
+	}
+
+	if m.CommitErr != nil {
+		m.RolledBack = true
+		return m.CommitErr
+	}
+	m.Committed = true
+
+	return nil
+}
+
+func (m *Tx) Rollback() error {
+	if m.Committed || m.RolledBack {
+		return sql.ErrTxDone
+	}
+	m.RolledBack = true
+
+	return nil
+}
+
+func (m *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return nil, ErrNotSupported
+}
+
+func (m *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return nil, ErrNotSupported
+}
+
+func (m *Tx) Query(query string, args ...any) (*sql.Rows, error) {
+	return nil, ErrNotSupported
+}
+
+func (m *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return nil, ErrNotSupported
+}
+
+// QueryRowContext returns a row whose Scan returns ErrNotSupported. The row comes from a database
+// whose driver fails every query, since a *sql.Row can't be created outside of database/sql.
+func (m *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return notSupportedDB.QueryRowContext(ctx, query, args...)
+}
+
+// notSupportedDB is a database whose connections fail every statement with ErrNotSupported
+var notSupportedDB = sql.OpenDB(notSupportedConnector{})
+
+type notSupportedConnector struct{}
+
+func (notSupportedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return notSupportedConn{}, nil
+}
+
+func (c notSupportedConnector) Driver() driver.Driver {
+	return c
+}
+
+func (notSupportedConnector) Open(name string) (driver.Conn, error) {
+	return notSupportedConn{}, nil
+}
+
+type notSupportedConn struct{}
+
+func (notSupportedConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, ErrNotSupported
+}
+
+func (notSupportedConn) Close() error {
+	return nil
+}
+
+func (notSupportedConn) Begin() (driver.Tx, error) {
+	return nil, ErrNotSupported
+}
+
+// TxMaker begins mock transactions and keeps them so tests can check how they ended. BeginErr, if
+// set, is returned instead of beginning a transaction and CommitErr is given to every Tx.
+type TxMaker struct {
+	BeginErr  error
+	CommitErr error
+
+	Txs []*Tx
+}
+
+func (m *TxMaker) BeginTransaction(ctx context.Context) (order.Tx, error) {
+	if m.BeginErr != nil {
+		return nil, m.BeginErr
+	}
+
+	tx := &Tx{CommitErr: m.CommitErr}
+	m.Txs = append(m.Txs, tx)
+
+	return tx, nil
+}
@@ -0,0 +1,22 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTx_QueryRowContext(t *testing.T) {
+	// Given
+	require := require.New(t)
+	tx := &Tx{}
+
+	// When
+	var id string
+	err := tx.QueryRowContext(context.Background(), "SELECT id FROM orders WHERE id = $1;", "1").Scan(&id)
+
+	// Then
+	require.True(errors.Is(err, ErrNotSupported), "expected %v to wrap %v", err, ErrNotSupported)
+}
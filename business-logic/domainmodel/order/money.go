@@ -0,0 +1,285 @@
+package order
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+)
+
+var (
+	// ErrCurrencyMismatch is returned when amounts in different currencies are combined
+	ErrCurrencyMismatch = apperror.New(apperror.CategoryValidation, "CURRENCY_MISMATCH", "currency mismatch")
+
+	// ErrMoneyOverflow is returned when the result of a calculation doesn't fit in an amount
+	ErrMoneyOverflow = apperror.New(apperror.CategoryValidation, "MONEY_OVERFLOW", "money amount overflow")
+)
+
+// Currency is an ISO 4217 currency code
+type Currency string
+
+// currencyMinorUnits is the number of decimals of the minor unit of the supported currencies
+var currencyMinorUnits = map[Currency]int{
+	"CHF": 2,
+	"DKK": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"NOK": 2,
+	"SEK": 2,
+	"USD": 2,
+	"JPY": 0,
+	"KWD": 3,
+}
+
+// NewCurrency is a factory function for instantiating a Currency value object
+func NewCurrency(code string) (Currency, error) {
+	currency := Currency(strings.ToUpper(code))
+	if _, ok := currencyMinorUnits[currency]; !ok {
+		return "", apperror.Validation("UNSUPPORTED_CURRENCY", "unsupported currency = %q", code)
+	}
+
+	return currency, nil
+}
+
+// MinorUnits is the number of decimals of the currency's minor unit, e.g. 2 for cents
+func (c Currency) MinorUnits() int {
+	return currencyMinorUnits[c]
+}
+
+// Money is an amount in a currency. The amount is kept in the minor unit of the currency, e.g.
+// cents, so that calculations are exact. Arithmetic returns errors instead of silently mixing
+// currencies or overflowing. The zero value is zero in no particular currency, so it can be
+// combined with amounts in any currency, which makes it the starting point of sums.
+type Money struct {
+	amount   int64
+	currency Currency
+}
+
+// NewMoney is a factory function for instantiating a Money value object. The amount is in the
+// minor unit of the currency.
+func NewMoney(amount int64, currency Currency) (Money, error) {
+	if _, ok := currencyMinorUnits[currency]; !ok {
+		return Money{}, apperror.Validation("UNSUPPORTED_CURRENCY", "unsupported currency = %q", currency)
+	}
+
+	return Money{amount: amount, currency: currency}, nil
+}
+
+// Zero returns the amount of zero in the currency
+func Zero(currency Currency) Money {
+	return Money{currency: currency}
+}
+
+// Amount is the amount in the minor unit of the currency
+func (m Money) Amount() int64 {
+	return m.amount
+}
+
+func (m Money) Currency() Currency {
+	return m.currency
+}
+
+func (m Money) IsZero() bool {
+	return m.amount == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.amount < 0
+}
+
+// Add returns the sum of the amounts, which must be in the same currency
+func (m Money) Add(other Money) (Money, error) {
+	if err := m.checkCurrency(other); err != nil {
+		return Money{}, err
+	}
+
+	if (other.amount > 0 && m.amount > math.MaxInt64-other.amount) ||
+		(other.amount < 0 && m.amount < math.MinInt64-other.amount) {
+		return Money{}, fmt.Errorf("could not add %s to %s: %w", other, m, ErrMoneyOverflow)
+	}
+
+	return Money{amount: m.amount + other.amount, currency: m.commonCurrency(other)}, nil
+}
+
+// Sub returns the difference of the amounts, which must be in the same currency
+func (m Money) Sub(other Money) (Money, error) {
+	if other.amount == math.MinInt64 {
+		return Money{}, fmt.Errorf("could not subtract %s from %s: %w", other, m, ErrMoneyOverflow)
+	}
+
+	return m.Add(Money{amount: -other.amount, currency: other.currency})
+}
+
+// Multiply returns the amount multiplied by n, e.g. the unit price multiplied by the quantity
+func (m Money) Multiply(n int64) (Money, error) {
+	return m.MultiplyRatio(n, 1)
+}
+
+// MultiplyRatio returns the amount multiplied by num/den, e.g. 2500/10000 for 25 percent. The
+// result is rounded to the nearest minor unit, with halves rounded to even (banker's rounding) so
+// that rounding errors don't add up in one direction.
+func (m Money) MultiplyRatio(num, den int64) (Money, error) {
+	if den == 0 {
+		return Money{}, fmt.Errorf("could not multiply %s by %d/%d: division by zero", m, num, den)
+	}
+
+	product := new(big.Int).Mul(big.NewInt(m.amount), big.NewInt(num))
+	quotient, remainder := new(big.Int).QuoRem(product, big.NewInt(den), new(big.Int))
+
+	// compare twice the remainder with the denominator to find out which way to round
+	twiceRemainder := new(big.Int).Abs(remainder)
+	twiceRemainder.Lsh(twiceRemainder, 1)
+	switch twiceRemainder.Cmp(new(big.Int).Abs(big.NewInt(den))) {
+	case 1:
+		quotient.Add(quotient, big.NewInt(int64(product.Sign()*sign(den))))
+	case 0:
+		if quotient.Bit(0) == 1 {
+			quotient.Add(quotient, big.NewInt(int64(product.Sign()*sign(den))))
+		}
+	}
+
+	if !quotient.IsInt64() {
+		return Money{}, fmt.Errorf("could not multiply %s by %d/%d: %w", m, num, den, ErrMoneyOverflow)
+	}
+
+	return Money{amount: quotient.Int64(), currency: m.currency}, nil
+}
+
+// Compare returns -1, 0 or +1 depending on whether m is less than, equal to or greater than
+// other, which must be in the same currency
+func (m Money) Compare(other Money) (int, error) {
+	if err := m.checkCurrency(other); err != nil {
+		return 0, err
+	}
+
+	switch {
+	case m.amount < other.amount:
+		return -1, nil
+	case m.amount > other.amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// Decimal formats the amount in the major unit of the currency, e.g. 12.50 for 1250 cents
+func (m Money) Decimal() string {
+	minorUnits := m.currency.MinorUnits()
+	if minorUnits == 0 {
+		return fmt.Sprintf("%d", m.amount)
+	}
+
+	var prefix string
+	amount := new(big.Int).SetInt64(m.amount)
+	if amount.Sign() < 0 {
+		prefix = "-"
+		amount.Neg(amount)
+	}
+
+	digits := fmt.Sprintf("%0*s", minorUnits+1, amount.String())
+	split := len(digits) - minorUnits
+
+	return prefix + digits[:split] + "." + digits[split:]
+}
+
+// ParseMoney is a factory function for instantiating a Money value object from an amount in the
+// major unit of the currency, e.g. 12.50. The amount must not have more decimals than the currency.
+func ParseMoney(decimal string, currency Currency) (Money, error) {
+	currency, err := NewCurrency(string(currency))
+	if err != nil {
+		return Money{}, err
+	}
+
+	digits := strings.TrimPrefix(decimal, "-")
+	whole, fraction, hasPoint := strings.Cut(digits, ".")
+	if whole == "" || (hasPoint && fraction == "") || len(fraction) > currency.MinorUnits() || !isDigits(whole) || !isDigits(fraction) {
+		return Money{}, apperror.Validation("INVALID_AMOUNT", "invalid amount = %q of currency = %s", decimal, currency)
+	}
+
+	fraction += strings.Repeat("0", currency.MinorUnits()-len(fraction))
+	amount, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount = %q of currency = %s: %w", decimal, currency, ErrMoneyOverflow)
+	}
+
+	if digits != decimal {
+		amount = -amount
+	}
+
+	return NewMoney(amount, currency)
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + string(m.currency)
+}
+
+// jsonMoney is the representation of money in JSON
+type jsonMoney struct {
+	Amount   int64    `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonMoney{Amount: m.amount, Currency: m.currency})
+}
+
+// UnmarshalJSON accepts the zero value, which has no currency, so that documents written before
+// an amount was added to them can still be read
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var raw jsonMoney
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if raw == (jsonMoney{}) {
+		*m = Money{}
+		return nil
+	}
+
+	money, err := NewMoney(raw.Amount, raw.Currency)
+	if err != nil {
+		return err
+	}
+	*m = money
+
+	return nil
+}
+
+func (m Money) checkCurrency(other Money) error {
+	if m.currency != other.currency && m != (Money{}) && other != (Money{}) {
+		return fmt.Errorf("could not combine %s with %s: %w", m.currency, other.currency, ErrCurrencyMismatch)
+	}
+
+	return nil
+}
+
+// commonCurrency is the currency of the result of combining the amounts
+func (m Money) commonCurrency(other Money) Currency {
+	if m.currency == "" {
+		return other.currency
+	}
+
+	return m.currency
+}
+
+func sign(n int64) int {
+	if n < 0 {
+		return -1
+	}
+
+	return 1
+}
@@ -0,0 +1,182 @@
+package order
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMoney(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   int64
+		currency Currency
+		wantErr  bool
+	}{
+		{name: "should create money in supported currency", amount: 1250, currency: "EUR"},
+		{name: "should create negative money", amount: -1, currency: "USD"},
+		{name: "should not create money without currency", amount: 1250, wantErr: true},
+		{name: "should not create money in unsupported currency", amount: 1250, currency: "XYZ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+
+			money, err := NewMoney(tt.amount, tt.currency)
+
+			if tt.wantErr {
+				require.Error(err)
+				return
+			}
+			require.NoError(err)
+			require.Equal(tt.amount, money.Amount())
+			require.Equal(tt.currency, money.Currency())
+		})
+	}
+}
+
+func TestNewCurrency(t *testing.T) {
+	require := require.New(t)
+
+	currency, err := NewCurrency("sek")
+	require.NoError(err)
+	require.Equal(Currency("SEK"), currency)
+	require.Equal(2, currency.MinorUnits())
+
+	_, err = NewCurrency("euro")
+	require.Error(err)
+}
+
+func TestMoney_Arithmetic(t *testing.T) {
+	eur := func(amount int64) Money { return Money{amount: amount, currency: "EUR"} }
+	usd := func(amount int64) Money { return Money{amount: amount, currency: "USD"} }
+
+	tests := []struct {
+		name    string
+		op      func() (Money, error)
+		want    Money
+		wantErr error
+	}{
+		{name: "should add", op: func() (Money, error) { return eur(150).Add(eur(250)) }, want: eur(400)},
+		{name: "should subtract", op: func() (Money, error) { return eur(150).Sub(eur(250)) }, want: eur(-100)},
+		{name: "should multiply", op: func() (Money, error) { return eur(150).Multiply(3) }, want: eur(450)},
+		{name: "should not add different currencies", op: func() (Money, error) { return eur(150).Add(usd(250)) }, wantErr: ErrCurrencyMismatch},
+		{name: "should not subtract different currencies", op: func() (Money, error) { return eur(150).Sub(usd(250)) }, wantErr: ErrCurrencyMismatch},
+		{name: "should not overflow when adding", op: func() (Money, error) { return eur(math.MaxInt64).Add(eur(1)) }, wantErr: ErrMoneyOverflow},
+		{name: "should not overflow when subtracting", op: func() (Money, error) { return eur(math.MinInt64).Sub(eur(1)) }, wantErr: ErrMoneyOverflow},
+		{name: "should not overflow when multiplying", op: func() (Money, error) { return eur(math.MaxInt64 / 2).Multiply(3) }, wantErr: ErrMoneyOverflow},
+		{name: "should round down below half", op: func() (Money, error) { return eur(1).MultiplyRatio(4, 10) }, want: eur(0)},
+		{name: "should round up above half", op: func() (Money, error) { return eur(1).MultiplyRatio(6, 10) }, want: eur(1)},
+		{name: "should round half to even when even is below", op: func() (Money, error) { return eur(5).MultiplyRatio(1, 2) }, want: eur(2)},
+		{name: "should round half to even when even is above", op: func() (Money, error) { return eur(7).MultiplyRatio(1, 2) }, want: eur(4)},
+		{name: "should round negative half to even", op: func() (Money, error) { return eur(-5).MultiplyRatio(1, 2) }, want: eur(-2)},
+		{name: "should round negative above half away from zero", op: func() (Money, error) { return eur(-1).MultiplyRatio(6, 10) }, want: eur(-1)},
+		{name: "should take percentage", op: func() (Money, error) { return eur(1999).MultiplyRatio(2500, 10000) }, want: eur(500)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+
+			got, err := tt.op()
+
+			if tt.wantErr != nil {
+				require.True(errors.Is(err, tt.wantErr), "expected %v to wrap %v", err, tt.wantErr)
+				return
+			}
+			require.NoError(err)
+			require.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestMoney_MultiplyRatio_DivisionByZero(t *testing.T) {
+	_, err := Money{amount: 1, currency: "EUR"}.MultiplyRatio(1, 0)
+	require.Error(t, err)
+}
+
+func TestMoney_Compare(t *testing.T) {
+	require := require.New(t)
+
+	less, err := Money{amount: 1, currency: "EUR"}.Compare(Money{amount: 2, currency: "EUR"})
+	require.NoError(err)
+	require.Equal(-1, less)
+
+	_, err = Money{amount: 1, currency: "EUR"}.Compare(Money{amount: 1, currency: "SEK"})
+	require.True(errors.Is(err, ErrCurrencyMismatch))
+}
+
+func TestMoney_String(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{money: Money{amount: 1250, currency: "EUR"}, want: "12.50 EUR"},
+		{money: Money{amount: 5, currency: "EUR"}, want: "0.05 EUR"},
+		{money: Money{amount: -5, currency: "EUR"}, want: "-0.05 EUR"},
+		{money: Money{amount: 1250, currency: "JPY"}, want: "1250 JPY"},
+		{money: Money{amount: 1250, currency: "KWD"}, want: "1.250 KWD"},
+		{money: Money{amount: math.MinInt64, currency: "EUR"}, want: "-92233720368547758.08 EUR"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			require.Equal(t, tt.want, tt.money.String())
+		})
+	}
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		decimal  string
+		currency Currency
+		want     Money
+		wantErr  bool
+	}{
+		{decimal: "12.50", currency: "EUR", want: Money{amount: 1250, currency: "EUR"}},
+		{decimal: "12.5", currency: "EUR", want: Money{amount: 1250, currency: "EUR"}},
+		{decimal: "12", currency: "eur", want: Money{amount: 1200, currency: "EUR"}},
+		{decimal: "-0.05", currency: "EUR", want: Money{amount: -5, currency: "EUR"}},
+		{decimal: "1250", currency: "JPY", want: Money{amount: 1250, currency: "JPY"}},
+		{decimal: "1.250", currency: "KWD", want: Money{amount: 1250, currency: "KWD"}},
+		{decimal: "12.505", currency: "EUR", wantErr: true},
+		{decimal: "12.", currency: "EUR", wantErr: true},
+		{decimal: ".50", currency: "EUR", wantErr: true},
+		{decimal: "1e3", currency: "EUR", wantErr: true},
+		{decimal: "12.50", currency: "XYZ", wantErr: true},
+		{decimal: "92233720368547758.08", currency: "EUR", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.decimal+" "+string(tt.currency), func(t *testing.T) {
+			require := require.New(t)
+
+			money, err := ParseMoney(tt.decimal, tt.currency)
+
+			if tt.wantErr {
+				require.Error(err)
+				return
+			}
+			require.NoError(err)
+			require.Equal(tt.want, money)
+		})
+	}
+}
+
+func TestMoney_JSON(t *testing.T) {
+	require := require.New(t)
+	money := Money{amount: 1250, currency: "EUR"}
+
+	data, err := json.Marshal(money)
+	require.NoError(err)
+	require.JSONEq(`{"amount": 1250, "currency": "EUR"}`, string(data))
+
+	var got Money
+	require.NoError(json.Unmarshal(data, &got))
+	require.Equal(money, got)
+
+	require.NoError(json.Unmarshal([]byte(`{}`), &got))
+	require.Equal(Money{}, got)
+
+	require.Error(json.Unmarshal([]byte(`{"amount": 1250, "currency": "XYZ"}`), &got))
+}
@@ -0,0 +1,155 @@
+package order
+
+import (
+	"fmt"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+)
+
+var (
+	// ErrNotPending is returned by commands that may only change orders that haven't been submitted yet
+	ErrNotPending = apperror.New(apperror.CategoryConflict, "ORDER_NOT_PENDING", "order is not pending")
+
+	// ErrInvalidDiscount is returned when a discount is negative or isn't taken off anything
+	ErrInvalidDiscount = apperror.New(apperror.CategoryValidation, "ORDER_INVALID_DISCOUNT", "invalid discount")
+
+	// ErrDiscountTooLarge is returned when the discounts taken off the items or the shipping fee of an
+	// order exceed what they're taken off
+	ErrDiscountTooLarge = apperror.New(apperror.CategoryPreconditionFailed, "ORDER_DISCOUNT_TOO_LARGE", "discount too large")
+)
+
+type DiscountTarget string
+
+const (
+	DiscountTargetItems    DiscountTarget = "items"    // the discount is taken off the subtotal
+	DiscountTargetShipping DiscountTarget = "shipping" // the discount is taken off the shipping fee
+)
+
+// Discount is an amount taken off the price of an order by a promotion
+type Discount struct {
+	PromotionID string         `json:"promotionId"`
+	Description string         `json:"description"`
+	Target      DiscountTarget `json:"target"`
+	ArticleNo   string         `json:"articleNo,omitempty"` // the item the discount is for, if it's for a single item
+	Amount      Money          `json:"amount"`
+}
+
+// Pricing is what's added to and taken off the subtotal of an order to arrive at its total
+type Pricing struct {
+	ShippingFee Money         `json:"shippingFee"`
+	Discounts   []Discount    `json:"discounts,omitempty"`
+	Tax         *TaxBreakdown `json:"tax,omitempty"`
+}
+
+// ShippingFee is the fee the customer is charged for shipping the order
+func (o *Order) ShippingFee() Money {
+	return o.pricing.ShippingFee
+}
+
+// Discounts returns a copy of the discounts applied to the order
+func (o *Order) Discounts() []Discount {
+	discounts := make([]Discount, len(o.pricing.Discounts))
+	copy(discounts, o.pricing.Discounts)
+
+	return discounts
+}
+
+// ChargeShipping is an aggregate command that sets the fee for shipping the order. The tax of the
+// order is removed since it must be calculated again.
+func (o *Order) ChargeShipping(fee Money) error {
+	// check business rules and invariants
+	if o.state != OrderStatePending {
+		return fmt.Errorf("could not charge shipping of order = %s: %w", o.id, ErrNotPending)
+	}
+
+	if fee.IsNegative() {
+		return apperror.Validation("ORDER_INVALID_SHIPPING_FEE", "shipping fee must not be negative")
+	}
+
+	pricing := o.pricing
+	pricing.ShippingFee = fee
+	pricing.Tax = nil
+	total, err := o.totalWith(pricing)
+	if err != nil {
+		return err
+	}
+
+	o.raise(OrderShippingCharged{Fee: fee, Total: total})
+
+	return nil
+}
+
+// ApplyDiscounts is an aggregate command that replaces the discounts applied to the order. The
+// discounts must be in the currency of the order and must not add up to more than what they're
+// taken off. The tax of the order is removed since it must be calculated again.
+func (o *Order) ApplyDiscounts(discounts []Discount) error {
+	// check business rules and invariants
+	if o.state != OrderStatePending {
+		return fmt.Errorf("could not apply discounts to order = %s: %w", o.id, ErrNotPending)
+	}
+
+	subtotal, err := o.Subtotal()
+	if err != nil {
+		return err
+	}
+
+	limits := map[DiscountTarget]Money{DiscountTargetItems: subtotal, DiscountTargetShipping: o.pricing.ShippingFee}
+	for _, discount := range discounts {
+		remaining, ok := limits[discount.Target]
+		if !ok {
+			return fmt.Errorf("discount of promotion = %s has an invalid target = %q: %w", discount.PromotionID, discount.Target, ErrInvalidDiscount)
+		}
+
+		if discount.Amount.IsNegative() {
+			return fmt.Errorf("discount of promotion = %s must not be negative: %w", discount.PromotionID, ErrInvalidDiscount)
+		}
+
+		if remaining, err = remaining.Sub(discount.Amount); err != nil {
+			return fmt.Errorf("could not apply discount of promotion = %s: %w", discount.PromotionID, err)
+		}
+
+		if remaining.IsNegative() {
+			return fmt.Errorf("discounts must not exceed the %s of order = %s: %w", discount.Target, o.id, ErrDiscountTooLarge)
+		}
+		limits[discount.Target] = remaining
+	}
+
+	pricing := o.pricing
+	pricing.Discounts = append([]Discount(nil), discounts...)
+	pricing.Tax = nil
+	total, err := o.totalWith(pricing)
+	if err != nil {
+		return err
+	}
+
+	o.raise(OrderDiscountsApplied{Discounts: pricing.Discounts, Total: total})
+
+	return nil
+}
+
+// totalWith calculates the total of the order as if it had the given pricing
+func (o *Order) totalWith(pricing Pricing) (Money, error) {
+	total, err := o.Subtotal()
+	if err != nil {
+		return Money{}, err
+	}
+
+	if total, err = total.Add(pricing.ShippingFee); err != nil {
+		return Money{}, fmt.Errorf("could not add shipping fee to order = %s: %w", o.id, err)
+	}
+
+	for _, discount := range pricing.Discounts {
+		if total, err = total.Sub(discount.Amount); err != nil {
+			return Money{}, fmt.Errorf("could not apply discount of promotion = %s: %w", discount.PromotionID, err)
+		}
+	}
+
+	// tax included in the prices is already part of the total
+	if pricing.Tax != nil && !pricing.Tax.Inclusive {
+		if total, err = total.Add(pricing.Tax.Total); err != nil {
+			return Money{}, fmt.Errorf("could not add tax to order = %s: %w", o.id, err)
+		}
+	}
+
+	return total, nil
+}
@@ -0,0 +1,139 @@
+package order
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+)
+
+func TestOrder_ApplyDiscounts(t *testing.T) {
+	eur := func(amount int64) Money { return Money{amount: amount, currency: "EUR"} }
+	items := []OrderItem{{ArticleNo: "A1", Quantity: 2, UnitPrice: eur(1250)}}
+
+	tests := []struct {
+		name       string
+		state      OrderState
+		discounts  []Discount
+		wantTotal  Money
+		wantErr    bool
+		wantErrIs  error
+		wantStatus int // the HTTP status of the error
+	}{
+		{
+			name:  "should take discounts off subtotal and shipping fee",
+			state: OrderStatePending,
+			discounts: []Discount{
+				{PromotionID: "p1", Target: DiscountTargetItems, ArticleNo: "A1", Amount: eur(500)},
+				{PromotionID: "p2", Target: DiscountTargetShipping, Amount: eur(495)},
+			},
+			wantTotal: eur(2000),
+		},
+		{
+			name:      "should remove discounts",
+			state:     OrderStatePending,
+			wantTotal: eur(2995),
+		},
+		{
+			name:       "should not apply discounts to submitted order",
+			state:      OrderStateSubmitted,
+			discounts:  []Discount{{PromotionID: "p1", Target: DiscountTargetItems, Amount: eur(500)}},
+			wantErr:    true,
+			wantErrIs:  ErrNotPending,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "should not apply discounts larger than the subtotal",
+			state:      OrderStatePending,
+			discounts:  []Discount{{PromotionID: "p1", Target: DiscountTargetItems, Amount: eur(2501)}},
+			wantErr:    true,
+			wantErrIs:  ErrDiscountTooLarge,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "should not apply discounts larger than the shipping fee",
+			state:      OrderStatePending,
+			discounts:  []Discount{{PromotionID: "p1", Target: DiscountTargetShipping, Amount: eur(496)}},
+			wantErr:    true,
+			wantErrIs:  ErrDiscountTooLarge,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "should not apply negative discounts",
+			state:      OrderStatePending,
+			discounts:  []Discount{{PromotionID: "p1", Target: DiscountTargetItems, Amount: eur(-1)}},
+			wantErr:    true,
+			wantErrIs:  ErrInvalidDiscount,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should not apply discounts in another currency",
+			state:      OrderStatePending,
+			discounts:  []Discount{{PromotionID: "p1", Target: DiscountTargetItems, Amount: Money{amount: 1, currency: "SEK"}}},
+			wantErr:    true,
+			wantErrIs:  ErrCurrencyMismatch,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should not apply discounts without target",
+			state:      OrderStatePending,
+			discounts:  []Discount{{PromotionID: "p1", Amount: eur(1)}},
+			wantErr:    true,
+			wantErrIs:  ErrInvalidDiscount,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			o, _ := ReconstituteOrder("order-id", "customer-id", items, OrderStatePending, Pricing{}, 1)
+			require.NoError(o.ChargeShipping(eur(495)))
+			o.state = tt.state
+
+			// When
+			err := o.ApplyDiscounts(tt.discounts)
+
+			// Then
+			if tt.wantErr {
+				require.Error(err)
+				if tt.wantErrIs != nil {
+					require.True(errors.Is(err, tt.wantErrIs), "expected %v to wrap %v", err, tt.wantErrIs)
+				}
+				require.Equal(tt.wantStatus, apperror.NewProblem(httptest.NewRequest(http.MethodPost, "/orders/order-id/promotions", nil), err).Status)
+				require.Len(o.Events(), 1)
+				return
+			}
+			require.NoError(err)
+
+			total, err := o.Total()
+			require.NoError(err)
+			require.Equal(tt.wantTotal, total)
+			require.Equal(len(tt.discounts), len(o.Discounts()))
+
+			events := o.Events()
+			require.Len(events, 2)
+			require.Equal(tt.wantTotal, events[1].Payload().(OrderDiscountsApplied).Total)
+		})
+	}
+}
+
+func TestOrder_ChargeShipping(t *testing.T) {
+	require := require.New(t)
+	items := []OrderItem{{ArticleNo: "A1", Quantity: 1, UnitPrice: Money{amount: 1000, currency: "EUR"}}}
+	o, _ := ReconstituteOrder("order-id", "customer-id", items, OrderStatePending, Pricing{}, 1)
+
+	require.True(errors.Is(o.ChargeShipping(Money{amount: 495, currency: "SEK"}), ErrCurrencyMismatch))
+	require.Error(o.ChargeShipping(Money{amount: -1, currency: "EUR"}))
+	require.NoError(o.ChargeShipping(Money{amount: 495, currency: "EUR"}))
+
+	total, err := o.Total()
+	require.NoError(err)
+	require.Equal(Money{amount: 1495, currency: "EUR"}, total)
+
+	require.NoError(o.Submit())
+	require.True(errors.Is(o.ChargeShipping(Money{amount: 0, currency: "EUR"}), ErrNotPending))
+}
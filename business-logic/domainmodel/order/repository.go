@@ -5,13 +5,20 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"time"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/sqltx"
 )
 
 type (
 	Tx interface {
 		Commit() error
 		Exec(query string, args ...any) (sql.Result, error)
+		ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
 		Query(query string, args ...any) (*sql.Rows, error)
+		QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+		QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 		Rollback() error
 	}
 
@@ -21,12 +28,41 @@ type (
 	}
 
 	connPool interface {
-		BeginTransaction() *sql.Tx
+		BeginTx(ctx context.Context, opts *sql.TxOptions) (sqltx.Tx, error)
 	}
 )
 
-// repository implements the Repository interface.
-// NOTE! Error handling is omitted to simplify the examples.
+const (
+	orderFindByID = "SELECT id, customer_id, order_items, state, pricing, version FROM orders WHERE id = $1;"
+	orderInsert   = "INSERT INTO orders (id, customer_id, order_items, state, pricing, version, currency, subtotal, total, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);"
+	orderUpdate   = "UPDATE orders SET customer_id = $1, order_items = $2, state = $3, pricing = $4, version = $5, currency = $6, subtotal = $7, total = $8 WHERE id = $9 AND version = $10;"
+)
+
+// CreatedAtLayout is the layout of the created_at column of the orders table. Its timestamps are in
+// UTC and have a fixed width, so they can be compared as text.
+const CreatedAtLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// ErrNotFound is returned when there is no order with the given id
+var ErrNotFound = apperror.New(apperror.CategoryNotFound, "ORDER_NOT_FOUND", "order not found")
+
+type (
+	jsonOrderItem struct {
+		ArticleNo string `json:"articleNo"`
+		Quantity  int    `json:"quantity"`
+		UnitPrice Money  `json:"unitPrice"`
+	}
+
+	// jsonOrderItems is the representation of order items in the order_items column
+	jsonOrderItems struct {
+		Data []jsonOrderItem `json:"data"`
+	}
+)
+
+// repository implements the Repository interface by storing the current state of orders in the
+// orders table. The order items are stored as JSON in the order_items column. The events orders
+// raise are appended to the order event store in the same transaction, so projections and sagas
+// see the changes of orders no matter how they're stored. Orders stored before their events were
+// kept only have the events since, so their streams can't be replayed.
 type repository struct {
 	tx Tx
 }
@@ -37,8 +73,11 @@ func NewRepository(tx Tx) Repository {
 }
 
 func (r *repository) FindByID(ctx context.Context, id string) (*Order, error) {
-	// run fictional query against database
-	rows, _ := r.tx.Query("... query ...")
+	// run query against database
+	rows, err := r.tx.QueryContext(ctx, orderFindByID, id)
+	if err != nil {
+		return nil, fmt.Errorf("could not query order by id = %s: %w", id, err)
+	}
 	defer rows.Close()
 
 	var (
@@ -46,46 +85,136 @@ func (r *repository) FindByID(ctx context.Context, id string) (*Order, error) {
 		rowCustID         string
 		rowJsonOrderItems []byte
 		rowState          int
-	)
-
-	type (
-		jsonOrderItem struct {
-			ArticleNo string `json:"articleNo"`
-			Quantity  int    `json:"quantity"`
-		}
-
-		jsonOrderItems struct {
-			Data []jsonOrderItem `json:"data"`
-		}
+		rowJsonPricing    []byte
+		rowVersion        int
 	)
 
 	// scan rows to map values returned from the database
 	if !rows.Next() {
-		return nil, fmt.Errorf("could not find order by id = %s", id)
+		if err = rows.Err(); err != nil {
+			return nil, fmt.Errorf("could not query order by id = %s: %w", id, err)
+		}
+		return nil, fmt.Errorf("could not find order by id = %s: %w", id, ErrNotFound)
+	}
+	if err = rows.Scan(&rowID, &rowCustID, &rowJsonOrderItems, &rowState, &rowJsonPricing, &rowVersion); err != nil {
+		return nil, fmt.Errorf("could not scan order by id = %s: %w", id, err)
 	}
-	rows.Scan(&rowID, &rowCustID, &rowJsonOrderItems, &rowState)
 
 	var rowOrderItems jsonOrderItems
-	json.Unmarshal(rowJsonOrderItems, &rowOrderItems)
+	if err = json.Unmarshal(rowJsonOrderItems, &rowOrderItems); err != nil {
+		return nil, fmt.Errorf("could not unmarshal items of order by id = %s: %w", id, err)
+	}
+
+	var pricing Pricing
+	if err = json.Unmarshal(rowJsonPricing, &pricing); err != nil {
+		return nil, fmt.Errorf("could not unmarshal pricing of order by id = %s: %w", id, err)
+	}
 
 	// delegate creation/validation/business rules to factories/constructors
-	ID, _ := NewID(rowID)
-	customerID, _ := NewCustomerID(rowCustID)
-	var orderItems []OrderItem
+	ID, err := NewID(rowID)
+	if err != nil {
+		return nil, err
+	}
+	customerID, err := NewCustomerID(rowCustID)
+	if err != nil {
+		return nil, err
+	}
+	orderItems := make([]OrderItem, 0, len(rowOrderItems.Data))
 	for _, rowOrderItem := range rowOrderItems.Data {
-		orderItem, _ := NewOrderItem(rowOrderItem.ArticleNo, rowOrderItem.Quantity)
+		orderItem, err := NewOrderItem(rowOrderItem.ArticleNo, rowOrderItem.Quantity, rowOrderItem.UnitPrice)
+		if err != nil {
+			return nil, fmt.Errorf("order by id = %s has an invalid item: %w", id, err)
+		}
 		orderItems = append(orderItems, orderItem)
 	}
-	state, _ := NewOrderState(rowState)
-	order, _ := ReconstituteOrder(ID, customerID, orderItems, state)
+	state, err := NewOrderState(rowState)
+	if err != nil {
+		return nil, fmt.Errorf("order by id = %s has an invalid state: %w", id, err)
+	}
 
-	return order, nil
+	return ReconstituteOrder(ID, customerID, orderItems, state, pricing, rowVersion)
 }
 
+// Upsert inserts new orders and updates existing ones. Updates only succeed if the order is still
+// at the version it was loaded at, otherwise ErrConcurrencyConflict is returned. The subtotal and
+// total are stored alongside the order so they can be queried, but they're always recalculated
+// from the order items when the order is loaded.
 func (r *repository) Upsert(ctx context.Context, order *Order) error {
-	// run fictional query against database
-	_, err := r.tx.Exec("... query ...")
-	return err
+	subtotal, err := order.Subtotal()
+	if err != nil {
+		return err
+	}
+	total, err := order.Total()
+	if err != nil {
+		return err
+	}
+
+	rowOrderItems := jsonOrderItems{Data: make([]jsonOrderItem, 0, len(order.orderItems))}
+	for _, orderItem := range order.orderItems {
+		rowOrderItems.Data = append(rowOrderItems.Data, jsonOrderItem(orderItem))
+	}
+	rowJsonOrderItems, err := json.Marshal(rowOrderItems)
+	if err != nil {
+		return fmt.Errorf("could not marshal items of order = %s: %w", order.ID(), err)
+	}
+	rowJsonPricing, err := json.Marshal(order.pricing)
+	if err != nil {
+		return fmt.Errorf("could not marshal pricing of order = %s: %w", order.ID(), err)
+	}
+
+	// the version the order was loaded at is the version before the events it has raised since
+	loadedVersion := order.Version() - len(order.events)
+	if loadedVersion == 0 {
+		_, err = r.tx.ExecContext(ctx, orderInsert, string(order.ID()), string(order.CustomerID()),
+			string(rowJsonOrderItems), int(order.State()), string(rowJsonPricing), order.Version(),
+			string(total.Currency()), subtotal.Amount(), total.Amount(), createdAt(order).UTC().Format(CreatedAtLayout))
+		if err != nil {
+			return fmt.Errorf("could not insert order = %s: %w", order.ID(), err)
+		}
+
+		return r.recordEvents(ctx, order)
+	}
+
+	result, err := r.tx.ExecContext(ctx, orderUpdate, string(order.CustomerID()), string(rowJsonOrderItems),
+		int(order.State()), string(rowJsonPricing), order.Version(), string(total.Currency()), subtotal.Amount(), total.Amount(),
+		string(order.ID()), loadedVersion)
+	if err != nil {
+		return fmt.Errorf("could not update order = %s: %w", order.ID(), err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not update order = %s: %w", order.ID(), err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("order = %s is no longer at version = %d: %w", order.ID(), loadedVersion, ErrConcurrencyConflict)
+	}
+
+	return r.recordEvents(ctx, order)
+}
+
+// recordEvents appends the events the order raised to the event store. The version of the order
+// has already been checked against the orders table.
+func (r *repository) recordEvents(ctx context.Context, order *Order) error {
+	store := &sqlEventStore{tx: r.tx}
+	if err := store.insert(ctx, order.ID(), order.events); err != nil {
+		return fmt.Errorf("could not record events of order = %s: %w", order.ID(), err)
+	}
+
+	return nil
+}
+
+// createdAt returns when the order was created, which is when it's first stored if it wasn't
+// created by NewOrder
+func createdAt(order *Order) time.Time {
+	for _, e := range order.events {
+		if _, ok := e.Payload().(OrderCreated); ok {
+			return e.OccurredAt()
+		}
+	}
+
+	return now()
 }
 
 type TxMaker struct {
@@ -96,6 +225,7 @@ func NewTxMaker(connPool connPool) *TxMaker {
 	return &TxMaker{connPool: connPool}
 }
 
-func (f *TxMaker) BeginTransaction() *sql.Tx {
-	return f.connPool.BeginTransaction()
+// BeginTransaction begins a transaction, which joins the shared transaction of ctx if there is one
+func (f *TxMaker) BeginTransaction(ctx context.Context) (Tx, error) {
+	return f.connPool.BeginTx(ctx, nil)
 }
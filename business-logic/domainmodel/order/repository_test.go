@@ -0,0 +1,156 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepository_Upsert_ConcurrencyConflict(t *testing.T) {
+	// Given
+	require := require.New(t)
+	ctx := context.Background()
+	repo := NewRepository(beginTestTx(t, openTestDB(t)))
+	o := newTestOrder(t)
+	require.NoError(repo.Upsert(ctx, o))
+
+	first, err := repo.FindByID(ctx, string(o.ID()))
+	require.NoError(err)
+	second, err := repo.FindByID(ctx, string(o.ID()))
+	require.NoError(err)
+
+	// When
+	require.NoError(first.Submit())
+	require.NoError(second.Cancel("changed my mind"))
+	firstErr := repo.Upsert(ctx, first)
+	secondErr := repo.Upsert(ctx, second)
+
+	// Then
+	require.NoError(firstErr)
+	require.True(errors.Is(secondErr, ErrConcurrencyConflict))
+
+	stored, err := repo.FindByID(ctx, string(o.ID()))
+	require.NoError(err)
+	require.Equal(OrderStateSubmitted, stored.State())
+	require.Equal(2, stored.Version())
+}
+
+func TestRepository(t *testing.T) {
+	// Given
+	require := require.New(t)
+	ctx := context.Background()
+	db := openTestDB(t)
+	tx := beginTestTx(t, db)
+	repo := NewRepository(tx)
+	o := newTestOrder(t)
+
+	// When a new order is upserted
+	require.NoError(repo.Upsert(ctx, o))
+
+	// Then it's inserted
+	found, err := repo.FindByID(ctx, string(o.ID()))
+	require.NoError(err)
+	require.Equal(o.ID(), found.ID())
+	require.Equal(o.CustomerID(), found.CustomerID())
+	require.Equal(o.OrderItems(), found.OrderItems())
+	require.Equal(OrderStatePending, found.State())
+	require.Equal(1, found.Version())
+
+	// And its totals are stored alongside it
+	var (
+		currency        string
+		subtotal, total int64
+	)
+	row := tx.QueryRowContext(ctx, "SELECT currency, subtotal, total FROM orders WHERE id = $1;", string(o.ID()))
+	require.NoError(row.Scan(&currency, &subtotal, &total))
+	require.Equal("EUR", currency)
+	require.Equal(int64(2500), subtotal)
+	require.Equal(int64(2500), total)
+
+	// When an existing order is upserted
+	require.NoError(found.Submit())
+	require.NoError(repo.Upsert(ctx, found))
+
+	// Then it's updated
+	found, err = repo.FindByID(ctx, string(o.ID()))
+	require.NoError(err)
+	require.Equal(OrderStateSubmitted, found.State())
+	require.Equal(2, found.Version())
+}
+
+func TestRepository_FindByID_Errors(t *testing.T) {
+	const id = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+	insert := "INSERT INTO orders (id, customer_id, order_items, state, version) VALUES ($1, $2, $3, $4, 1);"
+
+	tests := []struct {
+		name         string
+		customerID   string
+		orderItems   string
+		state        int
+		cancelCtx    bool
+		wantNotFound bool
+	}{
+		{
+			name:         "should return ErrNotFound when order doesn't exist",
+			wantNotFound: true,
+		},
+		{
+			name:       "should return error when order items aren't valid JSON",
+			customerID: "0f8fad5b-d9cb-469f-a165-70867728950e",
+			orderItems: `{"data":`,
+			state:      int(OrderStatePending),
+		},
+		{
+			name:       "should return error when an order item breaks business rules",
+			customerID: "0f8fad5b-d9cb-469f-a165-70867728950e",
+			orderItems: `{"data":[{"articleNo":"A1","quantity":101}]}`,
+			state:      int(OrderStatePending),
+		},
+		{
+			name:       "should return error when the customer id is invalid",
+			customerID: "not-a-uuid",
+			orderItems: `{"data":[]}`,
+			state:      int(OrderStatePending),
+		},
+		{
+			name:       "should return error when the state is invalid",
+			customerID: "0f8fad5b-d9cb-469f-a165-70867728950e",
+			orderItems: `{"data":[]}`,
+			state:      42,
+		},
+		{
+			name:       "should return error when the context is done",
+			customerID: "0f8fad5b-d9cb-469f-a165-70867728950e",
+			orderItems: `{"data":[]}`,
+			state:      int(OrderStatePending),
+			cancelCtx:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			tx := beginTestTx(t, openTestDB(t))
+			if !tt.wantNotFound {
+				_, err := tx.Exec(insert, id, tt.customerID, tt.orderItems, tt.state)
+				require.NoError(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancelCtx {
+				cancel()
+			}
+			defer cancel()
+
+			// When
+			o, err := NewRepository(tx).FindByID(ctx, id)
+
+			// Then
+			require.Error(err)
+			require.Nil(o)
+			require.Equal(tt.wantNotFound, errors.Is(err, ErrNotFound))
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// SnapshotSchemaVersion is the version of the representation of orders in snapshots. It must be
+// bumped whenever the representation changes. Snapshots with another version are ignored, which
+// means the order is replayed from its full event history until a new snapshot is taken.
+const SnapshotSchemaVersion = 3
+
+// Snapshot is the state of an order at a version of its event stream
+type Snapshot struct {
+	AggregateID      ID
+	AggregateVersion int
+	SchemaVersion    int
+	Data             []byte
+}
+
+// SnapshotStore stores snapshots alongside the event streams of orders
+type SnapshotStore interface {
+	Save(ctx context.Context, snapshot Snapshot) error
+
+	// Latest returns the snapshot of the order with the highest version, if any
+	Latest(ctx context.Context, aggregateID ID) (Snapshot, bool, error)
+}
+
+// jsonSnapshot is the representation of an order in snapshots
+type jsonSnapshot struct {
+	ID         ID          `json:"id"`
+	CustomerID CustomerID  `json:"customerId"`
+	Items      []OrderItem `json:"items"`
+	State      OrderState  `json:"state"`
+	Pricing    Pricing     `json:"pricing"`
+}
+
+// TakeSnapshot returns a snapshot of the order's current state
+func TakeSnapshot(o *Order) (Snapshot, error) {
+	data, err := json.Marshal(jsonSnapshot{
+		ID:         o.id,
+		CustomerID: o.customerID,
+		Items:      o.orderItems,
+		State:      o.state,
+		Pricing:    o.pricing,
+	})
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("could not take snapshot of order = %s: %w", o.id, err)
+	}
+
+	return Snapshot{
+		AggregateID:      o.id,
+		AggregateVersion: o.version,
+		SchemaVersion:    SnapshotSchemaVersion,
+		Data:             data,
+	}, nil
+}
+
+// RestoreSnapshot instantiates an order at the version of the snapshot
+func RestoreSnapshot(snapshot Snapshot) (*Order, error) {
+	if snapshot.SchemaVersion != SnapshotSchemaVersion {
+		return nil, fmt.Errorf("unsupported snapshot schema version = %d", snapshot.SchemaVersion)
+	}
+
+	var raw jsonSnapshot
+	if err := json.Unmarshal(snapshot.Data, &raw); err != nil {
+		return nil, fmt.Errorf("could not unmarshal snapshot of order = %s: %w", snapshot.AggregateID, err)
+	}
+
+	return &Order{
+		id:         raw.ID,
+		customerID: raw.CustomerID,
+		orderItems: raw.Items,
+		state:      raw.State,
+		pricing:    raw.Pricing,
+		version:    snapshot.AggregateVersion,
+	}, nil
+}
+
+type EventSourcedRepositoryOption func(r *eventSourcedRepository)
+
+// WithSnapshots makes the repository take a snapshot of orders every given number of events and
+// load orders from their latest snapshot, replaying only the events after it
+func WithSnapshots(store SnapshotStore, every int) EventSourcedRepositoryOption {
+	return func(r *eventSourcedRepository) {
+		r.snapshots = store
+		r.snapshotEvery = every
+	}
+}
+
+// load restores the order from its latest snapshot, if there is a usable one
+func (r *eventSourcedRepository) load(ctx context.Context, id ID) (*Order, error) {
+	if r.snapshots == nil {
+		return nil, nil
+	}
+
+	snapshot, found, err := r.snapshots.Latest(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("could not read snapshot of order = %s: %w", id, err)
+	}
+
+	if !found || snapshot.SchemaVersion != SnapshotSchemaVersion {
+		return nil, nil
+	}
+
+	return RestoreSnapshot(snapshot)
+}
+
+// snapshotIfDue takes a snapshot if the events appended since fromVersion crossed a multiple of
+// the snapshot interval
+func (r *eventSourcedRepository) snapshotIfDue(ctx context.Context, o *Order, fromVersion int) error {
+	if r.snapshots == nil || r.snapshotEvery <= 0 {
+		return nil
+	}
+
+	if fromVersion/r.snapshotEvery == o.version/r.snapshotEvery {
+		return nil
+	}
+
+	snapshot, err := TakeSnapshot(o)
+	if err != nil {
+		return err
+	}
+
+	if err = r.snapshots.Save(ctx, snapshot); err != nil {
+		return fmt.Errorf("could not save snapshot of order = %s: %w", o.id, err)
+	}
+
+	return nil
+}
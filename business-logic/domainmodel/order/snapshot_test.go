@@ -0,0 +1,118 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// spyEventStore records the versions streams are read from
+type spyEventStore struct {
+	EventStore
+	readFromVersions []int
+}
+
+func (s *spyEventStore) ReadStream(ctx context.Context, aggregateID ID, fromVersion int) ([]Event, error) {
+	s.readFromVersions = append(s.readFromVersions, fromVersion)
+	return s.EventStore.ReadStream(ctx, aggregateID, fromVersion)
+}
+
+func TestEventSourcedRepository_Snapshots(t *testing.T) {
+	tests := []struct {
+		name                string
+		snapshotSchema      int
+		wantReadFromVersion int
+	}{
+		{
+			name:                "should replay only the events after the latest snapshot",
+			snapshotSchema:      SnapshotSchemaVersion,
+			wantReadFromVersion: 3,
+		},
+		{
+			name:                "should replay all events when the snapshot schema is unsupported",
+			snapshotSchema:      SnapshotSchemaVersion + 1,
+			wantReadFromVersion: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			ctx := context.Background()
+			events := &spyEventStore{EventStore: NewMemoryEventStore()}
+			snapshots := NewMemorySnapshotStore()
+			repo := NewEventSourcedRepository(events, WithSnapshots(snapshots, 2))
+
+			o := newTestOrder(t)
+			require.NoError(repo.Upsert(ctx, o))
+			_, found, _ := snapshots.Latest(ctx, o.ID())
+			require.False(found, "no snapshot is due after the first event")
+
+			o, _ = repo.FindByID(ctx, string(o.ID()))
+			require.NoError(o.Submit())
+			require.NoError(repo.Upsert(ctx, o))
+			snapshot, found, _ := snapshots.Latest(ctx, o.ID())
+			require.True(found)
+			require.Equal(2, snapshot.AggregateVersion)
+
+			o, _ = repo.FindByID(ctx, string(o.ID()))
+			require.NoError(o.Pay())
+			require.NoError(repo.Upsert(ctx, o))
+
+			snapshot.SchemaVersion = tt.snapshotSchema
+			snapshots.(*memorySnapshotStore).snapshots[o.ID()] = snapshot
+			events.readFromVersions = nil
+
+			// When
+			loaded, err := repo.FindByID(ctx, string(o.ID()))
+
+			// Then
+			require.NoError(err)
+			require.Equal([]int{tt.wantReadFromVersion}, events.readFromVersions)
+			require.Equal(OrderStatePaid, loaded.State())
+			require.Equal(3, loaded.Version())
+			require.Equal(o.OrderItems(), loaded.OrderItems())
+			require.Equal(o.CustomerID(), loaded.CustomerID())
+		})
+	}
+}
+
+// BenchmarkEventSourcedRepository_FindByID compares loading an order with a long event history
+// from the SQL event store with and without snapshots.
+func BenchmarkEventSourcedRepository_FindByID(b *testing.B) {
+	const historyLength = 1000
+
+	for _, snapshotEvery := range []int{0, 100} {
+		name := "without snapshots"
+		if snapshotEvery > 0 {
+			name = fmt.Sprintf("with snapshots every %d events", snapshotEvery)
+		}
+
+		b.Run(name, func(b *testing.B) {
+			ctx := context.Background()
+			tx := beginTestTx(b, openTestDB(b))
+			var opts []EventSourcedRepositoryOption
+			if snapshotEvery > 0 {
+				opts = append(opts, WithSnapshots(NewSQLSnapshotStore(tx), snapshotEvery))
+			}
+			repo := NewEventSourcedRepository(NewSQLEventStore(tx), opts...)
+
+			// The life cycle of an order is short, so the history is padded with events that
+			// don't change anything to make it long enough to matter.
+			o := newTestOrder(b)
+			for o.Version() < historyLength {
+				o.raise(OrderCreated{OrderID: o.id, CustomerID: o.customerID, Items: o.orderItems, State: o.state})
+			}
+			require.NoError(b, repo.Upsert(ctx, o))
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.FindByID(ctx, string(o.ID())); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package order
+
+import (
+	"context"
+	"sync"
+)
+
+// memorySnapshotStore implements the SnapshotStore interface in memory. It only keeps the latest
+// snapshot of every order.
+type memorySnapshotStore struct {
+	mu        sync.RWMutex
+	snapshots map[ID]Snapshot
+}
+
+func NewMemorySnapshotStore() SnapshotStore {
+	return &memorySnapshotStore{snapshots: make(map[ID]Snapshot)}
+}
+
+func (s *memorySnapshotStore) Save(ctx context.Context, snapshot Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if latest, ok := s.snapshots[snapshot.AggregateID]; ok && latest.AggregateVersion >= snapshot.AggregateVersion {
+		return nil
+	}
+	s.snapshots[snapshot.AggregateID] = snapshot
+
+	return nil
+}
+
+func (s *memorySnapshotStore) Latest(ctx context.Context, aggregateID ID) (Snapshot, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snapshot, ok := s.snapshots[aggregateID]
+	return snapshot, ok, nil
+}
@@ -0,0 +1,47 @@
+package order
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+const (
+	snapshotStoreInsert       = "INSERT INTO order_snapshots (aggregate_id, aggregate_version, schema_version, data, created_at) VALUES ($1, $2, $3, $4, $5);"
+	snapshotStoreSelectLatest = "SELECT aggregate_version, schema_version, data FROM order_snapshots WHERE aggregate_id = $1 ORDER BY aggregate_version DESC LIMIT 1;"
+)
+
+// sqlSnapshotStore implements the SnapshotStore interface using the order_snapshots table
+type sqlSnapshotStore struct {
+	tx Tx
+}
+
+func NewSQLSnapshotStore(tx Tx) SnapshotStore {
+	return &sqlSnapshotStore{tx: tx}
+}
+
+func (s *sqlSnapshotStore) Save(ctx context.Context, snapshot Snapshot) error {
+	createdAt := now().UTC().Format(timestampLayout)
+	_, err := s.tx.ExecContext(ctx, snapshotStoreInsert, string(snapshot.AggregateID), snapshot.AggregateVersion,
+		snapshot.SchemaVersion, string(snapshot.Data), createdAt)
+
+	return err
+}
+
+func (s *sqlSnapshotStore) Latest(ctx context.Context, aggregateID ID) (Snapshot, bool, error) {
+	snapshot := Snapshot{AggregateID: aggregateID}
+	var data string
+
+	err := s.tx.QueryRowContext(ctx, snapshotStoreSelectLatest, string(aggregateID)).
+		Scan(&snapshot.AggregateVersion, &snapshot.SchemaVersion, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Snapshot{}, false, nil
+	}
+	if err != nil {
+		return Snapshot{}, false, fmt.Errorf("could not read latest snapshot of order = %s: %w", aggregateID, err)
+	}
+	snapshot.Data = []byte(data)
+
+	return snapshot, true, nil
+}
@@ -0,0 +1,94 @@
+package order
+
+import (
+	"fmt"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+)
+
+// transition is a legal change of an Order's state. The guard, if any, enforces the business
+// rules that must hold for the transition to be allowed.
+type transition struct {
+	from  OrderState
+	to    OrderState
+	guard func(o *Order) error
+}
+
+// transitions is the order life cycle. Any change of state not listed here is illegal.
+var transitions = []transition{
+	{from: OrderStatePending, to: OrderStateSubmitted, guard: hasOrderItems},
+	{from: OrderStatePending, to: OrderStateCancelled},
+	{from: OrderStateSubmitted, to: OrderStateCancelled},
+	{from: OrderStateSubmitted, to: OrderStatePaid},
+	{from: OrderStatePaid, to: OrderStatePicking},
+	{from: OrderStatePaid, to: OrderStateRefunded},
+	{from: OrderStatePicking, to: OrderStateShipped},
+	{from: OrderStateShipped, to: OrderStateDelivered},
+	{from: OrderStateDelivered, to: OrderStateReturned},
+	{from: OrderStateReturned, to: OrderStateRefunded},
+}
+
+// ErrIllegalTransition is wrapped by every TransitionError
+var ErrIllegalTransition = apperror.New(apperror.CategoryConflict, "ORDER_ILLEGAL_TRANSITION", "illegal order state transition")
+
+// TransitionError is returned by aggregate commands that would change an Order's state in a way
+// that isn't allowed
+type TransitionError struct {
+	From   OrderState
+	To     OrderState
+	Reason error // the guard that rejected the transition, nil if the transition isn't in the life cycle
+}
+
+func (e *TransitionError) Error() string {
+	if e.Reason != nil {
+		return fmt.Sprintf("cannot transition order from %s to %s: %s", e.From, e.To, e.Reason)
+	}
+
+	return fmt.Sprintf("cannot transition order from %s to %s", e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrIllegalTransition
+}
+
+// As makes the error categorized as ErrIllegalTransition, with the states as its details
+func (e *TransitionError) As(target any) bool {
+	appErr, ok := target.(**apperror.Error)
+	if !ok {
+		return false
+	}
+	*appErr = ErrIllegalTransition.WithDetail("from", e.From.String()).WithDetail("to", e.To.String())
+
+	return true
+}
+
+func (e *TransitionError) Unwrap() error {
+	return e.Reason
+}
+
+// checkTransition returns a TransitionError unless o may transition to the given state
+func checkTransition(o *Order, to OrderState) error {
+	for _, t := range transitions {
+		if t.from != o.state || t.to != to {
+			continue
+		}
+
+		if t.guard != nil {
+			if err := t.guard(o); err != nil {
+				return &TransitionError{From: o.state, To: to, Reason: err}
+			}
+		}
+
+		return nil
+	}
+
+	return &TransitionError{From: o.state, To: to}
+}
+
+func hasOrderItems(o *Order) error {
+	if len(o.orderItems) == 0 {
+		return fmt.Errorf("order has no items")
+	}
+
+	return nil
+}
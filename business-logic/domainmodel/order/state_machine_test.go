@@ -0,0 +1,101 @@
+package order
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+)
+
+func TestOrder_LifeCycle(t *testing.T) {
+	item := OrderItem{ArticleNo: "A1", Quantity: 1}
+	cancel := func(o *Order) error { return o.Cancel("changed my mind") }
+
+	tests := []struct {
+		name      string
+		state     OrderState
+		items     []OrderItem
+		command   func(o *Order) error
+		wantState OrderState
+		wantErr   *TransitionError
+	}{
+		{name: "should submit pending order", state: OrderStatePending, items: []OrderItem{item}, command: (*Order).Submit, wantState: OrderStateSubmitted},
+		{name: "should cancel pending order", state: OrderStatePending, command: cancel, wantState: OrderStateCancelled},
+		{name: "should cancel submitted order", state: OrderStateSubmitted, command: cancel, wantState: OrderStateCancelled},
+		{name: "should pay submitted order", state: OrderStateSubmitted, command: (*Order).Pay, wantState: OrderStatePaid},
+		{name: "should start picking paid order", state: OrderStatePaid, command: (*Order).StartPicking, wantState: OrderStatePicking},
+		{name: "should refund paid order", state: OrderStatePaid, command: (*Order).Refund, wantState: OrderStateRefunded},
+		{name: "should ship picked order", state: OrderStatePicking, command: (*Order).Ship, wantState: OrderStateShipped},
+		{name: "should deliver shipped order", state: OrderStateShipped, command: (*Order).Deliver, wantState: OrderStateDelivered},
+		{name: "should return delivered order", state: OrderStateDelivered, command: (*Order).Return, wantState: OrderStateReturned},
+		{name: "should refund returned order", state: OrderStateReturned, command: (*Order).Refund, wantState: OrderStateRefunded},
+		{
+			name:      "should not submit order without items",
+			state:     OrderStatePending,
+			command:   (*Order).Submit,
+			wantState: OrderStatePending,
+			wantErr:   &TransitionError{From: OrderStatePending, To: OrderStateSubmitted},
+		},
+		{
+			name:      "should not cancel paid order",
+			state:     OrderStatePaid,
+			command:   cancel,
+			wantState: OrderStatePaid,
+			wantErr:   &TransitionError{From: OrderStatePaid, To: OrderStateCancelled},
+		},
+		{
+			name:      "should not ship order that isn't picked",
+			state:     OrderStatePaid,
+			command:   (*Order).Ship,
+			wantState: OrderStatePaid,
+			wantErr:   &TransitionError{From: OrderStatePaid, To: OrderStateShipped},
+		},
+		{
+			name:      "should not refund delivered order that isn't returned",
+			state:     OrderStateDelivered,
+			command:   (*Order).Refund,
+			wantState: OrderStateDelivered,
+			wantErr:   &TransitionError{From: OrderStateDelivered, To: OrderStateRefunded},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			o, err := ReconstituteOrder("id", "customer-id", tt.items, tt.state, Pricing{}, 1)
+			require.NoError(err)
+
+			// When
+			err = tt.command(o)
+
+			// Then
+			require.Equal(tt.wantState, o.State())
+			if tt.wantErr == nil {
+				require.NoError(err)
+				return
+			}
+
+			var transitionErr *TransitionError
+			require.True(errors.As(err, &transitionErr))
+			require.True(errors.Is(err, ErrIllegalTransition))
+			appErr, ok := apperror.As(err)
+			require.True(ok)
+			require.Equal(apperror.CategoryConflict, appErr.Category)
+			require.Equal(map[string]string{"from": tt.wantErr.From.String(), "to": tt.wantErr.To.String()}, appErr.Details)
+			require.Equal(tt.wantErr.From, transitionErr.From)
+			require.Equal(tt.wantErr.To, transitionErr.To)
+		})
+	}
+}
+
+func TestNewOrderState(t *testing.T) {
+	require := require.New(t)
+
+	state, err := NewOrderState(int(OrderStateRefunded))
+	require.NoError(err)
+	require.Equal("refunded", state.String())
+
+	_, err = NewOrderState(9)
+	require.Error(err)
+}
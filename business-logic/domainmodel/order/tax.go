@@ -0,0 +1,79 @@
+package order
+
+import (
+	"fmt"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+)
+
+// ErrInvalidTax is returned when the tax of an order is negative or its lines don't add up
+var ErrInvalidTax = apperror.New(apperror.CategoryValidation, "ORDER_INVALID_TAX", "invalid tax")
+
+// TaxBreakdown is the tax of an order, broken down by rate
+type TaxBreakdown struct {
+	Jurisdiction string    `json:"jurisdiction"`
+	Inclusive    bool      `json:"inclusive"` // whether the tax is included in the prices or added on top of them
+	Lines        []TaxLine `json:"lines"`
+	Total        Money     `json:"total"`
+}
+
+// TaxLine is the tax of everything in an order that's taxed at the same rate
+type TaxLine struct {
+	Category string `json:"category"` // the category of the articles the rate applies to
+	Rate     int64  `json:"rate"`     // in basis points, e.g. 2500 for 25%
+	Taxable  Money  `json:"taxable"`  // the amount the tax is calculated from
+	Tax      Money  `json:"tax"`
+}
+
+// Tax returns the tax of the order, if it has been calculated
+func (o *Order) Tax() (TaxBreakdown, bool) {
+	if o.pricing.Tax == nil {
+		return TaxBreakdown{}, false
+	}
+
+	return copyTaxBreakdown(*o.pricing.Tax), true
+}
+
+// ApplyTax is an aggregate command that records the tax of the order. The total of the lines must
+// add up to the total of the breakdown and be in the currency of the order.
+func (o *Order) ApplyTax(tax TaxBreakdown) error {
+	// check business rules and invariants
+	if o.state != OrderStatePending {
+		return fmt.Errorf("could not apply tax to order = %s: %w", o.id, ErrNotPending)
+	}
+
+	var linesTotal Money
+	for _, line := range tax.Lines {
+		if line.Tax.IsNegative() || line.Rate < 0 {
+			return fmt.Errorf("tax of order = %s must not be negative: %w", o.id, ErrInvalidTax)
+		}
+
+		var err error
+		if linesTotal, err = linesTotal.Add(line.Tax); err != nil {
+			return fmt.Errorf("could not apply tax to order = %s: %w", o.id, err)
+		}
+	}
+
+	if comparison, err := linesTotal.Compare(tax.Total); err != nil {
+		return fmt.Errorf("could not apply tax to order = %s: %w", o.id, err)
+	} else if comparison != 0 {
+		return fmt.Errorf("tax lines of order = %s must add up to the total tax: %w", o.id, ErrInvalidTax)
+	}
+
+	pricing := o.pricing
+	tax = copyTaxBreakdown(tax)
+	pricing.Tax = &tax
+	total, err := o.totalWith(pricing)
+	if err != nil {
+		return err
+	}
+
+	o.raise(OrderTaxApplied{Tax: tax, Total: total})
+
+	return nil
+}
+
+func copyTaxBreakdown(tax TaxBreakdown) TaxBreakdown {
+	tax.Lines = append([]TaxLine(nil), tax.Lines...)
+	return tax
+}
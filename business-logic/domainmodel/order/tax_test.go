@@ -0,0 +1,108 @@
+package order
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+)
+
+func TestOrder_ApplyTax(t *testing.T) {
+	eur := func(amount int64) Money { return Money{amount: amount, currency: "EUR"} }
+	items := []OrderItem{{ArticleNo: "A1", Quantity: 2, UnitPrice: eur(1000)}}
+
+	tests := []struct {
+		name       string
+		state      OrderState
+		tax        TaxBreakdown
+		wantTotal  Money
+		wantErr    bool
+		wantErrIs  error
+		wantStatus int // the HTTP status of the error
+	}{
+		{
+			name:      "should add exclusive tax to total",
+			state:     OrderStatePending,
+			tax:       TaxBreakdown{Jurisdiction: "US-CA", Lines: []TaxLine{{Category: "standard", Rate: 725, Taxable: eur(2000), Tax: eur(145)}}, Total: eur(145)},
+			wantTotal: eur(2145),
+		},
+		{
+			name:      "should not add inclusive tax to total",
+			state:     OrderStatePending,
+			tax:       TaxBreakdown{Jurisdiction: "SE", Inclusive: true, Lines: []TaxLine{{Category: "standard", Rate: 2500, Taxable: eur(2000), Tax: eur(400)}}, Total: eur(400)},
+			wantTotal: eur(2000),
+		},
+		{
+			name:       "should not apply tax to submitted order",
+			state:      OrderStateSubmitted,
+			tax:        TaxBreakdown{Jurisdiction: "SE"},
+			wantErr:    true,
+			wantErrIs:  ErrNotPending,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "should not apply tax whose lines don't add up",
+			state:      OrderStatePending,
+			tax:        TaxBreakdown{Jurisdiction: "SE", Lines: []TaxLine{{Category: "standard", Rate: 2500, Taxable: eur(2000), Tax: eur(400)}}, Total: eur(401)},
+			wantErr:    true,
+			wantErrIs:  ErrInvalidTax,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should not apply negative tax",
+			state:      OrderStatePending,
+			tax:        TaxBreakdown{Jurisdiction: "SE", Lines: []TaxLine{{Category: "standard", Rate: 2500, Taxable: eur(2000), Tax: eur(-400)}}, Total: eur(-400)},
+			wantErr:    true,
+			wantErrIs:  ErrInvalidTax,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should not apply tax in another currency",
+			state:      OrderStatePending,
+			tax:        TaxBreakdown{Jurisdiction: "SE", Lines: []TaxLine{{Category: "standard", Rate: 2500, Tax: Money{amount: 1, currency: "SEK"}}}, Total: Money{amount: 1, currency: "SEK"}},
+			wantErr:    true,
+			wantErrIs:  ErrCurrencyMismatch,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			o, _ := ReconstituteOrder("order-id", "customer-id", items, tt.state, Pricing{}, 1)
+
+			// When
+			err := o.ApplyTax(tt.tax)
+
+			// Then
+			if tt.wantErr {
+				require.Error(err)
+				if tt.wantErrIs != nil {
+					require.True(errors.Is(err, tt.wantErrIs), "expected %v to wrap %v", err, tt.wantErrIs)
+				}
+				require.Equal(tt.wantStatus, apperror.NewProblem(httptest.NewRequest(http.MethodPost, "/orders/order-id/tax", nil), err).Status)
+				_, ok := o.Tax()
+				require.False(ok)
+				return
+			}
+			require.NoError(err)
+
+			total, err := o.Total()
+			require.NoError(err)
+			require.Equal(tt.wantTotal, total)
+			tax, ok := o.Tax()
+			require.True(ok)
+			require.Equal(tt.tax, tax)
+
+			// When the discounts change
+			require.NoError(o.ApplyDiscounts(nil))
+
+			// Then the tax must be calculated again
+			_, ok = o.Tax()
+			require.False(ok)
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/google/uuid"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
 )
 
 type ID string
@@ -12,7 +13,7 @@ type ID string
 func NewID(id string) (ID, error) {
 	// validate input
 	if err := tryParseUUID(id); err != nil {
-		return "", fmt.Errorf("could not parse id: %w", err)
+		return "", apperror.Validation("ORDER_INVALID_ID", "could not parse id: %w", err)
 	}
 
 	// return valid value object
@@ -25,56 +26,96 @@ type CustomerID string
 func NewCustomerID(id string) (CustomerID, error) {
 	// validate input
 	if err := tryParseUUID(id); err != nil {
-		return "", fmt.Errorf("could not parse customer id: %w", err)
+		return "", apperror.Validation("CUSTOMER_INVALID_ID", "could not parse customer id: %w", err)
 	}
 
 	// return valid value object
 	return CustomerID(id), nil
 }
 
+// ErrInvalidOrderItem is returned when an order item breaks the rules for what an order may contain
+var ErrInvalidOrderItem = apperror.New(apperror.CategoryValidation, "ORDER_INVALID_ITEM", "invalid order item")
+
 type OrderItem struct {
-	ArticleNo string
-	Quantity  int
+	ArticleNo string `json:"articleNo"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice Money  `json:"unitPrice"`
 }
 
 // NewOrderItem is a factory function for instantiating an OrderItem value object
-func NewOrderItem(articleNo string, quantity int) (OrderItem, error) {
+func NewOrderItem(articleNo string, quantity int, unitPrice Money) (OrderItem, error) {
 	// validate input and enforce business rules
 	if articleNo == "" || len(articleNo) > 8 {
-		return OrderItem{}, fmt.Errorf("invalid articleNo")
+		return OrderItem{}, fmt.Errorf("articleNo = %q must be 1-8 characters: %w", articleNo, ErrInvalidOrderItem)
 	}
 
 	// validate input and enforce business rules
 	if quantity <= 0 {
-		return OrderItem{}, fmt.Errorf("invalid quantity")
+		return OrderItem{}, fmt.Errorf("quantity = %d must be positive: %w", quantity, ErrInvalidOrderItem)
+	}
+
+	// validate input and enforce business rules
+	if quantity > MaxItemQuantity {
+		return OrderItem{}, fmt.Errorf("quantity = %d must not exceed %d: %w", quantity, MaxItemQuantity, ErrInvalidOrderItem)
 	}
 
 	// validate input and enforce business rules
-	if quantity > 100 {
-		return OrderItem{}, fmt.Errorf("quantity too large")
+	if unitPrice.Currency() == "" || unitPrice.IsNegative() {
+		return OrderItem{}, fmt.Errorf("unit price = %s must have a currency and not be negative: %w", unitPrice, ErrInvalidOrderItem)
 	}
 
 	// return valid value object
-	return OrderItem{ArticleNo: articleNo, Quantity: quantity}, nil
+	return OrderItem{ArticleNo: articleNo, Quantity: quantity, UnitPrice: unitPrice}, nil
+}
+
+// LineTotal is the unit price multiplied by the quantity
+func (i OrderItem) LineTotal() (Money, error) {
+	return i.UnitPrice.Multiply(int64(i.Quantity))
 }
 
 const (
 	OrderStateCancelled OrderState = 0
 	OrderStatePending   OrderState = 1
 	OrderStateSubmitted OrderState = 2
+	OrderStatePaid      OrderState = 3
+	OrderStatePicking   OrderState = 4
+	OrderStateShipped   OrderState = 5
+	OrderStateDelivered OrderState = 6
+	OrderStateReturned  OrderState = 7
+	OrderStateRefunded  OrderState = 8
 )
 
+var orderStateNames = map[OrderState]string{
+	OrderStateCancelled: "cancelled",
+	OrderStatePending:   "pending",
+	OrderStateSubmitted: "submitted",
+	OrderStatePaid:      "paid",
+	OrderStatePicking:   "picking",
+	OrderStateShipped:   "shipped",
+	OrderStateDelivered: "delivered",
+	OrderStateReturned:  "returned",
+	OrderStateRefunded:  "refunded",
+}
+
 type OrderState int
 
 // NewOrderState is a factory function for instantiating an OrderState value object
 func NewOrderState(state int) (OrderState, error) {
-	if state < 0 || state > 2 {
+	if _, ok := orderStateNames[OrderState(state)]; !ok {
 		return OrderStateCancelled, fmt.Errorf("invalid order state")
 	}
 
 	return OrderState(state), nil
 }
 
+func (s OrderState) String() string {
+	if name, ok := orderStateNames[s]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
 func tryParseUUID(id string) error {
 	_, err := uuid.Parse(id)
 	return err
@@ -0,0 +1,5 @@
+// Package orderpb contains the protobuf messages and gRPC service of the order API. The code is
+// generated from order.proto.
+package orderpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative order.proto
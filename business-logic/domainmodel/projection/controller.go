@@ -0,0 +1,156 @@
+package projection
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+)
+
+type (
+	MoneyResponseModel struct {
+		Amount   string `json:"amount"` // in the major unit of the currency, e.g. 12.50
+		Currency string `json:"currency"`
+	}
+
+	OrderSummaryResponseModel struct {
+		OrderID   string             `json:"orderId"`
+		State     string             `json:"state"`
+		ItemCount int                `json:"itemCount"`
+		Total     MoneyResponseModel `json:"total"`
+		CreatedAt string             `json:"createdAt"`
+		UpdatedAt string             `json:"updatedAt"`
+	}
+
+	CustomerOrdersResponseModel struct {
+		CustomerID string                      `json:"customerId"`
+		Orders     []OrderSummaryResponseModel `json:"orders"`
+	}
+
+	StateCountsResponseModel struct {
+		Counts map[string]int `json:"counts"`
+	}
+
+	DailyTotalResponseModel struct {
+		Day        string               `json:"day"`
+		OrderCount int                  `json:"orderCount"`
+		ItemCount  int                  `json:"itemCount"`
+		Totals     []MoneyResponseModel `json:"totals"` // in each currency
+	}
+
+	DailyTotalsResponseModel struct {
+		Totals []DailyTotalResponseModel `json:"totals"`
+	}
+)
+
+type orderQuerier interface {
+	OrdersByCustomer(ctx context.Context, customerID string) ([]OrderSummary, error)
+	CountsByState(ctx context.Context) (map[string]int, error)
+	DailySubmittedTotals(ctx context.Context, from, to time.Time) ([]DailyTotal, error)
+}
+
+// QueryController exposes the order read models over HTTP
+type QueryController struct {
+	orderQuerier orderQuerier
+}
+
+func NewQueryController(orderQuerier orderQuerier) *QueryController {
+	return &QueryController{orderQuerier: orderQuerier}
+}
+
+// OrdersByCustomer lists the orders of the customer given by the customerId query parameter
+func (c *QueryController) OrdersByCustomer(w http.ResponseWriter, r *http.Request) {
+	customerID := r.URL.Query().Get("customerId")
+	if customerID == "" {
+		apperror.WriteProblem(w, r, apperror.Validation("INVALID_REQUEST", "query parameter customerId is required"))
+		return
+	}
+
+	summaries, err := c.orderQuerier.OrdersByCustomer(r.Context(), customerID)
+	if err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	respModel := CustomerOrdersResponseModel{
+		CustomerID: customerID,
+		Orders:     make([]OrderSummaryResponseModel, 0, len(summaries)),
+	}
+	for _, summary := range summaries {
+		respModel.Orders = append(respModel.Orders, OrderSummaryResponseModel{
+			OrderID:   summary.OrderID,
+			State:     summary.State,
+			ItemCount: summary.ItemCount,
+			Total:     MoneyResponseModel{Amount: summary.Total.Decimal(), Currency: string(summary.Total.Currency())},
+			CreatedAt: summary.CreatedAt.Format(time.RFC3339),
+			UpdatedAt: summary.UpdatedAt.Format(time.RFC3339),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, respModel)
+}
+
+// CountsByState returns the number of orders in each state
+func (c *QueryController) CountsByState(w http.ResponseWriter, r *http.Request) {
+	counts, err := c.orderQuerier.CountsByState(r.Context())
+	if err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, StateCountsResponseModel{Counts: counts})
+}
+
+// DailySubmittedTotals returns the daily totals between the from and to query parameters
+// (yyyy-mm-dd, inclusive), which default to the last 30 days
+func (c *QueryController) DailySubmittedTotals(w http.ResponseWriter, r *http.Request) {
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -30)
+
+	var err error
+	if raw := r.URL.Query().Get("from"); raw != "" {
+		if from, err = time.Parse(dayLayout, raw); err != nil {
+			apperror.WriteProblem(w, r, apperror.Validation("INVALID_REQUEST", "query parameter from must be formatted as yyyy-mm-dd"))
+			return
+		}
+	}
+	if raw := r.URL.Query().Get("to"); raw != "" {
+		if to, err = time.Parse(dayLayout, raw); err != nil {
+			apperror.WriteProblem(w, r, apperror.Validation("INVALID_REQUEST", "query parameter to must be formatted as yyyy-mm-dd"))
+			return
+		}
+	}
+
+	totals, err := c.orderQuerier.DailySubmittedTotals(r.Context(), from, to)
+	if err != nil {
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	respModel := DailyTotalsResponseModel{Totals: make([]DailyTotalResponseModel, 0, len(totals))}
+	for _, total := range totals {
+		amounts := make([]MoneyResponseModel, 0, len(total.Totals))
+		for _, money := range total.Totals {
+			amounts = append(amounts, MoneyResponseModel{Amount: money.Decimal(), Currency: string(money.Currency())})
+		}
+
+		respModel.Totals = append(respModel.Totals, DailyTotalResponseModel{
+			Day:        total.Day,
+			OrderCount: total.OrderCount,
+			ItemCount:  total.ItemCount,
+			Totals:     amounts,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, respModel)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	body, _ := json.Marshal(v)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
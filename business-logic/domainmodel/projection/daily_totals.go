@@ -0,0 +1,60 @@
+package projection
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+const (
+	DailySubmittedTotalsName = "daily-submitted-totals"
+
+	dailyTotalAdd         = "INSERT INTO daily_submitted_totals (day, order_count, item_count) VALUES ($1, 1, $2) ON CONFLICT (day) DO UPDATE SET order_count = daily_submitted_totals.order_count + 1, item_count = daily_submitted_totals.item_count + excluded.item_count;"
+	dailyCurrencyTotalAdd = "INSERT INTO daily_submitted_currency_totals (day, currency, total) VALUES ($1, $2, $3) ON CONFLICT (day, currency) DO UPDATE SET total = daily_submitted_currency_totals.total + excluded.total;"
+
+	dayLayout = "2006-01-02"
+)
+
+// DailySubmittedTotals maintains the daily_submitted_totals read model, which sums up the orders
+// submitted each day (UTC), and the daily_submitted_currency_totals read model, which sums up
+// their totals in each currency
+type DailySubmittedTotals struct{}
+
+func (DailySubmittedTotals) Name() string {
+	return DailySubmittedTotalsName
+}
+
+func (DailySubmittedTotals) Handle(ctx context.Context, tx *sql.Tx, e order.Event) error {
+	submitted, ok := e.Payload().(order.OrderSubmitted)
+	if !ok {
+		return nil
+	}
+
+	day := e.OccurredAt().UTC().Format(dayLayout)
+	if _, err := tx.ExecContext(ctx, dailyTotalAdd, day, itemCount(submitted.Items)); err != nil {
+		return fmt.Errorf("could not update submitted totals of day = %s: %w", day, err)
+	}
+
+	// orders submitted before orders were priced have no total
+	if submitted.Total == (order.Money{}) {
+		return nil
+	}
+
+	currency := string(submitted.Total.Currency())
+	if _, err := tx.ExecContext(ctx, dailyCurrencyTotalAdd, day, currency, submitted.Total.Amount()); err != nil {
+		return fmt.Errorf("could not update submitted %s total of day = %s: %w", currency, day, err)
+	}
+
+	return nil
+}
+
+func (DailySubmittedTotals) Reset(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM daily_submitted_totals;"); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, "DELETE FROM daily_submitted_currency_totals;")
+	return err
+}
@@ -0,0 +1,163 @@
+package projection
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+const (
+	OrderSummariesName = "order-summaries"
+
+	summaryInsert      = "INSERT INTO order_summaries (order_id, customer_id, state, item_count, currency, total, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $7);"
+	summarySelectState = "SELECT state FROM order_summaries WHERE order_id = $1;"
+	summaryUpdateState = "UPDATE order_summaries SET state = $1, updated_at = $2 WHERE order_id = $3;"
+	summaryUpdateTotal = "UPDATE order_summaries SET currency = $1, total = $2, updated_at = $3 WHERE order_id = $4;"
+	summaryAddItems    = "UPDATE order_summaries SET item_count = item_count + $1, updated_at = $2 WHERE order_id = $3;"
+	stateCountAdd      = "INSERT INTO order_state_counts (state, order_count) VALUES ($1, $2) ON CONFLICT (state) DO UPDATE SET order_count = order_state_counts.order_count + excluded.order_count;"
+
+	// the timestamps have a fixed width, so they can be compared and sorted as text
+	timestampLayout = order.CreatedAtLayout
+)
+
+// OrderSummaries maintains the order_summaries read model, which lists the orders of customers,
+// and the order_state_counts read model, which counts orders by state
+type OrderSummaries struct{}
+
+func (OrderSummaries) Name() string {
+	return OrderSummariesName
+}
+
+func (OrderSummaries) Handle(ctx context.Context, tx *sql.Tx, e order.Event) error {
+	occurredAt := e.OccurredAt().UTC().Format(timestampLayout)
+	if total, ok := totalAfter(e.Payload()); ok {
+		_, err := tx.ExecContext(ctx, summaryUpdateTotal, string(total.Currency()), total.Amount(), occurredAt, string(e.AggregateID()))
+		if err != nil {
+			return fmt.Errorf("could not update total of order summary: %w", err)
+		}
+	}
+
+	if delta, ok := itemCountDelta(e.Payload()); ok {
+		if _, err := tx.ExecContext(ctx, summaryAddItems, delta, occurredAt, string(e.AggregateID())); err != nil {
+			return fmt.Errorf("could not update item count of order summary: %w", err)
+		}
+	}
+
+	state, ok := order.StateAfter(e.Payload())
+	if !ok {
+		return nil
+	}
+
+	if created, ok := e.Payload().(order.OrderCreated); ok {
+		total, err := itemTotal(created.Items)
+		if err != nil {
+			return fmt.Errorf("could not calculate total of order = %s: %w", created.OrderID, err)
+		}
+
+		_, err = tx.ExecContext(ctx, summaryInsert, string(created.OrderID), string(created.CustomerID),
+			state.String(), itemCount(created.Items), string(total.Currency()), total.Amount(), occurredAt)
+		if err != nil {
+			return fmt.Errorf("could not insert order summary: %w", err)
+		}
+
+		return addToStateCount(ctx, tx, state.String(), 1)
+	}
+
+	var previousState string
+	if err := tx.QueryRowContext(ctx, summarySelectState, string(e.AggregateID())).Scan(&previousState); err != nil {
+		return fmt.Errorf("could not find order summary of order = %s: %w", e.AggregateID(), err)
+	}
+
+	if _, err := tx.ExecContext(ctx, summaryUpdateState, state.String(), occurredAt, string(e.AggregateID())); err != nil {
+		return fmt.Errorf("could not update order summary: %w", err)
+	}
+
+	if err := addToStateCount(ctx, tx, previousState, -1); err != nil {
+		return err
+	}
+
+	return addToStateCount(ctx, tx, state.String(), 1)
+}
+
+func (OrderSummaries) Reset(ctx context.Context, tx *sql.Tx) error {
+	for _, query := range []string{"DELETE FROM order_summaries;", "DELETE FROM order_state_counts;"} {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addToStateCount(ctx context.Context, tx *sql.Tx, state string, delta int) error {
+	if _, err := tx.ExecContext(ctx, stateCountAdd, state, delta); err != nil {
+		return fmt.Errorf("could not update order count of state = %s: %w", state, err)
+	}
+
+	return nil
+}
+
+func itemCount(items []order.OrderItem) int {
+	var count int
+	for _, item := range items {
+		count += item.Quantity
+	}
+
+	return count
+}
+
+// itemCountDelta returns how much the number of items of an order changes by, if the event changes it
+func itemCountDelta(payload order.EventPayload) (int, bool) {
+	switch p := payload.(type) {
+	case order.OrderItemAdded:
+		return p.Item.Quantity, true
+	case order.OrderItemRemoved:
+		return -p.Quantity, true
+	case order.OrderItemQuantityChanged:
+		return p.Quantity - p.PreviousQuantity, true
+	default:
+		return 0, false
+	}
+}
+
+// totalAfter returns the total of an order after the event, if the event changes it
+func totalAfter(payload order.EventPayload) (order.Money, bool) {
+	switch p := payload.(type) {
+	case order.OrderItemAdded:
+		return p.Total, true
+	case order.OrderItemRemoved:
+		return p.Total, true
+	case order.OrderItemQuantityChanged:
+		return p.Total, true
+	case order.OrderShippingCharged:
+		return p.Total, true
+	case order.OrderDiscountsApplied:
+		return p.Total, true
+	case order.OrderTaxApplied:
+		return p.Total, true
+	case order.OrderSubmitted:
+		// orders submitted before orders were priced have no total
+		return p.Total, p.Total != order.Money{}
+	default:
+		return order.Money{}, false
+	}
+}
+
+// itemTotal sums the line totals of the items, which are zero without a currency if there are none
+func itemTotal(items []order.OrderItem) (order.Money, error) {
+	var total order.Money
+	for _, item := range items {
+		lineTotal, err := item.LineTotal()
+		if err != nil {
+			return order.Money{}, err
+		}
+
+		if total, err = total.Add(lineTotal); err != nil {
+			return order.Money{}, err
+		}
+	}
+
+	return total, nil
+}
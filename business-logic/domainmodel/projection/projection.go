@@ -0,0 +1,217 @@
+/*
+Package projection is the query side of orders. Projections consume the domain events in the
+order event store and maintain denormalized read models that are cheap to query. Every projection
+keeps a checkpoint of the last event it has handled, which is updated in the same transaction as
+its read models so that no event is handled twice or skipped. The events of orders are in the
+event store whether the orders are stored as streams of events or by their current state.
+*/
+package projection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/sqltx"
+)
+
+const (
+	selectCheckpoint = "SELECT position FROM projection_checkpoints WHERE name = $1;"
+	upsertCheckpoint = "INSERT INTO projection_checkpoints (name, position) VALUES ($1, $2) ON CONFLICT (name) DO UPDATE SET position = excluded.position;"
+
+	defaultBatchSize = 100
+	maxRetryBackoff  = time.Minute
+)
+
+// Projection maintains read models from order domain events
+type Projection interface {
+	// Name identifies the projection's checkpoint
+	Name() string
+
+	// Handle updates the read models according to the event. ctx carries the shared transaction
+	// tx belongs to, see sqltx.Begin, so the changes of application services that begin their
+	// transactions with a sqltx.Beginner are committed together with the checkpoint.
+	Handle(ctx context.Context, tx *sql.Tx, e order.Event) error
+
+	// Reset deletes the read models so they can be rebuilt from scratch
+	Reset(ctx context.Context, tx *sql.Tx) error
+}
+
+// Runner feeds the events of the event store to projections
+type Runner struct {
+	db          *sql.DB
+	projections []Projection
+	batchSize   int
+}
+
+func NewRunner(db *sql.DB, projections ...Projection) *Runner {
+	return &Runner{db: db, projections: projections, batchSize: defaultBatchSize}
+}
+
+// Run catches up all projections every interval until ctx is done. Failed catch ups are reported
+// to onError, or logged if it's nil, and retried with a backoff that doubles with every
+// consecutive failure, up to a minute.
+func (r *Runner) Run(ctx context.Context, interval time.Duration, onError func(err error)) error {
+	if onError == nil {
+		onError = func(err error) { log.Printf("projection runner: %v", err) }
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	var failures int
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+		}
+
+		delay := interval
+		if err := r.CatchUp(ctx); err != nil && ctx.Err() == nil {
+			onError(err)
+			failures++
+			delay = retryBackoff(interval, failures)
+		} else {
+			failures = 0
+		}
+		timer.Reset(delay)
+	}
+}
+
+// retryBackoff returns how long to wait after the given number of consecutive failures
+func retryBackoff(interval time.Duration, failures int) time.Duration {
+	delay := interval
+	for i := 0; i < failures && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryBackoff && interval < maxRetryBackoff {
+		return maxRetryBackoff
+	}
+
+	return delay
+}
+
+// CatchUp lets every projection handle the events appended since its checkpoint. A projection that
+// fails doesn't hold back the others, and the first error is returned once they've all run.
+func (r *Runner) CatchUp(ctx context.Context) error {
+	var firstErr error
+	for _, p := range r.projections {
+		if err := r.catchUp(ctx, p); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+func (r *Runner) catchUp(ctx context.Context, p Projection) error {
+	for {
+		handled, err := r.handleBatch(ctx, p)
+		if err != nil {
+			return err
+		}
+
+		if handled < r.batchSize {
+			return nil
+		}
+	}
+}
+
+// Rebuild resets the read models of the named projection and projects all events again
+func (r *Runner) Rebuild(ctx context.Context, name string) error {
+	for _, p := range r.projections {
+		if p.Name() != name {
+			continue
+		}
+
+		err := r.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+			if err := p.Reset(ctx, tx); err != nil {
+				return fmt.Errorf("could not reset projection = %s: %w", name, err)
+			}
+
+			return saveCheckpoint(ctx, tx, name, 0)
+		})
+		if err != nil {
+			return err
+		}
+
+		return r.CatchUp(ctx)
+	}
+
+	return fmt.Errorf("unknown projection = %s", name)
+}
+
+// handleBatch lets the projection handle the next batch of events and returns how many it handled
+func (r *Runner) handleBatch(ctx context.Context, p Projection) (int, error) {
+	var handled int
+	err := r.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		checkpoint, err := loadCheckpoint(ctx, tx, p.Name())
+		if err != nil {
+			return err
+		}
+
+		events, err := order.NewSQLEventStore(tx).ReadAll(ctx, checkpoint, r.batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, recorded := range events {
+			if err = p.Handle(ctx, tx, recorded.Event); err != nil {
+				return fmt.Errorf("projection = %s could not handle event at position = %d: %w", p.Name(), recorded.Position, err)
+			}
+			checkpoint = recorded.Position
+		}
+		handled = len(events)
+
+		return saveCheckpoint(ctx, tx, p.Name(), checkpoint)
+	})
+
+	return handled, err
+}
+
+// inTx runs fn in a shared transaction, which is committed unless fn fails or a transaction that
+// joined it was rolled back
+func (r *Runner) inTx(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	ctx, shared, err := sqltx.Begin(ctx, r.db)
+	if err != nil {
+		return err
+	}
+
+	if err = fn(ctx, shared.Tx); err != nil {
+		shared.Rollback()
+		return err
+	}
+
+	if shared.RollbackOnly() {
+		shared.Rollback()
+		return sqltx.ErrRollbackOnly
+	}
+
+	return shared.Commit()
+}
+
+func loadCheckpoint(ctx context.Context, tx *sql.Tx, name string) (int64, error) {
+	var position int64
+	err := tx.QueryRowContext(ctx, selectCheckpoint, name).Scan(&position)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("could not load checkpoint of projection = %s: %w", name, err)
+	}
+
+	return position, nil
+}
+
+func saveCheckpoint(ctx context.Context, tx *sql.Tx, name string, position int64) error {
+	if _, err := tx.ExecContext(ctx, upsertCheckpoint, name, position); err != nil {
+		return fmt.Errorf("could not save checkpoint of projection = %s: %w", name, err)
+	}
+
+	return nil
+}
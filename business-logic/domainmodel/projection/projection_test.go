@@ -0,0 +1,386 @@
+package projection
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/migrations"
+	_ "modernc.org/sqlite"
+)
+
+const customerID = "0f8fad5b-d9cb-469f-a165-70867728950e"
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "projection.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	runner, err := migrations.NewRunner(db, migrations.SQLite)
+	require.NoError(t, err)
+	require.NoError(t, runner.Up(context.Background()))
+
+	return db
+}
+
+// storeOrder appends the order's new events to the event store
+func storeOrder(t *testing.T, db *sql.DB, o *order.Order) {
+	t.Helper()
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, order.NewEventSourcedRepository(order.NewSQLEventStore(tx)).Upsert(context.Background(), o))
+	require.NoError(t, tx.Commit())
+}
+
+func newOrder(t *testing.T, quantity int) *order.Order {
+	t.Helper()
+	unitPrice, err := order.NewMoney(1250, "EUR")
+	require.NoError(t, err)
+	item, err := order.NewOrderItem("A1", quantity, unitPrice)
+	require.NoError(t, err)
+	o, err := order.NewOrder(customerID, []order.OrderItem{item}, order.OrderStatePending)
+	require.NoError(t, err)
+
+	return o
+}
+
+func mustMoney(t *testing.T, amount int64, currency order.Currency) order.Money {
+	t.Helper()
+	money, err := order.NewMoney(amount, currency)
+	require.NoError(t, err)
+
+	return money
+}
+
+func TestRunner(t *testing.T) {
+	// Given
+	require := require.New(t)
+	ctx := context.Background()
+	db := openTestDB(t)
+	runner := NewRunner(db, OrderSummaries{}, DailySubmittedTotals{})
+	runner.batchSize = 2
+	queries := NewQueryService(db)
+	today := time.Now().UTC()
+
+	submitted, cancelled, pending := newOrder(t, 2), newOrder(t, 3), newOrder(t, 1)
+	require.NoError(submitted.Submit())
+	require.NoError(cancelled.Cancel("changed my mind"))
+	storeOrder(t, db, submitted)
+	storeOrder(t, db, cancelled)
+
+	// When
+	require.NoError(runner.CatchUp(ctx))
+
+	// Then
+	counts, err := queries.CountsByState(ctx)
+	require.NoError(err)
+	require.Equal(map[string]int{"submitted": 1, "cancelled": 1}, counts)
+
+	// When more events are appended
+	require.NoError(pending.ChangeQuantity("A1", 4))
+	storeOrder(t, db, pending)
+	require.NoError(runner.CatchUp(ctx))
+
+	// Then only the new events are handled
+	summaries, err := queries.OrdersByCustomer(ctx, customerID)
+	require.NoError(err)
+	require.Len(summaries, 3)
+	require.Equal(string(pending.ID()), summaries[2].OrderID)
+	require.Equal(4, summaries[2].ItemCount)
+	require.Equal("50.00 EUR", summaries[2].Total.String())
+	require.Equal(string(submitted.ID()), summaries[0].OrderID)
+	require.Equal("submitted", summaries[0].State)
+	require.Equal(2, summaries[0].ItemCount)
+	require.Equal("25.00 EUR", summaries[0].Total.String())
+
+	totals, err := queries.DailySubmittedTotals(ctx, today, today)
+	require.NoError(err)
+	require.Equal([]DailyTotal{{Day: today.Format(dayLayout), OrderCount: 1, ItemCount: 2, Totals: []order.Money{mustMoney(t, 2500, "EUR")}}}, totals)
+
+	// When orders are submitted in another currency
+	usdPrice := mustMoney(t, 1000, "USD")
+	usdItem, err := order.NewOrderItem("A2", 1, usdPrice)
+	require.NoError(err)
+	usd, err := order.NewOrder(customerID, []order.OrderItem{usdItem}, order.OrderStatePending)
+	require.NoError(err)
+	require.NoError(usd.Submit())
+	storeOrder(t, db, usd)
+	require.NoError(runner.CatchUp(ctx))
+
+	// Then they're summed up separately
+	totals, err = queries.DailySubmittedTotals(ctx, today, today)
+	require.NoError(err)
+	require.Len(totals, 1)
+	require.Equal(2, totals[0].OrderCount)
+	require.Equal([]order.Money{mustMoney(t, 2500, "EUR"), usdPrice}, totals[0].Totals)
+
+	// When a projection is rebuilt
+	_, err = db.Exec("UPDATE order_state_counts SET order_count = 42;")
+	require.NoError(err)
+	require.NoError(runner.Rebuild(ctx, OrderSummariesName))
+
+	// Then its read models are projected from scratch
+	counts, err = queries.CountsByState(ctx)
+	require.NoError(err)
+	require.Equal(map[string]int{"submitted": 2, "cancelled": 1, "pending": 1}, counts)
+
+	require.Error(runner.Rebuild(ctx, "unknown"))
+}
+
+func TestRunner_StateStoredOrders(t *testing.T) {
+	// Given an order stored by its current state, then submitted
+	require := require.New(t)
+	ctx := context.Background()
+	db := openTestDB(t)
+	runner := NewRunner(db, OrderSummaries{})
+	queries := NewQueryService(db)
+
+	o := newOrder(t, 2)
+	tx, err := db.Begin()
+	require.NoError(err)
+	require.NoError(order.NewRepository(tx).Upsert(ctx, o))
+	require.NoError(tx.Commit())
+
+	tx, err = db.Begin()
+	require.NoError(err)
+	stored, err := order.NewRepository(tx).FindByID(ctx, string(o.ID()))
+	require.NoError(err)
+	require.NoError(stored.Submit())
+	require.NoError(order.NewRepository(tx).Upsert(ctx, stored))
+	require.NoError(tx.Commit())
+
+	// When
+	require.NoError(runner.CatchUp(ctx))
+
+	// Then the read models are projected from the events the repository recorded
+	summaries, err := queries.OrdersByCustomer(ctx, customerID)
+	require.NoError(err)
+	require.Len(summaries, 1)
+	require.Equal(string(o.ID()), summaries[0].OrderID)
+	require.Equal("submitted", summaries[0].State)
+	require.Equal(2, summaries[0].ItemCount)
+}
+
+// flakyProjection fails to handle events until it has failed the given number of times
+type flakyProjection struct {
+	mu       sync.Mutex
+	failures int
+	handled  int
+}
+
+func (p *flakyProjection) Name() string { return "flaky" }
+
+func (p *flakyProjection) Handle(ctx context.Context, tx *sql.Tx, e order.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failures > 0 {
+		p.failures--
+		return errAny
+	}
+	p.handled++
+	return nil
+}
+
+func (p *flakyProjection) Reset(ctx context.Context, tx *sql.Tx) error { return nil }
+
+func (p *flakyProjection) handledEvents() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.handled
+}
+
+var errAny = errors.New("any error")
+
+func TestRunner_Run(t *testing.T) {
+	// Given a projection that fails twice, and one that doesn't
+	require := require.New(t)
+	db := openTestDB(t)
+	storeOrder(t, db, newOrder(t, 1))
+	flaky := &flakyProjection{failures: 2}
+	runner := NewRunner(db, flaky, OrderSummaries{})
+	ctx, cancel := context.WithCancel(context.Background())
+	var (
+		mu     sync.Mutex
+		failed []error
+	)
+	done := make(chan error)
+
+	// When
+	go func() {
+		done <- runner.Run(ctx, time.Millisecond, func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, err)
+		})
+	}()
+
+	// Then the failures are reported and retried until the events are handled
+	require.Eventually(func() bool { return flaky.handledEvents() == 1 }, 5*time.Second, time.Millisecond)
+	cancel()
+	require.NoError(<-done)
+	require.Len(failed, 2)
+	require.True(errors.Is(failed[0], errAny), "expected %v to wrap %v", failed[0], errAny)
+
+	// Then the failures didn't hold back the other projection
+	counts, err := NewQueryService(db).CountsByState(context.Background())
+	require.NoError(err)
+	require.Equal(map[string]int{"pending": 1}, counts)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		failures int
+		want     time.Duration
+	}{
+		{name: "should wait the interval without failures", interval: time.Second, failures: 0, want: time.Second},
+		{name: "should double with every failure", interval: time.Second, failures: 3, want: 8 * time.Second},
+		{name: "should not wait longer than the max backoff", interval: time.Second, failures: 100, want: maxRetryBackoff},
+		{name: "should not wait less than the interval", interval: time.Hour, failures: 3, want: time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, retryBackoff(tt.interval, tt.failures))
+		})
+	}
+}
+
+func TestQueryController_OrdersByCustomer(t *testing.T) {
+	// Given
+	require := require.New(t)
+	db := openTestDB(t)
+	storeOrder(t, db, newOrder(t, 2))
+	require.NoError(NewRunner(db, OrderSummaries{}).CatchUp(context.Background()))
+	controller := NewQueryController(NewQueryService(db))
+
+	// When
+	rec := httptest.NewRecorder()
+	controller.OrdersByCustomer(rec, httptest.NewRequest(http.MethodGet, "/orders?customerId="+customerID, nil))
+
+	// Then
+	require.Equal(http.StatusOK, rec.Code)
+	var respModel CustomerOrdersResponseModel
+	require.NoError(json.Unmarshal(rec.Body.Bytes(), &respModel))
+	require.Len(respModel.Orders, 1)
+	require.Equal("pending", respModel.Orders[0].State)
+	require.Equal(MoneyResponseModel{Amount: "25.00", Currency: "EUR"}, respModel.Orders[0].Total)
+
+	// When the customer id is missing
+	rec = httptest.NewRecorder()
+	controller.OrdersByCustomer(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+	// Then
+	require.Equal(http.StatusBadRequest, rec.Code)
+	require.Equal(apperror.ProblemContentType, rec.Header().Get("Content-Type"))
+}
+
+func TestQueryController_DailySubmittedTotals(t *testing.T) {
+	// Given
+	require := require.New(t)
+	db := openTestDB(t)
+	o := newOrder(t, 2)
+	require.NoError(o.Submit())
+	storeOrder(t, db, o)
+	require.NoError(NewRunner(db, DailySubmittedTotals{}).CatchUp(context.Background()))
+	controller := NewQueryController(NewQueryService(db))
+	today := time.Now().UTC().Format(dayLayout)
+
+	// When
+	rec := httptest.NewRecorder()
+	controller.DailySubmittedTotals(rec, httptest.NewRequest(http.MethodGet, "/totals?from="+today+"&to="+today, nil))
+
+	// Then
+	require.Equal(http.StatusOK, rec.Code)
+	var respModel DailyTotalsResponseModel
+	require.NoError(json.Unmarshal(rec.Body.Bytes(), &respModel))
+	require.Equal([]DailyTotalResponseModel{{
+		Day:        today,
+		OrderCount: 1,
+		ItemCount:  2,
+		Totals:     []MoneyResponseModel{{Amount: "25.00", Currency: "EUR"}},
+	}}, respModel.Totals)
+}
+
+// failingQuerier fails every query
+type failingQuerier struct{}
+
+func (failingQuerier) OrdersByCustomer(ctx context.Context, customerID string) ([]OrderSummary, error) {
+	return nil, errAny
+}
+
+func (failingQuerier) CountsByState(ctx context.Context) (map[string]int, error) {
+	return nil, errAny
+}
+
+func (failingQuerier) DailySubmittedTotals(ctx context.Context, from, to time.Time) ([]DailyTotal, error) {
+	return nil, errAny
+}
+
+func TestQueryController_Errors(t *testing.T) {
+	controller := NewQueryController(failingQuerier{})
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		target     string
+		wantStatus int
+		wantDetail string
+	}{
+		{
+			name:       "should reject missing customer id",
+			handler:    controller.OrdersByCustomer,
+			target:     "/orders",
+			wantStatus: http.StatusBadRequest,
+			wantDetail: "query parameter customerId is required",
+		},
+		{
+			name:       "should reject malformed day",
+			handler:    controller.DailySubmittedTotals,
+			target:     "/totals?from=yesterday",
+			wantStatus: http.StatusBadRequest,
+			wantDetail: "query parameter from must be formatted as yyyy-mm-dd",
+		},
+		{
+			name:       "should not expose failed queries",
+			handler:    controller.CountsByState,
+			target:     "/counts",
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "should not expose failed daily totals queries",
+			handler:    controller.DailySubmittedTotals,
+			target:     "/totals",
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			rec := httptest.NewRecorder()
+
+			// When
+			tt.handler(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			// Then the error is responded with as problem details
+			require.Equal(tt.wantStatus, rec.Code)
+			require.Equal(apperror.ProblemContentType, rec.Header().Get("Content-Type"))
+			var problem apperror.Problem
+			require.NoError(json.Unmarshal(rec.Body.Bytes(), &problem))
+			require.Equal(tt.wantStatus, problem.Status)
+			require.Equal(tt.wantDetail, problem.Detail)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package projection
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+const (
+	querySummariesByCustomer = "SELECT order_id, customer_id, state, item_count, currency, total, created_at, updated_at FROM order_summaries WHERE customer_id = $1 ORDER BY created_at;"
+	queryStateCounts         = "SELECT state, order_count FROM order_state_counts WHERE order_count > 0 ORDER BY state;"
+	queryDailyTotals         = "SELECT day, order_count, item_count FROM daily_submitted_totals WHERE day >= $1 AND day <= $2 ORDER BY day;"
+	queryDailyCurrencyTotals = "SELECT day, currency, total FROM daily_submitted_currency_totals WHERE day >= $1 AND day <= $2 ORDER BY day, currency;"
+)
+
+type (
+	OrderSummary struct {
+		OrderID    string
+		CustomerID string
+		State      string
+		ItemCount  int
+		Total      order.Money
+		CreatedAt  time.Time
+		UpdatedAt  time.Time
+	}
+
+	DailyTotal struct {
+		Day        string // yyyy-mm-dd
+		OrderCount int
+		ItemCount  int
+		Totals     []order.Money // the sum of the order totals in each currency, ordered by currency
+	}
+)
+
+// QueryService answers queries about orders from the read models maintained by the projections.
+// The answers are eventually consistent with the orders themselves.
+type QueryService struct {
+	db *sql.DB
+}
+
+func NewQueryService(db *sql.DB) *QueryService {
+	return &QueryService{db: db}
+}
+
+// OrdersByCustomer returns the orders of the customer, oldest first
+func (s *QueryService) OrdersByCustomer(ctx context.Context, customerID string) ([]OrderSummary, error) {
+	rows, err := s.db.QueryContext(ctx, querySummariesByCustomer, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("could not query orders of customer = %s: %w", customerID, err)
+	}
+	defer rows.Close()
+
+	var summaries []OrderSummary
+	for rows.Next() {
+		var (
+			summary              OrderSummary
+			currency             string
+			total                int64
+			createdAt, updatedAt string
+		)
+		if err := rows.Scan(&summary.OrderID, &summary.CustomerID, &summary.State, &summary.ItemCount, &currency, &total, &createdAt, &updatedAt); err != nil {
+			return nil, fmt.Errorf("could not scan order summary: %w", err)
+		}
+		// summaries of orders created before orders were priced have no currency
+		if currency != "" {
+			if summary.Total, err = order.NewMoney(total, order.Currency(currency)); err != nil {
+				return nil, fmt.Errorf("order summary of order = %s has an invalid total: %w", summary.OrderID, err)
+			}
+		}
+		summary.CreatedAt, _ = time.Parse(timestampLayout, createdAt)
+		summary.UpdatedAt, _ = time.Parse(timestampLayout, updatedAt)
+
+		summaries = append(summaries, summary)
+	}
+
+	return summaries, rows.Err()
+}
+
+// CountsByState returns the number of orders in each state
+func (s *QueryService) CountsByState(ctx context.Context) (map[string]int, error) {
+	rows, err := s.db.QueryContext(ctx, queryStateCounts)
+	if err != nil {
+		return nil, fmt.Errorf("could not query order counts by state: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var (
+			state string
+			count int
+		)
+		if err := rows.Scan(&state, &count); err != nil {
+			return nil, fmt.Errorf("could not scan order count: %w", err)
+		}
+		counts[state] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// DailySubmittedTotals returns the totals of the days between from and to, inclusive, that had
+// orders submitted
+func (s *QueryService) DailySubmittedTotals(ctx context.Context, from, to time.Time) ([]DailyTotal, error) {
+	fromDay, toDay := from.UTC().Format(dayLayout), to.UTC().Format(dayLayout)
+	rows, err := s.db.QueryContext(ctx, queryDailyTotals, fromDay, toDay)
+	if err != nil {
+		return nil, fmt.Errorf("could not query daily submitted totals: %w", err)
+	}
+	defer rows.Close()
+
+	var totals []DailyTotal
+	for rows.Next() {
+		var total DailyTotal
+		if err := rows.Scan(&total.Day, &total.OrderCount, &total.ItemCount); err != nil {
+			return nil, fmt.Errorf("could not scan daily submitted total: %w", err)
+		}
+		totals = append(totals, total)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	byDay := make(map[string]*DailyTotal, len(totals))
+	for i := range totals {
+		byDay[totals[i].Day] = &totals[i]
+	}
+
+	currencyRows, err := s.db.QueryContext(ctx, queryDailyCurrencyTotals, fromDay, toDay)
+	if err != nil {
+		return nil, fmt.Errorf("could not query daily submitted currency totals: %w", err)
+	}
+	defer currencyRows.Close()
+
+	for currencyRows.Next() {
+		var (
+			day, currency string
+			amount        int64
+		)
+		if err := currencyRows.Scan(&day, &currency, &amount); err != nil {
+			return nil, fmt.Errorf("could not scan daily submitted currency total: %w", err)
+		}
+
+		money, err := order.NewMoney(amount, order.Currency(currency))
+		if err != nil {
+			return nil, fmt.Errorf("daily submitted total of day = %s has an invalid total: %w", day, err)
+		}
+		if total, ok := byDay[day]; ok {
+			total.Totals = append(total.Totals, money)
+		}
+	}
+
+	return totals, currencyRows.Err()
+}
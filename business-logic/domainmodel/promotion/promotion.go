@@ -0,0 +1,322 @@
+// Package promotion is a domain service that prices orders according to promotions, such as
+// percentages off, buy X get Y and coupons. The discounts are recorded on the orders themselves,
+// so they're part of the orders' events.
+package promotion
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+var (
+	// ErrInvalidCoupon is returned when a coupon code is unknown or can't be used for the order
+	ErrInvalidCoupon = apperror.New(apperror.CategoryValidation, "PROMOTION_INVALID_COUPON", "invalid coupon code")
+
+	// ErrUsageLimitReached is returned when an order is redeemed with a promotion that has been
+	// used as many times as it may be since the discounts were applied
+	ErrUsageLimitReached = apperror.New(apperror.CategoryPreconditionFailed, "PROMOTION_USAGE_LIMIT_REACHED", "promotion usage limit reached")
+
+	// ErrPromotionNotValid is returned when an order is redeemed with a promotion whose validity
+	// window has ended, or not yet started, since the discounts were applied
+	ErrPromotionNotValid = apperror.New(apperror.CategoryPreconditionFailed, "PROMOTION_NOT_VALID", "promotion is not valid")
+)
+
+// now is the clock used to check validity windows. It's a variable so tests can replace it.
+var now = time.Now
+
+// Promotion is a rule together with the conditions for when it applies
+type Promotion struct {
+	ID          string
+	Description string
+	Rule        Rule
+
+	// ValidFrom and ValidUntil is the window the promotion is valid in. A zero time means the
+	// window is open at that end.
+	ValidFrom  time.Time
+	ValidUntil time.Time
+
+	// CouponCode makes the promotion only apply when the code is presented. CustomerID further
+	// restricts the coupon to a single customer.
+	CouponCode string
+	CustomerID order.CustomerID
+
+	// UsageLimit is the number of orders the promotion may be used for and UsageLimitPerCustomer
+	// is the number of orders each customer may use it for. Zero means unlimited.
+	UsageLimit            int
+	UsageLimitPerCustomer int
+}
+
+// isValidAt returns whether t is within the validity window
+func (p Promotion) isValidAt(t time.Time) bool {
+	if !p.ValidFrom.IsZero() && t.Before(p.ValidFrom) {
+		return false
+	}
+
+	return p.ValidUntil.IsZero() || t.Before(p.ValidUntil)
+}
+
+// Usage is an order a promotion was used for
+type Usage struct {
+	PromotionID string
+	OrderID     order.ID
+	CustomerID  order.CustomerID
+	UsedAt      time.Time
+
+	// Slot numbers the usage among all usages of the promotion and CustomerSlot among the usages
+	// by the customer, starting at 1. They're zero unless the promotion has the corresponding
+	// usage limit. The slots of released usages are taken again by later usages.
+	Slot         int
+	CustomerSlot int
+}
+
+// UsageStore keeps track of how many times promotions have been used
+type UsageStore interface {
+	// Count returns the number of orders the promotion has been used for in total and by the customer
+	Count(ctx context.Context, promotionID string, customerID order.CustomerID) (total, byCustomer int, err error)
+
+	// TakenSlots returns the slots taken by the usages of the promotion in total and by the customer
+	TakenSlots(ctx context.Context, promotionID string, customerID order.CustomerID) (slots, customerSlots []int, err error)
+
+	// Record records the usage. It returns order.ErrConcurrencyConflict if the promotion has
+	// already been used in the same slot, or for the same order.
+	Record(ctx context.Context, usage Usage) error
+
+	// Release removes the usages recorded for the order, which frees their slots
+	Release(ctx context.Context, orderID order.ID) error
+}
+
+// Engine applies promotions to orders
+type Engine struct {
+	promotions []Promotion
+	usages     UsageStore
+}
+
+func NewEngine(usages UsageStore, promotions ...Promotion) *Engine {
+	return &Engine{promotions: promotions, usages: usages}
+}
+
+// Apply replaces the discounts of the order with the discounts of the promotions that apply to it
+func (e *Engine) Apply(ctx context.Context, o *order.Order, couponCodes ...string) error {
+	discounts, err := e.Evaluate(ctx, o, couponCodes...)
+	if err != nil {
+		return err
+	}
+
+	return o.ApplyDiscounts(discounts)
+}
+
+// Evaluate returns the discounts of the promotions that apply to the order, in the order the
+// promotions were given to the engine. Promotions that require a coupon code only apply if the
+// code is presented, and every presented code must belong to a promotion that applies. The
+// discounts are trimmed so they never add up to more than what they're taken off.
+func (e *Engine) Evaluate(ctx context.Context, o *order.Order, couponCodes ...string) ([]order.Discount, error) {
+	presented := make(map[string]bool, len(couponCodes))
+	for _, code := range couponCodes {
+		presented[code] = false
+	}
+
+	subtotal, err := o.Subtotal()
+	if err != nil {
+		return nil, err
+	}
+	remaining := map[order.DiscountTarget]order.Money{
+		order.DiscountTargetItems:    subtotal,
+		order.DiscountTargetShipping: o.ShippingFee(),
+	}
+
+	at := now()
+	var discounts []order.Discount
+	for _, promotion := range e.promotions {
+		if _, ok := presented[promotion.CouponCode]; promotion.CouponCode != "" && !ok {
+			continue
+		}
+
+		eligible, err := e.isEligible(ctx, promotion, o, at)
+		if err != nil {
+			return nil, err
+		}
+		if !eligible {
+			continue
+		}
+		if promotion.CouponCode != "" {
+			presented[promotion.CouponCode] = true
+		}
+
+		promotionDiscounts, err := promotion.Rule.Evaluate(o)
+		if err != nil {
+			return nil, fmt.Errorf("could not evaluate promotion = %s: %w", promotion.ID, err)
+		}
+
+		for _, discount := range promotionDiscounts {
+			if discount, err = trim(discount, remaining); err != nil {
+				return nil, fmt.Errorf("could not evaluate promotion = %s: %w", promotion.ID, err)
+			}
+
+			if discount.Amount.IsZero() {
+				continue
+			}
+
+			discount.PromotionID = promotion.ID
+			discount.Description = promotion.Description
+			discounts = append(discounts, discount)
+		}
+	}
+
+	for code, applied := range presented {
+		if !applied {
+			return nil, fmt.Errorf("could not apply coupon code = %s: %w", code, ErrInvalidCoupon)
+		}
+	}
+
+	return discounts, nil
+}
+
+// Redeem records that the promotions whose discounts are applied to the order have been used for
+// it. It's meant to be called when the order is submitted, in the same transaction, so a
+// promotion that has ended or reached its usage limit after the discounts were applied can't be
+// used anyway. The usages take numbered slots, so when concurrent redemptions compete for the
+// last slot of a promotion, only one of them is recorded and the others fail with
+// order.ErrConcurrencyConflict.
+func (e *Engine) Redeem(ctx context.Context, o *order.Order) error {
+	at := now()
+	redeemed := make(map[string]bool)
+	for _, discount := range o.Discounts() {
+		if redeemed[discount.PromotionID] {
+			continue
+		}
+		redeemed[discount.PromotionID] = true
+
+		usage := Usage{PromotionID: discount.PromotionID, OrderID: o.ID(), CustomerID: o.CustomerID(), UsedAt: at.UTC()}
+		if promotion, ok := e.promotion(discount.PromotionID); ok {
+			if !promotion.isValidAt(at) {
+				return fmt.Errorf("could not redeem promotion = %s: %w", promotion.ID, ErrPromotionNotValid)
+			}
+
+			var (
+				withinLimits bool
+				err          error
+			)
+			usage.Slot, usage.CustomerSlot, withinLimits, err = e.nextSlots(ctx, promotion, o.CustomerID())
+			if err != nil {
+				return err
+			}
+
+			if !withinLimits {
+				return fmt.Errorf("could not redeem promotion = %s: %w", promotion.ID, ErrUsageLimitReached)
+			}
+		}
+
+		if err := e.usages.Record(ctx, usage); err != nil {
+			return fmt.Errorf("could not record usage of promotion = %s: %w", discount.PromotionID, err)
+		}
+	}
+
+	return nil
+}
+
+// Release gives back the usages of the promotions redeemed for the order, so they can be used for
+// other orders. It's meant to be called when a submitted order is cancelled, in the same
+// transaction.
+func (e *Engine) Release(ctx context.Context, o *order.Order) error {
+	if err := e.usages.Release(ctx, o.ID()); err != nil {
+		return fmt.Errorf("could not release usages of promotions by order = %s: %w", o.ID(), err)
+	}
+
+	return nil
+}
+
+func (e *Engine) isEligible(ctx context.Context, promotion Promotion, o *order.Order, at time.Time) (bool, error) {
+	if !promotion.isValidAt(at) {
+		return false, nil
+	}
+
+	if promotion.CustomerID != "" && promotion.CustomerID != o.CustomerID() {
+		return false, nil
+	}
+
+	_, _, withinLimits, err := e.nextSlots(ctx, promotion, o.CustomerID())
+	return withinLimits, err
+}
+
+// nextSlots returns the slots the next usage of the promotion by the customer takes and whether
+// they're within the usage limits of the promotion. The lowest free slots are taken, so the slots
+// of released usages are used again.
+func (e *Engine) nextSlots(ctx context.Context, promotion Promotion, customerID order.CustomerID) (slot, customerSlot int, withinLimits bool, err error) {
+	if promotion.UsageLimit == 0 && promotion.UsageLimitPerCustomer == 0 {
+		return 0, 0, true, nil
+	}
+
+	// usages recorded while the promotion had no limits don't take slots, but they're counted
+	total, byCustomer, err := e.usages.Count(ctx, promotion.ID, customerID)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("could not count usages of promotion = %s: %w", promotion.ID, err)
+	}
+
+	slots, customerSlots, err := e.usages.TakenSlots(ctx, promotion.ID, customerID)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("could not find slots of promotion = %s: %w", promotion.ID, err)
+	}
+
+	withinLimits = true
+	if promotion.UsageLimit > 0 {
+		slot = firstFreeSlot(slots)
+		withinLimits = total < promotion.UsageLimit && slot <= promotion.UsageLimit
+	}
+	if promotion.UsageLimitPerCustomer > 0 {
+		customerSlot = firstFreeSlot(customerSlots)
+		withinLimits = withinLimits && byCustomer < promotion.UsageLimitPerCustomer && customerSlot <= promotion.UsageLimitPerCustomer
+	}
+
+	return slot, customerSlot, withinLimits, nil
+}
+
+// firstFreeSlot returns the lowest slot, starting at 1, that isn't taken
+func firstFreeSlot(taken []int) int {
+	isTaken := make(map[int]bool, len(taken))
+	for _, slot := range taken {
+		isTaken[slot] = true
+	}
+
+	slot := 1
+	for isTaken[slot] {
+		slot++
+	}
+
+	return slot
+}
+
+func (e *Engine) promotion(id string) (Promotion, bool) {
+	for _, promotion := range e.promotions {
+		if promotion.ID == id {
+			return promotion, true
+		}
+	}
+
+	return Promotion{}, false
+}
+
+// trim reduces the discount so it doesn't exceed the remaining amount of its target
+func trim(discount order.Discount, remaining map[order.DiscountTarget]order.Money) (order.Discount, error) {
+	left, ok := remaining[discount.Target]
+	if !ok {
+		return order.Discount{}, fmt.Errorf("invalid discount target = %q", discount.Target)
+	}
+
+	comparison, err := discount.Amount.Compare(left)
+	if err != nil {
+		return order.Discount{}, err
+	}
+	if comparison > 0 {
+		discount.Amount = left
+	}
+
+	if remaining[discount.Target], err = left.Sub(discount.Amount); err != nil {
+		return order.Discount{}, err
+	}
+
+	return discount, nil
+}
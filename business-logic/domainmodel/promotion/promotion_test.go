@@ -0,0 +1,313 @@
+package promotion
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/migrations"
+	_ "modernc.org/sqlite"
+)
+
+func TestEngine_Evaluate(t *testing.T) {
+	fixedNow := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return fixedNow }
+	defer func() { now = time.Now }()
+
+	tenPercent := PercentageOff{BasisPoints: 1000}
+	freeShipping := FreeShippingOver{Threshold: eur(t, 0)}
+
+	tests := []struct {
+		name        string
+		promotions  []Promotion
+		usages      []Usage
+		couponCodes []string
+		want        map[string]int64 // discount amounts by promotion id
+		wantErr     error
+	}{
+		{
+			name:       "should apply promotions without coupon codes",
+			promotions: []Promotion{{ID: "p1", Rule: tenPercent}, {ID: "p2", Rule: freeShipping}},
+			want:       map[string]int64{"p1": 350, "p2": 495},
+		},
+		{
+			name: "should apply promotions within validity window",
+			promotions: []Promotion{
+				{ID: "current", Rule: tenPercent, ValidFrom: fixedNow, ValidUntil: fixedNow.Add(time.Hour)},
+				{ID: "future", Rule: tenPercent, ValidFrom: fixedNow.Add(time.Second)},
+				{ID: "expired", Rule: tenPercent, ValidUntil: fixedNow},
+			},
+			want: map[string]int64{"current": 350},
+		},
+		{
+			name:       "should not apply coupon promotions without coupon code",
+			promotions: []Promotion{{ID: "p1", Rule: tenPercent, CouponCode: "SUMMER"}},
+			want:       map[string]int64{},
+		},
+		{
+			name:        "should apply coupon promotions with coupon code",
+			promotions:  []Promotion{{ID: "p1", Rule: tenPercent, CouponCode: "SUMMER"}},
+			couponCodes: []string{"SUMMER"},
+			want:        map[string]int64{"p1": 350},
+		},
+		{
+			name:        "should apply customer specific coupon for the customer",
+			promotions:  []Promotion{{ID: "p1", Rule: tenPercent, CouponCode: "VIP", CustomerID: customerID}},
+			couponCodes: []string{"VIP"},
+			want:        map[string]int64{"p1": 350},
+		},
+		{
+			name:        "should refuse customer specific coupon of another customer",
+			promotions:  []Promotion{{ID: "p1", Rule: tenPercent, CouponCode: "VIP", CustomerID: "7c9e6679-7425-40de-944b-e07fc1f90ae7"}},
+			couponCodes: []string{"VIP"},
+			wantErr:     ErrInvalidCoupon,
+		},
+		{
+			name:        "should refuse unknown coupon code",
+			promotions:  []Promotion{{ID: "p1", Rule: tenPercent, CouponCode: "SUMMER"}},
+			couponCodes: []string{"WINTER"},
+			wantErr:     ErrInvalidCoupon,
+		},
+		{
+			name:        "should refuse coupon code of expired promotion",
+			promotions:  []Promotion{{ID: "p1", Rule: tenPercent, CouponCode: "SUMMER", ValidUntil: fixedNow}},
+			couponCodes: []string{"SUMMER"},
+			wantErr:     ErrInvalidCoupon,
+		},
+		{
+			name:       "should not apply promotions that are used up",
+			promotions: []Promotion{{ID: "p1", Rule: tenPercent, UsageLimit: 1}, {ID: "p2", Rule: freeShipping, UsageLimit: 2}},
+			usages:     []Usage{{PromotionID: "p1", CustomerID: "7c9e6679-7425-40de-944b-e07fc1f90ae7"}},
+			want:       map[string]int64{"p2": 495},
+		},
+		{
+			name:       "should not apply promotions that are used up by the customer",
+			promotions: []Promotion{{ID: "p1", Rule: tenPercent, UsageLimitPerCustomer: 1}},
+			usages:     []Usage{{PromotionID: "p1", CustomerID: customerID}},
+			want:       map[string]int64{},
+		},
+		{
+			name:       "should not take off more than the subtotal",
+			promotions: []Promotion{{ID: "p1", Rule: FixedAmountOff{Amount: eur(t, 3000)}}, {ID: "p2", Rule: FixedAmountOff{Amount: eur(t, 3000)}}},
+			want:       map[string]int64{"p1": 3000, "p2": 500},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			ctx := context.Background()
+			usages := NewMemoryUsageStore()
+			for _, usage := range tt.usages {
+				require.NoError(usages.Record(ctx, usage))
+			}
+			engine := NewEngine(usages, tt.promotions...)
+
+			// When
+			discounts, err := engine.Evaluate(ctx, newOrder(t), tt.couponCodes...)
+
+			// Then
+			if tt.wantErr != nil {
+				require.True(errors.Is(err, tt.wantErr), "expected %v to wrap %v", err, tt.wantErr)
+				return
+			}
+			require.NoError(err)
+			got := make(map[string]int64)
+			for _, discount := range discounts {
+				got[discount.PromotionID] += discount.Amount.Amount()
+			}
+			require.Equal(tt.want, got)
+		})
+	}
+}
+
+func TestEngine_ApplyAndRedeem(t *testing.T) {
+	// Given
+	require := require.New(t)
+	ctx := context.Background()
+	usages := NewSQLUsageStore(beginTestTx(t))
+	summer := Promotion{ID: "summer", Description: "10% off", Rule: PercentageOff{BasisPoints: 1000}, CouponCode: "SUMMER", UsageLimit: 1}
+	engine := NewEngine(usages, summer)
+	first, second := newOrder(t), newOrder(t)
+
+	// When the discounts are applied to two orders
+	require.NoError(engine.Apply(ctx, first, "SUMMER"))
+	require.NoError(engine.Apply(ctx, second, "SUMMER"))
+
+	// Then they're recorded on the orders and emitted in their events
+	discounts := first.Discounts()
+	require.Len(discounts, 2)
+	require.Equal("summer", discounts[0].PromotionID)
+	require.Equal("10% off", discounts[0].Description)
+	events := first.Events()
+	require.Equal(order.OrderDiscountsApplied{Discounts: discounts, Total: eur(t, 3645)}, events[len(events)-1].Payload())
+
+	// When the orders are redeemed
+	require.NoError(engine.Redeem(ctx, first))
+	err := engine.Redeem(ctx, second)
+
+	// Then only the first is within the usage limit
+	require.True(errors.Is(err, ErrUsageLimitReached), "expected %v to wrap %v", err, ErrUsageLimitReached)
+	total, byCustomer, err := usages.Count(ctx, "summer", customerID)
+	require.NoError(err)
+	require.Equal(1, total)
+	require.Equal(1, byCustomer)
+
+	// And the coupon can't be applied anymore
+	require.True(errors.Is(engine.Apply(ctx, newOrder(t), "SUMMER"), ErrInvalidCoupon))
+}
+
+// staleUsageStore finds the slots taken before any usages were recorded, as if they were found
+// before concurrent redemptions were recorded
+type staleUsageStore struct {
+	UsageStore
+}
+
+func (s staleUsageStore) Count(ctx context.Context, promotionID string, customerID order.CustomerID) (total, byCustomer int, err error) {
+	return 0, 0, nil
+}
+
+func (s staleUsageStore) TakenSlots(ctx context.Context, promotionID string, customerID order.CustomerID) (slots, customerSlots []int, err error) {
+	return nil, nil, nil
+}
+
+func TestEngine_Redeem(t *testing.T) {
+	fixedNow := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	stores := map[string]func(t *testing.T) UsageStore{
+		"memory": func(t *testing.T) UsageStore { return NewMemoryUsageStore() },
+		"sql":    func(t *testing.T) UsageStore { return NewSQLUsageStore(beginTestTx(t)) },
+	}
+
+	tests := []struct {
+		name      string
+		promotion Promotion
+		redeemAt  time.Time
+		stale     bool // whether the usages are counted before the first redemption is recorded
+		wantErr   error
+	}{
+		{
+			name:      "should redeem promotions within usage limits",
+			promotion: Promotion{ID: "p1", UsageLimit: 2, UsageLimitPerCustomer: 2},
+			redeemAt:  fixedNow,
+		},
+		{
+			name:      "should redeem unlimited promotions concurrently",
+			promotion: Promotion{ID: "p1"},
+			redeemAt:  fixedNow,
+			stale:     true,
+		},
+		{
+			name:      "should not redeem the last usage concurrently",
+			promotion: Promotion{ID: "p1", UsageLimit: 2},
+			redeemAt:  fixedNow,
+			stale:     true,
+			wantErr:   order.ErrConcurrencyConflict,
+		},
+		{
+			name:      "should not redeem the last usage of the customer concurrently",
+			promotion: Promotion{ID: "p1", UsageLimitPerCustomer: 2},
+			redeemAt:  fixedNow,
+			stale:     true,
+			wantErr:   order.ErrConcurrencyConflict,
+		},
+		{
+			name:      "should not redeem promotions that ended after the discounts were applied",
+			promotion: Promotion{ID: "p1", ValidUntil: fixedNow.Add(time.Hour)},
+			redeemAt:  fixedNow.Add(time.Hour),
+			wantErr:   ErrPromotionNotValid,
+		},
+	}
+	for storeName, newStore := range stores {
+		for _, tt := range tests {
+			t.Run(storeName+"/"+tt.name, func(t *testing.T) {
+				// Given the discounts of the promotion applied to two orders, one of which is redeemed
+				require := require.New(t)
+				ctx := context.Background()
+				tt.promotion.Rule = PercentageOff{BasisPoints: 1000}
+				now = func() time.Time { return fixedNow }
+				defer func() { now = time.Now }()
+				usages := newStore(t)
+				engine := NewEngine(usages, tt.promotion)
+				first, second := newOrder(t), newOrder(t)
+				require.NoError(engine.Apply(ctx, first))
+				require.NoError(engine.Apply(ctx, second))
+				require.NoError(engine.Redeem(ctx, first))
+				if tt.stale {
+					engine = NewEngine(staleUsageStore{UsageStore: usages}, tt.promotion)
+				}
+
+				// When the other is redeemed
+				now = func() time.Time { return tt.redeemAt }
+				err := engine.Redeem(ctx, second)
+
+				// Then
+				if tt.wantErr != nil {
+					require.True(errors.Is(err, tt.wantErr), "expected %v to wrap %v", err, tt.wantErr)
+					return
+				}
+				require.NoError(err)
+				total, _, err := usages.Count(ctx, "p1", customerID)
+				require.NoError(err)
+				require.Equal(2, total)
+			})
+		}
+	}
+}
+
+func TestEngine_Release(t *testing.T) {
+	stores := map[string]func(t *testing.T) UsageStore{
+		"memory": func(t *testing.T) UsageStore { return NewMemoryUsageStore() },
+		"sql":    func(t *testing.T) UsageStore { return NewSQLUsageStore(beginTestTx(t)) },
+	}
+
+	for storeName, newStore := range stores {
+		t.Run(storeName, func(t *testing.T) {
+			// Given a promotion whose usages have all been redeemed
+			require := require.New(t)
+			ctx := context.Background()
+			usages := newStore(t)
+			promotion := Promotion{ID: "p1", Rule: PercentageOff{BasisPoints: 1000}, UsageLimit: 2, UsageLimitPerCustomer: 2}
+			engine := NewEngine(usages, promotion)
+			first, second, third := newOrder(t), newOrder(t), newOrder(t)
+			for _, o := range []*order.Order{first, second, third} {
+				require.NoError(engine.Apply(ctx, o))
+			}
+			require.NoError(engine.Redeem(ctx, first))
+			require.NoError(engine.Redeem(ctx, second))
+			err := engine.Redeem(ctx, third)
+			require.True(errors.Is(err, ErrUsageLimitReached), "expected %v to wrap %v", err, ErrUsageLimitReached)
+
+			// When the usage of the first order is released
+			require.NoError(engine.Release(ctx, first))
+
+			// Then its slot can be taken by another order
+			require.NoError(engine.Redeem(ctx, third))
+			total, byCustomer, err := usages.Count(ctx, "p1", customerID)
+			require.NoError(err)
+			require.Equal(2, total)
+			require.Equal(2, byCustomer)
+		})
+	}
+}
+
+func beginTestTx(t *testing.T) *sql.Tx {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "promotion.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	runner, err := migrations.NewRunner(db, migrations.SQLite)
+	require.NoError(t, err)
+	require.NoError(t, runner.Up(context.Background()))
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	t.Cleanup(func() { tx.Rollback() })
+
+	return tx
+}
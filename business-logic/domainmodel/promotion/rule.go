@@ -0,0 +1,197 @@
+package promotion
+
+import (
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+// Rule calculates the discounts a promotion gives an order. Rules only calculate amounts, which
+// means the discounts they return don't need a promotion id or description, and an order that
+// doesn't qualify simply gets no discounts. Rules only apply to orders in their own currency.
+type Rule interface {
+	Evaluate(o *order.Order) ([]order.Discount, error)
+}
+
+// RuleFunc lets ordinary functions be used as rules
+type RuleFunc func(o *order.Order) ([]order.Discount, error)
+
+func (f RuleFunc) Evaluate(o *order.Order) ([]order.Discount, error) {
+	return f(o)
+}
+
+// PercentageOff takes a percentage, in basis points, off the items with the given article
+// numbers or off every item if there are none. 1000 basis points is 10%. Every item gets a
+// discount of its own, rounded with banker's rounding.
+type PercentageOff struct {
+	BasisPoints int64
+	ArticleNos  []string
+}
+
+func (r PercentageOff) Evaluate(o *order.Order) ([]order.Discount, error) {
+	var discounts []order.Discount
+	for _, item := range o.OrderItems() {
+		if len(r.ArticleNos) > 0 && !contains(r.ArticleNos, item.ArticleNo) {
+			continue
+		}
+
+		lineTotal, err := item.LineTotal()
+		if err != nil {
+			return nil, err
+		}
+
+		amount, err := lineTotal.MultiplyRatio(r.BasisPoints, 10000)
+		if err != nil {
+			return nil, err
+		}
+
+		discounts = appendDiscount(discounts, order.DiscountTargetItems, item.ArticleNo, amount)
+	}
+
+	return discounts, nil
+}
+
+// FixedAmountOff takes an amount off the subtotal, but no more than the subtotal
+type FixedAmountOff struct {
+	Amount order.Money
+}
+
+func (r FixedAmountOff) Evaluate(o *order.Order) ([]order.Discount, error) {
+	subtotal, err := o.Subtotal()
+	if err != nil {
+		return nil, err
+	}
+
+	if subtotal.Currency() != r.Amount.Currency() {
+		return nil, nil
+	}
+
+	amount := r.Amount
+	if amount.Amount() > subtotal.Amount() {
+		amount = subtotal
+	}
+
+	return appendDiscount(nil, order.DiscountTargetItems, "", amount), nil
+}
+
+// BuyXGetY gives Get items of the article for free for every Buy items of it, e.g. buy 2 get 1
+// free makes every third item free
+type BuyXGetY struct {
+	ArticleNo string
+	Buy       int
+	Get       int
+}
+
+func (r BuyXGetY) Evaluate(o *order.Order) ([]order.Discount, error) {
+	if r.Buy <= 0 || r.Get <= 0 {
+		return nil, nil
+	}
+
+	var discounts []order.Discount
+	for _, item := range o.OrderItems() {
+		if item.ArticleNo != r.ArticleNo {
+			continue
+		}
+
+		free := item.Quantity / (r.Buy + r.Get) * r.Get
+		amount, err := item.UnitPrice.Multiply(int64(free))
+		if err != nil {
+			return nil, err
+		}
+
+		discounts = appendDiscount(discounts, order.DiscountTargetItems, item.ArticleNo, amount)
+	}
+
+	return discounts, nil
+}
+
+// FreeShippingOver waives the shipping fee of orders with a subtotal of at least the threshold
+type FreeShippingOver struct {
+	Threshold order.Money
+}
+
+func (r FreeShippingOver) Evaluate(o *order.Order) ([]order.Discount, error) {
+	subtotal, err := o.Subtotal()
+	if err != nil {
+		return nil, err
+	}
+
+	if subtotal.Currency() != r.Threshold.Currency() || subtotal.Amount() < r.Threshold.Amount() {
+		return nil, nil
+	}
+
+	return appendDiscount(nil, order.DiscountTargetShipping, "", o.ShippingFee()), nil
+}
+
+// AllOf combines rules by giving the discounts of all of them
+func AllOf(rules ...Rule) Rule {
+	return RuleFunc(func(o *order.Order) ([]order.Discount, error) {
+		var discounts []order.Discount
+		for _, rule := range rules {
+			ruleDiscounts, err := rule.Evaluate(o)
+			if err != nil {
+				return nil, err
+			}
+			discounts = append(discounts, ruleDiscounts...)
+		}
+
+		return discounts, nil
+	})
+}
+
+// BestOf combines rules by giving the discounts of the rule that takes the most off the order
+func BestOf(rules ...Rule) Rule {
+	return RuleFunc(func(o *order.Order) ([]order.Discount, error) {
+		var (
+			best       []order.Discount
+			bestAmount order.Money
+		)
+		for _, rule := range rules {
+			discounts, err := rule.Evaluate(o)
+			if err != nil {
+				return nil, err
+			}
+
+			amount, err := sum(discounts)
+			if err != nil {
+				return nil, err
+			}
+
+			if comparison, err := amount.Compare(bestAmount); err != nil {
+				return nil, err
+			} else if comparison > 0 {
+				best, bestAmount = discounts, amount
+			}
+		}
+
+		return best, nil
+	})
+}
+
+func appendDiscount(discounts []order.Discount, target order.DiscountTarget, articleNo string, amount order.Money) []order.Discount {
+	if amount.IsZero() {
+		return discounts
+	}
+
+	return append(discounts, order.Discount{Target: target, ArticleNo: articleNo, Amount: amount})
+}
+
+func sum(discounts []order.Discount) (order.Money, error) {
+	var total order.Money
+	for _, discount := range discounts {
+		var err error
+		if total, err = total.Add(discount.Amount); err != nil {
+			return order.Money{}, err
+		}
+	}
+
+	return total, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
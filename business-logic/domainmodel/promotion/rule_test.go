@@ -0,0 +1,112 @@
+package promotion
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+const customerID = "0f8fad5b-d9cb-469f-a165-70867728950e"
+
+func eur(t *testing.T, amount int64) order.Money {
+	t.Helper()
+	money, err := order.NewMoney(amount, "EUR")
+	require.NoError(t, err)
+
+	return money
+}
+
+// newOrder returns a pending order with 3 x A1 at 10.00 EUR, 1 x B2 at 5.00 EUR and a shipping
+// fee of 4.95 EUR
+func newOrder(t *testing.T) *order.Order {
+	t.Helper()
+	a1, err := order.NewOrderItem("A1", 3, eur(t, 1000))
+	require.NoError(t, err)
+	b2, err := order.NewOrderItem("B2", 1, eur(t, 500))
+	require.NoError(t, err)
+	o, err := order.NewOrder(customerID, []order.OrderItem{a1, b2}, order.OrderStatePending)
+	require.NoError(t, err)
+	require.NoError(t, o.ChargeShipping(eur(t, 495)))
+
+	return o
+}
+
+func TestRules(t *testing.T) {
+
+	tests := []struct {
+		name string
+		rule Rule
+		want map[string]int64 // discount amounts by target and article number
+	}{
+		{
+			name: "should take percentage off every item",
+			rule: PercentageOff{BasisPoints: 1250},
+			want: map[string]int64{"items/A1": 375, "items/B2": 62},
+		},
+		{
+			name: "should take percentage off given items",
+			rule: PercentageOff{BasisPoints: 1000, ArticleNos: []string{"B2"}},
+			want: map[string]int64{"items/B2": 50},
+		},
+		{
+			name: "should take fixed amount off",
+			rule: FixedAmountOff{Amount: eur(t, 1000)},
+			want: map[string]int64{"items/": 1000},
+		},
+		{
+			name: "should take no more than the subtotal off",
+			rule: FixedAmountOff{Amount: eur(t, 10000)},
+			want: map[string]int64{"items/": 3500},
+		},
+		{
+			name: "should not take amount in another currency off",
+			rule: FixedAmountOff{Amount: order.Zero("SEK")},
+			want: map[string]int64{},
+		},
+		{
+			name: "should give free items",
+			rule: BuyXGetY{ArticleNo: "A1", Buy: 2, Get: 1},
+			want: map[string]int64{"items/A1": 1000},
+		},
+		{
+			name: "should not give free items when buying too few",
+			rule: BuyXGetY{ArticleNo: "B2", Buy: 2, Get: 1},
+			want: map[string]int64{},
+		},
+		{
+			name: "should waive shipping fee over threshold",
+			rule: FreeShippingOver{Threshold: eur(t, 3500)},
+			want: map[string]int64{"shipping/": 495},
+		},
+		{
+			name: "should not waive shipping fee under threshold",
+			rule: FreeShippingOver{Threshold: eur(t, 3501)},
+			want: map[string]int64{},
+		},
+		{
+			name: "should combine all rules",
+			rule: AllOf(BuyXGetY{ArticleNo: "A1", Buy: 2, Get: 1}, FreeShippingOver{Threshold: eur(t, 1000)}),
+			want: map[string]int64{"items/A1": 1000, "shipping/": 495},
+		},
+		{
+			name: "should pick best rule",
+			rule: BestOf(PercentageOff{BasisPoints: 1000}, FixedAmountOff{Amount: eur(t, 400)}, FreeShippingOver{Threshold: eur(t, 1000)}),
+			want: map[string]int64{"shipping/": 495},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+
+			discounts, err := tt.rule.Evaluate(newOrder(t))
+
+			require.NoError(err)
+			got := make(map[string]int64)
+			for _, discount := range discounts {
+				got[string(discount.Target)+"/"+discount.ArticleNo] += discount.Amount.Amount()
+			}
+			require.Equal(tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package promotion
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+// memoryUsageStore implements the UsageStore interface in memory
+type memoryUsageStore struct {
+	mu     sync.RWMutex
+	usages []Usage
+}
+
+func NewMemoryUsageStore() UsageStore {
+	return &memoryUsageStore{}
+}
+
+func (s *memoryUsageStore) Count(ctx context.Context, promotionID string, customerID order.CustomerID) (total, byCustomer int, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, usage := range s.usages {
+		if usage.PromotionID != promotionID {
+			continue
+		}
+
+		total++
+		if usage.CustomerID == customerID {
+			byCustomer++
+		}
+	}
+
+	return total, byCustomer, nil
+}
+
+func (s *memoryUsageStore) TakenSlots(ctx context.Context, promotionID string, customerID order.CustomerID) (slots, customerSlots []int, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, usage := range s.usages {
+		if usage.PromotionID != promotionID {
+			continue
+		}
+
+		if usage.Slot > 0 {
+			slots = append(slots, usage.Slot)
+		}
+		if usage.CustomerSlot > 0 && usage.CustomerID == customerID {
+			customerSlots = append(customerSlots, usage.CustomerSlot)
+		}
+	}
+
+	return slots, customerSlots, nil
+}
+
+func (s *memoryUsageStore) Record(ctx context.Context, usage Usage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, recorded := range s.usages {
+		if recorded.PromotionID != usage.PromotionID {
+			continue
+		}
+
+		sameSlot := usage.Slot > 0 && recorded.Slot == usage.Slot
+		sameCustomerSlot := usage.CustomerSlot > 0 && recorded.CustomerID == usage.CustomerID && recorded.CustomerSlot == usage.CustomerSlot
+		if sameSlot || sameCustomerSlot || recorded.OrderID == usage.OrderID {
+			return fmt.Errorf("promotion = %s was used concurrently: %w", usage.PromotionID, order.ErrConcurrencyConflict)
+		}
+	}
+
+	s.usages = append(s.usages, usage)
+
+	return nil
+}
+
+func (s *memoryUsageStore) Release(ctx context.Context, orderID order.ID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.usages[:0]
+	for _, usage := range s.usages {
+		if usage.OrderID != orderID {
+			kept = append(kept, usage)
+		}
+	}
+	s.usages = kept
+
+	return nil
+}
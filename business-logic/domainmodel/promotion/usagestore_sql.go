@@ -0,0 +1,85 @@
+package promotion
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/sqltx"
+)
+
+const (
+	usageCount   = "SELECT COUNT(*), COUNT(CASE WHEN customer_id = $2 THEN 1 END) FROM promotion_usages WHERE promotion_id = $1;"
+	usageSlots   = "SELECT slot, CASE WHEN customer_id = $2 THEN customer_slot END FROM promotion_usages WHERE promotion_id = $1 AND (slot IS NOT NULL OR customer_id = $2);"
+	usageInsert  = "INSERT INTO promotion_usages (promotion_id, order_id, customer_id, used_at, slot, customer_slot) VALUES ($1, $2, $3, $4, $5, $6);"
+	usageRelease = "DELETE FROM promotion_usages WHERE order_id = $1;"
+)
+
+// Tx is the database transaction the SQL usage store runs in
+type Tx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// sqlUsageStore implements the UsageStore interface using the promotion_usages table. It uses the
+// transaction it's given, which means usages are recorded atomically with the orders they're for.
+type sqlUsageStore struct {
+	tx Tx
+}
+
+func NewSQLUsageStore(tx Tx) UsageStore {
+	return &sqlUsageStore{tx: tx}
+}
+
+func (s *sqlUsageStore) Count(ctx context.Context, promotionID string, customerID order.CustomerID) (total, byCustomer int, err error) {
+	err = s.tx.QueryRowContext(ctx, usageCount, promotionID, string(customerID)).Scan(&total, &byCustomer)
+	return total, byCustomer, err
+}
+
+func (s *sqlUsageStore) TakenSlots(ctx context.Context, promotionID string, customerID order.CustomerID) (slots, customerSlots []int, err error) {
+	rows, err := s.tx.QueryContext(ctx, usageSlots, promotionID, string(customerID))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var slot, customerSlot sql.NullInt64
+		if err = rows.Scan(&slot, &customerSlot); err != nil {
+			return nil, nil, err
+		}
+
+		if slot.Valid {
+			slots = append(slots, int(slot.Int64))
+		}
+		if customerSlot.Valid {
+			customerSlots = append(customerSlots, int(customerSlot.Int64))
+		}
+	}
+
+	return slots, customerSlots, rows.Err()
+}
+
+func (s *sqlUsageStore) Record(ctx context.Context, usage Usage) error {
+	_, err := s.tx.ExecContext(ctx, usageInsert, usage.PromotionID, string(usage.OrderID), string(usage.CustomerID),
+		usage.UsedAt.UTC().Format(time.RFC3339Nano), nullSlot(usage.Slot), nullSlot(usage.CustomerSlot))
+	if sqltx.IsUniqueViolation(err) {
+		return fmt.Errorf("promotion = %s was used concurrently: %w", usage.PromotionID, order.ErrConcurrencyConflict)
+	}
+
+	return err
+}
+
+// nullSlot stores the slots of usages of promotions without the corresponding limit as NULL,
+// which doesn't take a slot
+func nullSlot(slot int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(slot), Valid: slot > 0}
+}
+
+func (s *sqlUsageStore) Release(ctx context.Context, orderID order.ID) error {
+	_, err := s.tx.ExecContext(ctx, usageRelease, string(orderID))
+	return err
+}
@@ -0,0 +1,234 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+// ErrBusClosed is returned by Publish after the bus is closed
+var ErrBusClosed = errors.New("event bus is closed")
+
+// SubscriberError is returned by a synchronous Bus when subscribers fail to handle an event. The
+// other subscribers handle it anyway.
+type SubscriberError struct {
+	EventID   string
+	EventType string
+	Errs      []error // one per failed subscriber, in the order they subscribed
+}
+
+func (e *SubscriberError) Error() string {
+	msgs := make([]string, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		msgs = append(msgs, err.Error())
+	}
+
+	return fmt.Sprintf("%d subscriber(s) failed to handle event by id = %s of type = %s: %s",
+		len(e.Errs), e.EventID, e.EventType, strings.Join(msgs, "; "))
+}
+
+func (e *SubscriberError) Unwrap() []error {
+	return e.Errs
+}
+
+// Bus delivers published events to the handlers subscribed to them in the same process.
+//
+// A synchronous bus runs the handlers in Publish, in the order they subscribed. A failing or
+// panicking handler doesn't stop the others from handling the event, and Publish returns a
+// SubscriberError with the failures.
+//
+// An asynchronous bus only queues the event in Publish. A single worker runs the handlers in the
+// order the events were published, and tells the error handler about the failures. Publish blocks
+// while the queue is full until ctx is done. Events that are queued when the process exits are
+// lost, so Close must be called to deliver them first.
+type Bus struct {
+	handlersMu sync.RWMutex
+	handlers   []Handler
+	mu         sync.RWMutex // guards closed, so that nothing is queued after the queue is closed
+	closed     bool
+	onError    ErrorHandler
+	queueSize  int
+	queue      chan queuedEvent
+	workerDone chan struct{}
+	async      bool
+}
+
+type queuedEvent struct {
+	ctx   context.Context
+	event order.Event
+}
+
+type BusOption func(b *Bus)
+
+// WithAsyncDelivery makes the bus deliver events asynchronously from a queue holding up to
+// queueSize events
+func WithAsyncDelivery(queueSize int) BusOption {
+	return func(b *Bus) {
+		b.async = true
+		b.queueSize = queueSize
+	}
+}
+
+// WithErrorHandler sets the handler an asynchronous bus tells about the events its subscribers
+// fail to handle. Failures are logged by default.
+func WithErrorHandler(onError ErrorHandler) BusOption {
+	return func(b *Bus) {
+		b.onError = onError
+	}
+}
+
+// NewBus returns a synchronous Bus unless WithAsyncDelivery is given
+func NewBus(opts ...BusOption) *Bus {
+	b := &Bus{
+		onError: func(_ context.Context, e order.Event, err error) {
+			log.Printf("event bus: could not deliver event = %s (%s): %v", e.EventID(), e.EventType(), err)
+		},
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	if b.async {
+		b.queue = make(chan queuedEvent, b.queueSize)
+		b.workerDone = make(chan struct{})
+		go b.work()
+	}
+
+	return b
+}
+
+// SubscribeAll makes the handler handle every event published on the bus
+func (b *Bus) SubscribeAll(handler Handler) {
+	b.handlersMu.Lock()
+	defer b.handlersMu.Unlock()
+
+	b.handlers = append(b.handlers, handler)
+}
+
+// Subscribe makes the handler handle the events published on the bus whose payloads are of type
+// P, e.g.
+//
+//	publisher.Subscribe(bus, func(ctx context.Context, e order.Event, submitted order.OrderSubmitted) error {
+//		...
+//	})
+func Subscribe[P order.EventPayload](b *Bus, handler func(ctx context.Context, e order.Event, payload P) error) {
+	b.SubscribeAll(func(ctx context.Context, e order.Event) error {
+		payload, ok := e.Payload().(P)
+		if !ok {
+			return nil
+		}
+
+		return handler(ctx, e, payload)
+	})
+}
+
+// Publish delivers the event to the subscribers, see Bus for how failures are handled
+func (b *Bus) Publish(ctx context.Context, e order.Event) error {
+	if !b.async {
+		if b.isClosed() {
+			return ErrBusClosed
+		}
+
+		return b.deliver(ctx, e)
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return ErrBusClosed
+	}
+
+	select {
+	case b.queue <- queuedEvent{ctx: detachedContext{ctx}, event: e}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("could not queue event by id = %s: %w", e.EventID(), ctx.Err())
+	}
+}
+
+// Close stops the bus from accepting events. An asynchronous bus delivers the queued events before
+// Close returns.
+func (b *Bus) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	if b.async {
+		close(b.queue)
+	}
+	b.mu.Unlock()
+
+	if b.async {
+		<-b.workerDone
+	}
+
+	return nil
+}
+
+func (b *Bus) isClosed() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.closed
+}
+
+func (b *Bus) work() {
+	defer close(b.workerDone)
+
+	for queued := range b.queue {
+		if err := b.deliver(queued.ctx, queued.event); err != nil {
+			b.onError(queued.ctx, queued.event, err)
+		}
+	}
+}
+
+func (b *Bus) deliver(ctx context.Context, e order.Event) error {
+	// handlers may subscribe while the event is delivered
+	b.handlersMu.RLock()
+	handlers := b.handlers
+	b.handlersMu.RUnlock()
+
+	var errs []error
+	for _, handler := range handlers {
+		if err := handle(ctx, e, handler); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return &SubscriberError{EventID: e.EventID(), EventType: e.EventType(), Errs: errs}
+	}
+
+	return nil
+}
+
+// handle runs the handler, turning a panic into an error so that it doesn't crash the publisher
+func handle(ctx context.Context, e order.Event, handler Handler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("subscriber panicked: %v", r)
+		}
+	}()
+
+	return handler(ctx, e)
+}
+
+// detachedContext keeps the values of the context an event was published with, e.g. its trace,
+// but not its deadline, since the event is delivered after Publish returns
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
@@ -0,0 +1,181 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+var errAny = errors.New("any error")
+
+// newTestEvents returns the events raised when an order is created and submitted
+func newTestEvents(t *testing.T) []order.Event {
+	t.Helper()
+	unitPrice, _ := order.NewMoney(1000, "EUR")
+	o, err := order.NewOrder("0f8fad5b-d9cb-469f-a165-70867728950e", []order.OrderItem{{ArticleNo: "A1", Quantity: 1, UnitPrice: unitPrice}}, order.OrderStatePending)
+	require.NoError(t, err)
+	require.NoError(t, o.Submit())
+
+	return o.Events()
+}
+
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) record(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, name)
+}
+
+func (r *recorder) recorded() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+func TestBus_Sync(t *testing.T) {
+	// Given
+	require := require.New(t)
+	events := newTestEvents(t)
+	rec := &recorder{}
+	bus := NewBus()
+	bus.SubscribeAll(func(ctx context.Context, e order.Event) error {
+		rec.record("all:" + e.EventType())
+		return nil
+	})
+	Subscribe(bus, func(ctx context.Context, e order.Event, submitted order.OrderSubmitted) error {
+		rec.record("submitted:" + submitted.Total.Decimal())
+		return nil
+	})
+	Subscribe(bus, func(ctx context.Context, e order.Event, cancelled order.OrderCancelled) error {
+		rec.record("cancelled")
+		return nil
+	})
+
+	// When
+	for _, e := range events {
+		require.NoError(bus.Publish(context.Background(), e))
+	}
+
+	// Then the handlers ran in the order they subscribed, and only for the payloads they subscribed to
+	require.Equal([]string{"all:" + order.EventTypeOrderCreated, "all:" + order.EventTypeOrderSubmitted, "submitted:10.00"}, rec.recorded())
+
+	// When the bus is closed
+	require.NoError(bus.Close())
+
+	// Then
+	require.True(errors.Is(bus.Publish(context.Background(), events[0]), ErrBusClosed))
+}
+
+func TestBus_Sync_Failures(t *testing.T) {
+	// Given
+	require := require.New(t)
+	event := newTestEvents(t)[0]
+	rec := &recorder{}
+	bus := NewBus()
+	bus.SubscribeAll(func(ctx context.Context, e order.Event) error { return errAny })
+	bus.SubscribeAll(func(ctx context.Context, e order.Event) error { panic("boom") })
+	bus.SubscribeAll(func(ctx context.Context, e order.Event) error {
+		rec.record(e.EventType())
+		return nil
+	})
+
+	// When
+	err := bus.Publish(context.Background(), event)
+
+	// Then the failures are returned, but don't stop the other subscribers
+	var subscriberErr *SubscriberError
+	require.True(errors.As(err, &subscriberErr), "expected %v to be a SubscriberError", err)
+	require.Equal(event.EventID(), subscriberErr.EventID)
+	require.Len(subscriberErr.Errs, 2)
+	require.True(errors.Is(err, errAny), "expected %v to wrap %v", err, errAny)
+	require.Contains(subscriberErr.Errs[1].Error(), "boom")
+	require.Equal([]string{order.EventTypeOrderCreated}, rec.recorded())
+}
+
+func TestBus_Async(t *testing.T) {
+	// Given
+	require := require.New(t)
+	events := newTestEvents(t)
+	rec := &recorder{}
+	var failed []error
+	bus := NewBus(WithAsyncDelivery(10), WithErrorHandler(func(ctx context.Context, e order.Event, err error) {
+		failed = append(failed, err)
+	}))
+	bus.SubscribeAll(func(ctx context.Context, e order.Event) error {
+		if ctx.Err() == nil {
+			rec.record(e.EventType() + ":" + order.MetadataFromContext(ctx).CorrelationID)
+		}
+		return nil
+	})
+	Subscribe(bus, func(ctx context.Context, e order.Event, _ order.OrderSubmitted) error { return errAny })
+
+	// When the events are published with a context that's cancelled before they're delivered
+	ctx, cancel := context.WithCancel(order.ContextWithMetadata(context.Background(), order.Metadata{CorrelationID: "correlation-1"}))
+	for _, e := range events {
+		require.NoError(bus.Publish(ctx, e))
+	}
+	cancel()
+	require.NoError(bus.Close())
+
+	// Then they're all delivered in order before Close returns, and failures go to the error handler
+	require.Equal([]string{order.EventTypeOrderCreated + ":correlation-1", order.EventTypeOrderSubmitted + ":correlation-1"}, rec.recorded())
+	require.Len(failed, 1)
+	require.True(errors.Is(failed[0], errAny), "expected %v to wrap %v", failed[0], errAny)
+	require.True(errors.Is(bus.Publish(context.Background(), events[0]), ErrBusClosed))
+}
+
+func TestBus_Async_FullQueue(t *testing.T) {
+	// Given a bus whose only worker is blocked and whose queue is full
+	require := require.New(t)
+	events := newTestEvents(t)
+	unblock := make(chan struct{})
+	bus := NewBus(WithAsyncDelivery(1))
+	bus.SubscribeAll(func(ctx context.Context, e order.Event) error {
+		<-unblock
+		return nil
+	})
+	require.NoError(bus.Publish(context.Background(), events[0]))
+	require.Eventually(func() bool { return len(bus.queue) == 0 }, time.Second, time.Millisecond)
+	require.NoError(bus.Publish(context.Background(), events[0]))
+
+	// When
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := bus.Publish(ctx, events[1])
+
+	// Then Publish gives up once ctx is done
+	require.True(errors.Is(err, context.DeadlineExceeded), "expected %v to wrap %v", err, context.DeadlineExceeded)
+	close(unblock)
+	require.NoError(bus.Close())
+}
+
+func TestBus_Async_LogsFailuresByDefault(t *testing.T) {
+	// Given an asynchronous bus without an error handler
+	require := require.New(t)
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	event := newTestEvents(t)[0]
+	bus := NewBus(WithAsyncDelivery(1))
+	bus.SubscribeAll(func(ctx context.Context, e order.Event) error { return errAny })
+
+	// When
+	require.NoError(bus.Publish(context.Background(), event))
+	require.NoError(bus.Close())
+
+	// Then the failure is logged
+	require.Contains(logged.String(), event.EventID())
+	require.Contains(logged.String(), errAny.Error())
+}
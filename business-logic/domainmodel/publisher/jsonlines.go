@@ -0,0 +1,69 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+// JSONLinesPublisher writes events as JSON lines, serialized by order.MarshalEvent. It's meant for
+// debugging locally, e.g. by following the file the events are written to.
+type JSONLinesPublisher struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewJSONLinesPublisher returns a JSONLinesPublisher writing to w. Each event is written with a
+// single call to w.Write, so the lines of concurrently published events aren't interleaved.
+func NewJSONLinesPublisher(w io.Writer) *JSONLinesPublisher {
+	return &JSONLinesPublisher{w: w}
+}
+
+// OpenJSONLinesFile returns a JSONLinesPublisher appending to the file at path, which is created
+// if it doesn't exist. The file is closed by Close.
+func OpenJSONLinesFile(path string) (*JSONLinesPublisher, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open event file: %w", err)
+	}
+
+	return &JSONLinesPublisher{w: f, closer: f}, nil
+}
+
+// Publish writes the event as a line. An error is returned if the line couldn't be written
+// completely, in which case a partial line may have been written.
+func (p *JSONLinesPublisher) Publish(ctx context.Context, e order.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	line, err := order.MarshalEvent(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err = p.w.Write(line); err != nil {
+		return fmt.Errorf("could not write event by id = %s: %w", e.EventID(), err)
+	}
+
+	return nil
+}
+
+// Close closes the file opened by OpenJSONLinesFile. Writers given to NewJSONLinesPublisher are
+// left open.
+func (p *JSONLinesPublisher) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+
+	return p.closer.Close()
+}
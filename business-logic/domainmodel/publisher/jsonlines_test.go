@@ -0,0 +1,60 @@
+package publisher
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errAny }
+
+func TestJSONLinesPublisher(t *testing.T) {
+	// Given
+	require := require.New(t)
+	events := newTestEvents(t)
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	p, err := OpenJSONLinesFile(path)
+	require.NoError(err)
+
+	// When
+	for _, e := range events {
+		require.NoError(p.Publish(context.Background(), e))
+	}
+	require.NoError(p.Close())
+
+	// Then every event is written as a line that deserializes to the event
+	f, err := os.Open(path)
+	require.NoError(err)
+	defer f.Close()
+	var lines int
+	scanner := bufio.NewScanner(f)
+	for ; scanner.Scan(); lines++ {
+		e, err := order.UnmarshalEvent(scanner.Bytes())
+		require.NoError(err)
+		require.Equal(events[lines].EventID(), e.EventID())
+		require.Equal(events[lines].Payload().EventType(), e.Payload().EventType())
+	}
+	require.NoError(scanner.Err())
+	require.Equal(len(events), lines)
+}
+
+func TestJSONLinesPublisher_Failures(t *testing.T) {
+	require := require.New(t)
+	event := newTestEvents(t)[0]
+
+	err := NewJSONLinesPublisher(failingWriter{}).Publish(context.Background(), event)
+	require.True(errors.Is(err, errAny), "expected %v to wrap %v", err, errAny)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = NewJSONLinesPublisher(failingWriter{}).Publish(ctx, event)
+	require.True(errors.Is(err, context.Canceled), "expected %v to wrap %v", err, context.Canceled)
+}
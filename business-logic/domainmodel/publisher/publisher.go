@@ -0,0 +1,28 @@
+/*
+Package publisher implements the event publishers domainmodel.Service publishes the domain events
+of orders with.
+
+  - Bus delivers events to subscribers in the same process, either synchronously while Publish is
+    called or asynchronously from a queue.
+  - JSONLinesPublisher writes events as JSON lines, e.g. to a file for local debugging.
+  - WebhookPublisher posts events to an HTTP endpoint, signed with HMAC-SHA256.
+
+A publisher returns an error from Publish when it can't tell whether the event was delivered, so
+the caller decides what to do about it. Events may be delivered more than once, e.g. when a webhook
+responds after its deadline, so subscribers should deduplicate them by event id.
+*/
+package publisher
+
+import (
+	"context"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+// Handler handles a published event. Publishers have a Publish method of this type, so they can
+// subscribe to a Bus to forward its events.
+type Handler func(ctx context.Context, e order.Event) error
+
+// ErrorHandler is told about the events that failed to be delivered when there's no caller to
+// return the error to
+type ErrorHandler func(ctx context.Context, e order.Event, err error)
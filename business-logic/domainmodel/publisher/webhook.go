@@ -0,0 +1,168 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+// Headers of the requests posted by WebhookPublisher
+const (
+	HeaderEventID   = "X-Event-Id"
+	HeaderEventType = "X-Event-Type"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const signaturePrefix = "sha256="
+
+// ErrWebhookRejected is returned by WebhookPublisher.Publish when the webhook responds that the
+// event will never be accepted, i.e. with a 4xx status other than 408 and 429
+var ErrWebhookRejected = errors.New("webhook rejected event")
+
+// WebhookPublisher posts events, serialized by order.MarshalEvent, to a webhook. Requests are
+// signed with HMAC-SHA256 over the timestamp and body, so the webhook can verify them with
+// VerifySignature.
+//
+// Events are posted again when the webhook can't be reached or responds with a 5xx, 408 or 429
+// status, up to the configured number of attempts with exponential backoff in between. Publish
+// returns the last failure once the attempts are used up or ctx is done. Since an event may be
+// posted again after the webhook handled it, the webhook should deduplicate events by
+// HeaderEventID.
+type WebhookPublisher struct {
+	url      string
+	secret   []byte
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+	now      func() time.Time
+}
+
+type WebhookOption func(p *WebhookPublisher)
+
+// WithHTTPClient sets the client the events are posted with
+func WithHTTPClient(client *http.Client) WebhookOption {
+	return func(p *WebhookPublisher) {
+		p.client = client
+	}
+}
+
+// WithRetries sets how many times an event is posted at most, and how long to wait before the
+// second attempt. The wait is doubled before every following attempt.
+func WithRetries(attempts int, backoff time.Duration) WebhookOption {
+	return func(p *WebhookPublisher) {
+		p.attempts = attempts
+		p.backoff = backoff
+	}
+}
+
+// WithWebhookClock makes the publisher timestamp requests with now instead of time.Now
+func WithWebhookClock(now func() time.Time) WebhookOption {
+	return func(p *WebhookPublisher) {
+		p.now = now
+	}
+}
+
+// NewWebhookPublisher returns a WebhookPublisher posting to url. Events are posted up to 3 times
+// unless WithRetries is given.
+func NewWebhookPublisher(url string, secret []byte, opts ...WebhookOption) *WebhookPublisher {
+	p := &WebhookPublisher{
+		url:      url,
+		secret:   secret,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		attempts: 3,
+		backoff:  500 * time.Millisecond,
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// Publish posts the event to the webhook, see WebhookPublisher for how failures are handled
+func (p *WebhookPublisher) Publish(ctx context.Context, e order.Event) error {
+	body, err := order.MarshalEvent(e)
+	if err != nil {
+		return err
+	}
+
+	backoff := p.backoff
+	for attempt := 1; ; attempt++ {
+		retryable, err := p.post(ctx, e, body)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= p.attempts {
+			return fmt.Errorf("could not post event by id = %s after %d attempt(s): %w", e.EventID(), attempt, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("could not post event by id = %s after %d attempt(s): %w", e.EventID(), attempt, err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post posts the event once and tells whether it's worth trying again if it fails
+func (p *WebhookPublisher) post(ctx context.Context, e order.Event, body []byte) (retryable bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("could not create request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(p.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEventID, e.EventID())
+	req.Header.Set(HeaderEventType, e.EventType())
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(p.secret, timestamp, body))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	// drain the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook responded with status = %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("%w: webhook responded with status = %d", ErrWebhookRejected, resp.StatusCode)
+	}
+}
+
+// Sign returns the signature of a webhook request with the given timestamp and body, as sent in
+// HeaderSignature
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature tells whether the signature of a webhook request was made with the secret. The
+// webhook should also reject requests whose timestamps are too old, so they can't be replayed.
+func VerifySignature(secret []byte, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
@@ -0,0 +1,135 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+var webhookSecret = []byte("webhook-secret")
+
+func TestWebhookPublisher(t *testing.T) {
+	// Given a webhook that verifies the requests
+	require := require.New(t)
+	event := newTestEvents(t)[1]
+	received := make(chan order.Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(HeaderTimestamp) != "1700000000" ||
+			!VerifySignature(webhookSecret, r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		e, err := order.UnmarshalEvent(body)
+		if err != nil || e.EventID() != r.Header.Get(HeaderEventID) || e.EventType() != r.Header.Get(HeaderEventType) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- e
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	p := NewWebhookPublisher(server.URL, webhookSecret, WithWebhookClock(func() time.Time { return time.Unix(1700000000, 0) }))
+
+	// When
+	err := p.Publish(context.Background(), event)
+
+	// Then
+	require.NoError(err)
+	require.Equal(event.EventID(), (<-received).EventID())
+
+	// When the request is signed with another secret
+	err = NewWebhookPublisher(server.URL, []byte("other-secret")).Publish(context.Background(), event)
+
+	// Then it's rejected without being posted again
+	require.True(errors.Is(err, ErrWebhookRejected), "expected %v to wrap %v", err, ErrWebhookRejected)
+	require.Contains(err.Error(), "after 1 attempt(s)")
+}
+
+func TestWebhookPublisher_Retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int // the statuses of the responses to the attempts, the last one is repeated
+		attempts     int
+		wantErr      error
+		wantAttempts int32
+	}{
+		{
+			name:         "should post again until it succeeds",
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK},
+			attempts:     3,
+			wantAttempts: 3,
+		},
+		{
+			name:         "should give up after the last attempt",
+			statuses:     []int{http.StatusInternalServerError},
+			attempts:     3,
+			wantErr:      errors.New("webhook responded with status = 500"),
+			wantAttempts: 3,
+		},
+		{
+			name:         "should not post again when rejected",
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusUnprocessableEntity},
+			attempts:     3,
+			wantErr:      ErrWebhookRejected,
+			wantAttempts: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			var attempts int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempt := int(atomic.AddInt32(&attempts, 1))
+				if attempt > len(tt.statuses) {
+					attempt = len(tt.statuses)
+				}
+				w.WriteHeader(tt.statuses[attempt-1])
+			}))
+			defer server.Close()
+			p := NewWebhookPublisher(server.URL, webhookSecret, WithRetries(tt.attempts, time.Millisecond))
+
+			// When
+			err := p.Publish(context.Background(), newTestEvents(t)[0])
+
+			// Then
+			switch {
+			case tt.wantErr == nil:
+				require.NoError(err)
+			case errors.Is(tt.wantErr, ErrWebhookRejected):
+				require.True(errors.Is(err, tt.wantErr), "expected %v to wrap %v", err, tt.wantErr)
+			default:
+				require.ErrorContains(err, tt.wantErr.Error())
+			}
+			require.Equal(tt.wantAttempts, atomic.LoadInt32(&attempts))
+		})
+	}
+}
+
+func TestWebhookPublisher_Unreachable(t *testing.T) {
+	// Given a webhook that can't be reached
+	require := require.New(t)
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	p := NewWebhookPublisher(server.URL, webhookSecret, WithRetries(100, 10*time.Millisecond))
+
+	// When
+	err := p.Publish(ctx, newTestEvents(t)[0])
+
+	// Then it's posted again until ctx is done
+	require.Error(err)
+	require.NotContains(err.Error(), "after 1 attempt(s)")
+	require.NotContains(err.Error(), "after 100 attempt(s)")
+}
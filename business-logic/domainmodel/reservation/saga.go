@@ -0,0 +1,120 @@
+/*
+Package reservation is a saga, or process manager, that reserves stock for orders when they're
+submitted. It reacts to the domain events of orders:
+
+  - order.submitted reserves stock for the items of the order. If there isn't enough stock, the
+    order is rejected instead, which is the compensating command of the saga.
+  - order.cancelled releases the stock reserved for the order, if any.
+  - order.shipped takes the stock reserved for the order off the shelf.
+
+The saga is run by a projection.Runner, which feeds it the events of the order event store. The
+state of the saga, the stock and the checkpoint of the runner are all saved in the same
+transaction, so every event is handled exactly once, even across restarts. Orders are rejected
+through the application service, which joins the transaction of the runner and publishes the
+events of the rejected orders once it's committed.
+*/
+package reservation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/inventory"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+const SagaName = "stock-reservations"
+
+// Tx is the database transaction the saga runs in
+type Tx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// orderService is the application service orders are rejected through, e.g. domainmodel.Service.
+// It must begin its transactions with a sqltx.Beginner to join the transaction of the runner.
+type orderService interface {
+	RejectOrder(ctx context.Context, id, reason string) error
+}
+
+// Saga reserves, releases and fulfils stock for orders and rejects orders it can't reserve stock for
+type Saga struct {
+	orders orderService
+}
+
+func NewSaga(orders orderService) *Saga {
+	return &Saga{orders: orders}
+}
+
+func (*Saga) Name() string {
+	return SagaName
+}
+
+func (s *Saga) Handle(ctx context.Context, tx *sql.Tx, e order.Event) error {
+	switch p := e.Payload().(type) {
+	case order.OrderSubmitted:
+		return s.reserve(ctx, tx, e, p.Items)
+	case order.OrderCancelled:
+		return s.changeReserved(ctx, tx, e, StatusReleased, (*inventory.Service).Release)
+	case order.OrderShipped:
+		return s.changeReserved(ctx, tx, e, StatusFulfilled, (*inventory.Service).Fulfil)
+	default:
+		return nil
+	}
+}
+
+// Reset refuses to reset the saga, since handling the events again would reserve stock again
+func (*Saga) Reset(ctx context.Context, tx *sql.Tx) error {
+	return fmt.Errorf("saga = %s can't be rebuilt", SagaName)
+}
+
+// reserve reserves stock for the items of a submitted order, or rejects the order if there isn't
+// enough stock
+func (s *Saga) reserve(ctx context.Context, tx *sql.Tx, e order.Event, items []order.OrderItem) error {
+	if _, found, err := FindState(ctx, tx, e.AggregateID()); err != nil || found {
+		return err
+	}
+
+	state := State{OrderID: e.AggregateID(), Status: StatusReserved, Items: items, UpdatedAt: e.OccurredAt()}
+	err := inventory.NewService(inventory.NewRepository(tx)).Reserve(ctx, items)
+	if errors.Is(err, inventory.ErrInsufficientStock) {
+		state.Status, state.Reason = StatusRejected, err.Error()
+		if err = s.rejectOrder(ctx, e); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return fmt.Errorf("could not reserve stock for order = %s: %w", e.AggregateID(), err)
+	}
+
+	return saveState(ctx, tx, state)
+}
+
+// rejectOrder is the compensating command for an order that stock can't be reserved for
+func (s *Saga) rejectOrder(ctx context.Context, e order.Event) error {
+	ctx = order.ContextWithMetadata(ctx, order.Metadata{CorrelationID: e.CorrelationID(), CausationID: e.EventID()})
+	if err := s.orders.RejectOrder(ctx, string(e.AggregateID()), "insufficient stock"); err != nil {
+		return fmt.Errorf("could not reject order = %s: %w", e.AggregateID(), err)
+	}
+
+	return nil
+}
+
+// changeReserved changes the stock reserved for an order, if there is any, and moves the saga to
+// the given status
+func (s *Saga) changeReserved(ctx context.Context, tx *sql.Tx, e order.Event, status Status,
+	command func(*inventory.Service, context.Context, []order.OrderItem) error) error {
+	state, found, err := FindState(ctx, tx, e.AggregateID())
+	if err != nil || !found || state.Status != StatusReserved {
+		return err
+	}
+
+	if err = command(inventory.NewService(inventory.NewRepository(tx)), ctx, state.Items); err != nil {
+		return fmt.Errorf("could not change stock reserved for order = %s: %w", e.AggregateID(), err)
+	}
+
+	state.Status, state.UpdatedAt = status, e.OccurredAt()
+
+	return saveState(ctx, tx, state)
+}
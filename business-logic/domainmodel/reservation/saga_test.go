@@ -0,0 +1,249 @@
+package reservation
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/customer"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/inventory"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/projection"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/migrations"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/sqltx"
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "reservation.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	runner, err := migrations.NewRunner(db, migrations.SQLite)
+	require.NoError(t, err)
+	require.NoError(t, runner.Up(context.Background()))
+
+	return db
+}
+
+// recordingPublisher records the events it's given
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []order.Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, e order.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, e)
+
+	return nil
+}
+
+func (p *recordingPublisher) published() []order.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]order.Event(nil), p.events...)
+}
+
+// newOrderService returns an application service that stores orders with event sourcing in the
+// transactions of the runner
+func newOrderService(db *sql.DB, publisher *recordingPublisher) *domainmodel.Service {
+	return domainmodel.NewService(order.NewTxMaker(sqltx.NewBeginner(db)), publisher,
+		domainmodel.WithEventSourcing(0), domainmodel.WithCustomerCredit())
+}
+
+// inTx runs fn in a transaction that's committed unless fn fails the test
+func inTx(t *testing.T, db *sql.DB, fn func(tx *sql.Tx)) {
+	t.Helper()
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	fn(tx)
+	require.NoError(t, tx.Commit())
+}
+
+// submitOrder stores a submitted order of the items with event sourcing. Its submission is
+// correlated with the checkout of the order.
+func submitOrder(t *testing.T, db *sql.DB, customerID order.CustomerID, items ...order.OrderItem) order.ID {
+	t.Helper()
+	o, err := order.NewOrder(customerID, items, order.OrderStatePending)
+	require.NoError(t, err)
+	o.Trace(order.Metadata{CorrelationID: "checkout-" + string(o.ID())})
+	require.NoError(t, o.Submit())
+	inTx(t, db, func(tx *sql.Tx) {
+		require.NoError(t, order.NewEventSourcedRepository(order.NewSQLEventStore(tx)).Upsert(context.Background(), o))
+	})
+
+	return o.ID()
+}
+
+// changeOrder runs the command against the stored order and stores its new events
+func changeOrder(t *testing.T, db *sql.DB, id order.ID, command func(o *order.Order) error) {
+	t.Helper()
+	ctx := context.Background()
+	inTx(t, db, func(tx *sql.Tx) {
+		repo := order.NewEventSourcedRepository(order.NewSQLEventStore(tx))
+		o, err := repo.FindByID(ctx, string(id))
+		require.NoError(t, err)
+		require.NoError(t, command(o))
+		require.NoError(t, repo.Upsert(ctx, o))
+	})
+}
+
+func findOrder(t *testing.T, db *sql.DB, id order.ID) *order.Order {
+	t.Helper()
+	var o *order.Order
+	inTx(t, db, func(tx *sql.Tx) {
+		var err error
+		o, err = order.NewEventSourcedRepository(order.NewSQLEventStore(tx)).FindByID(context.Background(), string(id))
+		require.NoError(t, err)
+	})
+
+	return o
+}
+
+func findState(t *testing.T, db *sql.DB, id order.ID) State {
+	t.Helper()
+	state, found, err := FindState(context.Background(), db, id)
+	require.NoError(t, err)
+	require.True(t, found, "expected saga state of order = %s", id)
+
+	return state
+}
+
+func findStock(t *testing.T, db *sql.DB, articleNo string) *inventory.Stock {
+	t.Helper()
+	var stock *inventory.Stock
+	inTx(t, db, func(tx *sql.Tx) {
+		var err error
+		stock, err = inventory.NewRepository(tx).FindByArticleNo(context.Background(), articleNo)
+		require.NoError(t, err)
+	})
+
+	return stock
+}
+
+func TestSaga(t *testing.T) {
+	// Given
+	require := require.New(t)
+	ctx := context.Background()
+	db := openTestDB(t)
+	eur := func(amount int64) order.Money {
+		m, _ := order.NewMoney(amount, "EUR")
+		return m
+	}
+
+	c, err := customer.NewCustomer("Jane Doe", eur(100000))
+	require.NoError(err)
+	inTx(t, db, func(tx *sql.Tx) {
+		stock, err := inventory.NewStock("A1", 5)
+		require.NoError(err)
+		require.NoError(inventory.NewRepository(tx).Upsert(ctx, stock))
+		require.NoError(c.ReserveCredit(eur(4000)))
+		require.NoError(customer.NewRepository(tx).Upsert(ctx, c))
+	})
+	publisher := &recordingPublisher{}
+	runner := projection.NewRunner(db, NewSaga(newOrderService(db, publisher)))
+
+	// When orders are submitted
+	reserved := submitOrder(t, db, c.ID(), order.OrderItem{ArticleNo: "A1", Quantity: 2, UnitPrice: eur(1000)})
+	rejected := submitOrder(t, db, c.ID(), order.OrderItem{ArticleNo: "A1", Quantity: 4, UnitPrice: eur(1000)})
+	require.NoError(runner.CatchUp(ctx))
+
+	// Then stock is reserved for the first and the second is rejected since there isn't enough left
+	require.Equal(StatusReserved, findState(t, db, reserved).Status)
+	require.Equal(2, findStock(t, db, "A1").Reserved())
+
+	rejectedState := findState(t, db, rejected)
+	require.Equal(StatusRejected, rejectedState.Status)
+	require.Contains(rejectedState.Reason, "insufficient stock")
+	require.Equal(order.OrderStateCancelled, findOrder(t, db, rejected).State())
+
+	// And the cancellation is published, caused by the submission
+	published := publisher.published()
+	require.Len(published, 1)
+	require.Equal(order.EventTypeOrderCancelled, published[0].EventType())
+	require.Equal(rejected, published[0].AggregateID())
+	var submittedEvent order.Event
+	inTx(t, db, func(tx *sql.Tx) {
+		stream, err := order.NewSQLEventStore(tx).ReadStream(ctx, rejected, 0)
+		require.NoError(err)
+		submittedEvent = stream[1]
+	})
+	require.Equal(order.EventTypeOrderSubmitted, submittedEvent.EventType())
+	require.Equal(submittedEvent.EventID(), published[0].CausationID())
+	require.Equal("checkout-"+string(rejected), published[0].CorrelationID())
+
+	// And the credit reserved for the cancelled order is given back
+	inTx(t, db, func(tx *sql.Tx) {
+		stored, err := customer.NewRepository(tx).FindByID(ctx, c.ID())
+		require.NoError(err)
+		require.Equal(eur(0), stored.CreditUsed())
+	})
+
+	// When the saga is restarted
+	require.NoError(projection.NewRunner(db, NewSaga(newOrderService(db, publisher))).CatchUp(ctx))
+
+	// Then no event is handled twice
+	require.Equal(2, findStock(t, db, "A1").Reserved())
+
+	// When an order with reserved stock is cancelled
+	changeOrder(t, db, reserved, func(o *order.Order) error { return o.Cancel("changed my mind") })
+	require.NoError(runner.CatchUp(ctx))
+
+	// Then its stock is released
+	require.Equal(StatusReleased, findState(t, db, reserved).Status)
+	require.Equal(0, findStock(t, db, "A1").Reserved())
+
+	// When an order with reserved stock is shipped
+	shipped := submitOrder(t, db, c.ID(), order.OrderItem{ArticleNo: "A1", Quantity: 3, UnitPrice: eur(1000)})
+	require.NoError(runner.CatchUp(ctx))
+	for _, command := range []func(o *order.Order) error{(*order.Order).Pay, (*order.Order).StartPicking, (*order.Order).Ship} {
+		changeOrder(t, db, shipped, command)
+	}
+	require.NoError(runner.CatchUp(ctx))
+
+	// Then its stock leaves the shelf
+	require.Equal(StatusFulfilled, findState(t, db, shipped).Status)
+	stock := findStock(t, db, "A1")
+	require.Equal(2, stock.OnHand())
+	require.Equal(0, stock.Reserved())
+
+	// And the saga can't be rebuilt
+	require.Error(runner.Rebuild(ctx, SagaName))
+}
+
+func TestSaga_HandlesEventOnce(t *testing.T) {
+	// Given
+	require := require.New(t)
+	ctx := context.Background()
+	db := openTestDB(t)
+	unitPrice, _ := order.NewMoney(1000, "EUR")
+	inTx(t, db, func(tx *sql.Tx) {
+		stock, err := inventory.NewStock("A1", 5)
+		require.NoError(err)
+		require.NoError(inventory.NewRepository(tx).Upsert(ctx, stock))
+	})
+	o, err := order.NewOrder("0f8fad5b-d9cb-469f-a165-70867728950e", []order.OrderItem{{ArticleNo: "A1", Quantity: 2, UnitPrice: unitPrice}}, order.OrderStatePending)
+	require.NoError(err)
+	require.NoError(o.Submit())
+	submitted := o.Events()[1]
+
+	// When the same event is handled twice
+	for i := 0; i < 2; i++ {
+		inTx(t, db, func(tx *sql.Tx) {
+			require.NoError(NewSaga(newOrderService(db, &recordingPublisher{})).Handle(ctx, tx, submitted))
+		})
+	}
+
+	// Then stock is only reserved once
+	require.Equal(2, findStock(t, db, "A1").Reserved())
+}
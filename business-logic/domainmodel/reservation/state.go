@@ -0,0 +1,83 @@
+package reservation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+const (
+	stateSelect = "SELECT status, items, reason, updated_at FROM stock_reservations WHERE order_id = $1;"
+	stateUpsert = "INSERT INTO stock_reservations (order_id, status, items, reason, updated_at) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (order_id) DO UPDATE SET status = excluded.status, reason = excluded.reason, updated_at = excluded.updated_at;"
+
+	timestampLayout = time.RFC3339Nano
+)
+
+// Status is how far the saga of an order has come
+type Status string
+
+const (
+	StatusReserved  Status = "reserved"  // stock is reserved for the order
+	StatusRejected  Status = "rejected"  // stock couldn't be reserved, so the order was cancelled
+	StatusReleased  Status = "released"  // the order was cancelled, so its stock was released
+	StatusFulfilled Status = "fulfilled" // the order was shipped, so its stock left the shelf
+)
+
+// State is the persisted state of the saga of an order. It's what lets the saga know what to do
+// with events of the order after a restart, e.g. whether there's any stock to release.
+type State struct {
+	OrderID   order.ID
+	Status    Status
+	Items     []order.OrderItem // the items stock is reserved for
+	Reason    string            // why stock couldn't be reserved
+	UpdatedAt time.Time
+}
+
+// FindState returns the state of the saga of the order, which is false if the order hasn't been
+// submitted yet
+func FindState(ctx context.Context, tx Tx, id order.ID) (State, bool, error) {
+	var (
+		rowStatus    string
+		rowItems     []byte
+		rowReason    string
+		rowUpdatedAt string
+	)
+	err := tx.QueryRowContext(ctx, stateSelect, string(id)).Scan(&rowStatus, &rowItems, &rowReason, &rowUpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return State{}, false, nil
+	}
+	if err != nil {
+		return State{}, false, fmt.Errorf("could not query stock reservation of order = %s: %w", id, err)
+	}
+
+	state := State{OrderID: id, Status: Status(rowStatus), Reason: rowReason}
+	if err = json.Unmarshal(rowItems, &state.Items); err != nil {
+		return State{}, false, fmt.Errorf("could not unmarshal items of stock reservation of order = %s: %w", id, err)
+	}
+
+	if state.UpdatedAt, err = time.Parse(timestampLayout, rowUpdatedAt); err != nil {
+		return State{}, false, fmt.Errorf("could not parse stock reservation of order = %s: %w", id, err)
+	}
+
+	return state, true, nil
+}
+
+func saveState(ctx context.Context, tx Tx, state State) error {
+	rowItems, err := json.Marshal(state.Items)
+	if err != nil {
+		return fmt.Errorf("could not marshal items of stock reservation of order = %s: %w", state.OrderID, err)
+	}
+
+	_, err = tx.ExecContext(ctx, stateUpsert, string(state.OrderID), string(state.Status), string(rowItems), state.Reason,
+		state.UpdatedAt.UTC().Format(timestampLayout))
+	if err != nil {
+		return fmt.Errorf("could not save stock reservation of order = %s: %w", state.OrderID, err)
+	}
+
+	return nil
+}
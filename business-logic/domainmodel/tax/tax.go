@@ -0,0 +1,326 @@
+// Package tax is a domain service that calculates the VAT or sales tax of orders according to the
+// rates of the jurisdiction they're shipped to. The tax breakdown is recorded on the orders
+// themselves, so it's part of the orders' events.
+package tax
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+// ErrUnknownJurisdiction is returned when there are no rates for the address an order is shipped to
+var ErrUnknownJurisdiction = apperror.New(apperror.CategoryValidation, "TAX_UNKNOWN_JURISDICTION", "unknown tax jurisdiction")
+
+// Category is a category of articles that may be taxed at a reduced rate
+type Category string
+
+// CategoryStandard is the category of articles taxed at the standard rate, which includes shipping
+const CategoryStandard Category = "standard"
+
+// Categories maps article numbers to their categories. Articles that aren't in it are taxed at
+// the standard rate.
+type Categories map[string]Category
+
+// Rounding is how tax is rounded to the minor unit of the currency
+type Rounding string
+
+const (
+	RoundPerLine  Rounding = "line"  // the tax of every order item is rounded
+	RoundPerTotal Rounding = "total" // the tax of all order items taxed at the same rate is rounded once
+)
+
+// Address is where an order is shipped to
+type Address struct {
+	Country string // ISO 3166-1 alpha-2 country code, e.g. SE
+	Region  string // the region within the country if it has its own rates, e.g. CA
+}
+
+// Jurisdiction is the tax rules of a country or a region within one. Rates are in basis points,
+// e.g. 2500 for 25%.
+type Jurisdiction struct {
+	Code         string             `json:"code"` // the country code, followed by the region if any, e.g. SE or US-CA
+	Inclusive    bool               `json:"inclusive"`
+	StandardRate int64              `json:"standardRate"`
+	ReducedRates map[Category]int64 `json:"reducedRates,omitempty"`
+	Rounding     Rounding           `json:"rounding"`
+	TaxShipping  bool               `json:"taxShipping"`
+}
+
+// rate returns the rate articles of the category are taxed at
+func (j Jurisdiction) rate(category Category) int64 {
+	if rate, ok := j.ReducedRates[category]; ok {
+		return rate
+	}
+
+	return j.StandardRate
+}
+
+// RateTable is the tax rules of all jurisdictions orders can be shipped to
+type RateTable struct {
+	jurisdictions map[string]Jurisdiction
+}
+
+//go:embed rates.json
+var defaultRates []byte
+
+// DefaultRateTable returns the rate table that's shipped with the package
+func DefaultRateTable() (*RateTable, error) {
+	return LoadRateTable(bytes.NewReader(defaultRates))
+}
+
+// LoadRateTable reads a rate table formatted like rates.json
+func LoadRateTable(r io.Reader) (*RateTable, error) {
+	var raw struct {
+		Jurisdictions []Jurisdiction `json:"jurisdictions"`
+	}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("could not decode rate table: %w", err)
+	}
+
+	table := &RateTable{jurisdictions: make(map[string]Jurisdiction, len(raw.Jurisdictions))}
+	for _, jurisdiction := range raw.Jurisdictions {
+		if err := validateJurisdiction(jurisdiction); err != nil {
+			return nil, err
+		}
+
+		code := strings.ToUpper(jurisdiction.Code)
+		if _, ok := table.jurisdictions[code]; ok {
+			return nil, fmt.Errorf("duplicate tax jurisdiction = %s", code)
+		}
+		table.jurisdictions[code] = jurisdiction
+	}
+
+	return table, nil
+}
+
+func validateJurisdiction(jurisdiction Jurisdiction) error {
+	if jurisdiction.Code == "" {
+		return fmt.Errorf("tax jurisdiction must have a code")
+	}
+
+	if jurisdiction.Rounding != RoundPerLine && jurisdiction.Rounding != RoundPerTotal {
+		return fmt.Errorf("tax jurisdiction = %s has an invalid rounding = %q", jurisdiction.Code, jurisdiction.Rounding)
+	}
+
+	if jurisdiction.StandardRate < 0 {
+		return fmt.Errorf("tax jurisdiction = %s must not have a negative standard rate", jurisdiction.Code)
+	}
+
+	for category, rate := range jurisdiction.ReducedRates {
+		if rate < 0 {
+			return fmt.Errorf("tax jurisdiction = %s must not have a negative rate for category = %s", jurisdiction.Code, category)
+		}
+	}
+
+	return nil
+}
+
+// Lookup returns the jurisdiction of the address, which is the region if it has rates of its own
+// and otherwise the country
+func (t *RateTable) Lookup(address Address) (Jurisdiction, error) {
+	country := strings.ToUpper(address.Country)
+	if address.Region != "" {
+		if jurisdiction, ok := t.jurisdictions[country+"-"+strings.ToUpper(address.Region)]; ok {
+			return jurisdiction, nil
+		}
+	}
+
+	if jurisdiction, ok := t.jurisdictions[country]; ok {
+		return jurisdiction, nil
+	}
+
+	return Jurisdiction{}, fmt.Errorf("could not find rates of country = %s region = %s: %w", address.Country, address.Region, ErrUnknownJurisdiction)
+}
+
+// Service calculates the tax of orders
+type Service struct {
+	rates      *RateTable
+	categories Categories
+}
+
+func NewService(rates *RateTable, categories Categories) *Service {
+	return &Service{rates: rates, categories: categories}
+}
+
+// Apply calculates the tax of the order and records it on the order
+func (s *Service) Apply(o *order.Order, address Address) error {
+	breakdown, err := s.Calculate(o, address)
+	if err != nil {
+		return err
+	}
+
+	return o.ApplyTax(breakdown)
+}
+
+// taxableLine is an amount that's taxed at the rate of its category
+type taxableLine struct {
+	category Category
+	amount   order.Money
+}
+
+// Calculate returns the tax of the order when it's shipped to the address. Tax is calculated from
+// what the customer pays, which means the discounts are taken off first. Discounts for a single
+// item are taken off that item and discounts for the whole order are spread over the items in
+// proportion to their amounts.
+func (s *Service) Calculate(o *order.Order, address Address) (order.TaxBreakdown, error) {
+	jurisdiction, err := s.rates.Lookup(address)
+	if err != nil {
+		return order.TaxBreakdown{}, err
+	}
+
+	lines, err := s.taxableLines(o, jurisdiction)
+	if err != nil {
+		return order.TaxBreakdown{}, fmt.Errorf("could not calculate tax of order = %s: %w", o.ID(), err)
+	}
+
+	breakdown := order.TaxBreakdown{Jurisdiction: jurisdiction.Code, Inclusive: jurisdiction.Inclusive}
+	index := make(map[Category]int)
+	for _, line := range lines {
+		rate := jurisdiction.rate(line.category)
+		i, ok := index[line.category]
+		if !ok {
+			i = len(breakdown.Lines)
+			index[line.category] = i
+			breakdown.Lines = append(breakdown.Lines, order.TaxLine{Category: string(line.category), Rate: rate})
+		}
+
+		taxLine := &breakdown.Lines[i]
+		if taxLine.Taxable, err = taxLine.Taxable.Add(line.amount); err != nil {
+			return order.TaxBreakdown{}, err
+		}
+
+		if jurisdiction.Rounding == RoundPerLine {
+			lineTax, err := taxOf(line.amount, rate, jurisdiction.Inclusive)
+			if err != nil {
+				return order.TaxBreakdown{}, err
+			}
+
+			if taxLine.Tax, err = taxLine.Tax.Add(lineTax); err != nil {
+				return order.TaxBreakdown{}, err
+			}
+		}
+	}
+
+	for i := range breakdown.Lines {
+		taxLine := &breakdown.Lines[i]
+		if jurisdiction.Rounding == RoundPerTotal {
+			if taxLine.Tax, err = taxOf(taxLine.Taxable, taxLine.Rate, jurisdiction.Inclusive); err != nil {
+				return order.TaxBreakdown{}, err
+			}
+		}
+
+		if breakdown.Total, err = breakdown.Total.Add(taxLine.Tax); err != nil {
+			return order.TaxBreakdown{}, err
+		}
+	}
+
+	return breakdown, nil
+}
+
+// taxableLines returns the amounts of the items and shipping after discounts
+func (s *Service) taxableLines(o *order.Order, jurisdiction Jurisdiction) ([]taxableLine, error) {
+	var (
+		lines         []taxableLine
+		orderDiscount order.Money
+		itemsTotal    order.Money
+		shipping      = o.ShippingFee()
+		itemDiscounts = make(map[string]order.Money)
+	)
+
+	var err error
+	for _, discount := range o.Discounts() {
+		switch {
+		case discount.Target == order.DiscountTargetShipping:
+			if shipping, err = shipping.Sub(discount.Amount); err != nil {
+				return nil, err
+			}
+		case discount.ArticleNo != "":
+			if itemDiscounts[discount.ArticleNo], err = itemDiscounts[discount.ArticleNo].Add(discount.Amount); err != nil {
+				return nil, err
+			}
+		default:
+			if orderDiscount, err = orderDiscount.Add(discount.Amount); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, item := range o.OrderItems() {
+		amount, err := item.LineTotal()
+		if err != nil {
+			return nil, err
+		}
+
+		if discount, ok := itemDiscounts[item.ArticleNo]; ok {
+			if amount, err = amount.Sub(discount); err != nil {
+				return nil, err
+			}
+			delete(itemDiscounts, item.ArticleNo)
+		}
+
+		category, ok := s.categories[item.ArticleNo]
+		if !ok {
+			category = CategoryStandard
+		}
+
+		lines = append(lines, taxableLine{category: category, amount: amount})
+		if itemsTotal, err = itemsTotal.Add(amount); err != nil {
+			return nil, err
+		}
+	}
+
+	if lines, err = allocate(lines, orderDiscount, itemsTotal); err != nil {
+		return nil, err
+	}
+
+	if jurisdiction.TaxShipping && !shipping.IsZero() {
+		lines = append(lines, taxableLine{category: CategoryStandard, amount: shipping})
+	}
+
+	return lines, nil
+}
+
+// allocate takes the discount off the lines in proportion to their amounts. The last line takes
+// what's left after rounding the shares of the others, so the shares add up to the discount.
+func allocate(lines []taxableLine, discount, total order.Money) ([]taxableLine, error) {
+	if discount.IsZero() || total.IsZero() {
+		return lines, nil
+	}
+
+	remaining := discount
+	for i := range lines {
+		share := remaining
+		if i < len(lines)-1 {
+			var err error
+			if share, err = discount.MultiplyRatio(lines[i].amount.Amount(), total.Amount()); err != nil {
+				return nil, err
+			}
+		}
+
+		var err error
+		if lines[i].amount, err = lines[i].amount.Sub(share); err != nil {
+			return nil, err
+		}
+		if remaining, err = remaining.Sub(share); err != nil {
+			return nil, err
+		}
+	}
+
+	return lines, nil
+}
+
+// taxOf returns the tax of the amount, which includes the tax if inclusive is true
+func taxOf(amount order.Money, rate int64, inclusive bool) (order.Money, error) {
+	if inclusive {
+		return amount.MultiplyRatio(rate, 10000+rate)
+	}
+
+	return amount.MultiplyRatio(rate, 10000)
+}
@@ -0,0 +1,182 @@
+package tax
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/domainmodel/order"
+)
+
+const customerID = "0f8fad5b-d9cb-469f-a165-70867728950e"
+
+func money(amount int64, currency order.Currency) order.Money {
+	m, err := order.NewMoney(amount, currency)
+	if err != nil {
+		panic(err)
+	}
+
+	return m
+}
+
+// line is an order item with a unit price in the currency of the order it's added to
+type line struct {
+	articleNo string
+	quantity  int
+	unitPrice int64
+}
+
+func newOrder(t *testing.T, currency order.Currency, shippingFee int64, discounts []order.Discount, lines ...line) *order.Order {
+	t.Helper()
+	var items []order.OrderItem
+	for _, l := range lines {
+		item, err := order.NewOrderItem(l.articleNo, l.quantity, money(l.unitPrice, currency))
+		require.NoError(t, err)
+		items = append(items, item)
+	}
+	o, err := order.NewOrder(customerID, items, order.OrderStatePending)
+	require.NoError(t, err)
+	require.NoError(t, o.ChargeShipping(money(shippingFee, currency)))
+	require.NoError(t, o.ApplyDiscounts(discounts))
+
+	return o
+}
+
+func TestService_Calculate(t *testing.T) {
+	rates, err := DefaultRateTable()
+	require.NoError(t, err)
+	service := NewService(rates, Categories{"F1": "food", "B1": "books"})
+
+	tests := []struct {
+		name      string
+		address   Address
+		order     func(t *testing.T) *order.Order
+		wantLines map[string][2]int64 // taxable amount and tax by category
+		wantTotal int64
+		wantErr   error
+	}{
+		{
+			name:    "should extract inclusive tax with reduced rates and shipping",
+			address: Address{Country: "se"},
+			order: func(t *testing.T) *order.Order {
+				return newOrder(t, "SEK", 4900, nil, line{"A1", 1, 12500}, line{"F1", 2, 5600}, line{"B1", 1, 10600})
+			},
+			// 125.00 + 49.00 at 25%, 112.00 at 12% and 106.00 at 6%
+			wantLines: map[string][2]int64{"standard": {17400, 3480}, "food": {11200, 1200}, "books": {10600, 600}},
+			wantTotal: 5280,
+		},
+		{
+			name:    "should add exclusive tax without taxing shipping",
+			address: Address{Country: "US", Region: "CA"},
+			order: func(t *testing.T) *order.Order {
+				return newOrder(t, "USD", 999, nil, line{"A1", 2, 1000}, line{"F1", 1, 300})
+			},
+			wantLines: map[string][2]int64{"standard": {2000, 145}, "food": {300, 0}},
+			wantTotal: 145,
+		},
+		{
+			name:    "should round every line",
+			address: Address{Country: "US", Region: "CA"},
+			order: func(t *testing.T) *order.Order {
+				return newOrder(t, "USD", 0, nil, line{"A1", 1, 105}, line{"A2", 1, 105}, line{"A3", 1, 105})
+			},
+			// 1.05 * 7.25% = 0.076125 which is rounded to 0.08 three times
+			wantLines: map[string][2]int64{"standard": {315, 24}},
+			wantTotal: 24,
+		},
+		{
+			name:    "should round the total of every rate",
+			address: Address{Country: "SE"},
+			order: func(t *testing.T) *order.Order {
+				return newOrder(t, "SEK", 0, nil, line{"A1", 1, 101}, line{"A2", 1, 101}, line{"A3", 1, 101})
+			},
+			// 1.01 * 20% = 0.202 which would be rounded to 0.20 three times, but 3.03 * 20% = 0.606
+			wantLines: map[string][2]int64{"standard": {303, 61}},
+			wantTotal: 61,
+		},
+		{
+			name:    "should take discounts off before calculating tax",
+			address: Address{Country: "US", Region: "NY"},
+			order: func(t *testing.T) *order.Order {
+				discounts := []order.Discount{
+					{PromotionID: "p1", Target: order.DiscountTargetItems, ArticleNo: "A1", Amount: money(1000, "USD")},
+					{PromotionID: "p2", Target: order.DiscountTargetItems, Amount: money(1000, "USD")},
+					{PromotionID: "p3", Target: order.DiscountTargetShipping, Amount: money(500, "USD")},
+				}
+				return newOrder(t, "USD", 500, discounts, line{"A1", 1, 3000}, line{"F1", 1, 2000})
+			},
+			// A1 is 30.00 - 10.00 and the order discount is shared equally by A1 and F1
+			wantLines: map[string][2]int64{"standard": {1500, 60}, "food": {1500, 0}},
+			wantTotal: 60,
+		},
+		{
+			name:    "should fall back to the rates of the country",
+			address: Address{Country: "DE", Region: "BY"},
+			order: func(t *testing.T) *order.Order {
+				return newOrder(t, "EUR", 0, nil, line{"A1", 1, 11900})
+			},
+			wantLines: map[string][2]int64{"standard": {11900, 1900}},
+			wantTotal: 1900,
+		},
+		{
+			name:    "should not calculate tax of unknown jurisdiction",
+			address: Address{Country: "XX"},
+			order: func(t *testing.T) *order.Order {
+				return newOrder(t, "EUR", 0, nil, line{"A1", 1, 100})
+			},
+			wantErr: ErrUnknownJurisdiction,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			o := tt.order(t)
+
+			// When
+			err := service.Apply(o, tt.address)
+
+			// Then
+			if tt.wantErr != nil {
+				require.True(errors.Is(err, tt.wantErr), "expected %v to wrap %v", err, tt.wantErr)
+				return
+			}
+			require.NoError(err)
+
+			breakdown, ok := o.Tax()
+			require.True(ok)
+			gotLines := make(map[string][2]int64)
+			for _, line := range breakdown.Lines {
+				gotLines[line.Category] = [2]int64{line.Taxable.Amount(), line.Tax.Amount()}
+			}
+			require.Equal(tt.wantLines, gotLines)
+			require.Equal(tt.wantTotal, breakdown.Total.Amount())
+		})
+	}
+}
+
+func TestLoadRateTable(t *testing.T) {
+	tests := []struct {
+		name    string
+		table   string
+		wantErr bool
+	}{
+		{name: "should load rate table", table: `{"jurisdictions": [{"code": "SE", "standardRate": 2500, "rounding": "line"}]}`},
+		{name: "should not load malformed rate table", table: `{"jurisdictions": [`, wantErr: true},
+		{name: "should not load jurisdiction without code", table: `{"jurisdictions": [{"standardRate": 2500, "rounding": "line"}]}`, wantErr: true},
+		{name: "should not load invalid rounding", table: `{"jurisdictions": [{"code": "SE", "standardRate": 2500, "rounding": "up"}]}`, wantErr: true},
+		{name: "should not load negative rate", table: `{"jurisdictions": [{"code": "SE", "standardRate": 2500, "reducedRates": {"food": -1}, "rounding": "line"}]}`, wantErr: true},
+		{
+			name:    "should not load duplicate jurisdictions",
+			table:   `{"jurisdictions": [{"code": "SE", "rounding": "line"}, {"code": "se", "rounding": "line"}]}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadRateTable(strings.NewReader(tt.table))
+			require.Equal(t, tt.wantErr, err != nil, "unexpected error: %v", err)
+		})
+	}
+}
@@ -0,0 +1,277 @@
+/*
+Package idempotency makes retried requests safe. Requests carrying an Idempotency-Key header are
+handled in a shared database transaction (see package sqltx) in which the key, a fingerprint of
+the request and the response are stored together with the changes the handler makes. Repeated
+requests with the same key get the stored response replayed instead of being handled again.
+*/
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/sqltx"
+)
+
+const (
+	// HeaderKey is the request header carrying the idempotency key
+	HeaderKey = "Idempotency-Key"
+
+	// HeaderReplayed is set on responses that are replayed from a previous request
+	HeaderReplayed = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+
+	// DefaultMaxBodyBytes is the size limit of the request bodies fingerprinted by middlewares that
+	// aren't given their own
+	DefaultMaxBodyBytes = 1 << 20
+
+	selectKey = "SELECT fingerprint, status, headers, body FROM idempotency_keys WHERE idempotency_key = $1;"
+	insertKey = "INSERT INTO idempotency_keys (idempotency_key, fingerprint, status, headers, body, created_at) VALUES ($1, $2, $3, $4, $5, $6);"
+)
+
+type storedResponse struct {
+	fingerprint string
+	status      int
+	header      http.Header
+	body        []byte
+}
+
+type Middleware struct {
+	db           *sql.DB
+	now          func() time.Time
+	maxBodyBytes int64
+}
+
+type MiddlewareOption func(m *Middleware)
+
+// WithMaxBodyBytes limits the size of the request bodies, which are read in full to fingerprint
+// the requests
+func WithMaxBodyBytes(maxBytes int64) MiddlewareOption {
+	return func(m *Middleware) {
+		m.maxBodyBytes = maxBytes
+	}
+}
+
+func NewMiddleware(db *sql.DB, opts ...MiddlewareOption) *Middleware {
+	m := &Middleware{db: db, now: time.Now, maxBodyBytes: DefaultMaxBodyBytes}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// Wrap returns a handler that honours the Idempotency-Key header of requests to next. Requests
+// without the header are passed on as is. next must begin its transactions with a
+// sqltx.Beginner for its changes to be stored in the same transaction as the key.
+func (m *Middleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(HeaderKey)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxKeyLength {
+			writeProblem(w, r, http.StatusBadRequest, fmt.Sprintf("%s must not be longer than %d characters", HeaderKey, maxKeyLength))
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, m.maxBodyBytes)
+		fingerprint, err := fingerprintOf(r)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeProblem(w, r, http.StatusBadRequest, fmt.Sprintf("request body must not be larger than %d bytes", m.maxBodyBytes))
+			return
+		}
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, "could not read request body")
+			return
+		}
+
+		m.serve(w, r, next, key, fingerprint)
+	})
+}
+
+func (m *Middleware) serve(w http.ResponseWriter, r *http.Request, next http.Handler, key, fingerprint string) {
+	ctx, shared, err := sqltx.Begin(r.Context(), m.db)
+	if err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, "")
+		return
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			shared.Rollback()
+		}
+	}()
+
+	// replay the stored response if the key has been used before
+	stored, found, err := findResponse(ctx, shared, key)
+	if err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, "")
+		return
+	}
+
+	if found {
+		if stored.fingerprint != fingerprint {
+			writeProblem(w, r, http.StatusUnprocessableEntity, fmt.Sprintf("%s has already been used for a different request", HeaderKey))
+			return
+		}
+
+		w.Header().Set(HeaderReplayed, "true")
+		writeResponse(w, stored)
+		return
+	}
+
+	// handle the request in the shared transaction and record its response
+	rec := newRecorder()
+	next.ServeHTTP(rec, r.WithContext(ctx))
+	resp := rec.response(fingerprint)
+
+	// only responses of requests that took effect are stored. Failed requests may be retried.
+	if shared.RollbackOnly() || resp.status >= http.StatusInternalServerError {
+		writeResponse(w, resp)
+		return
+	}
+
+	if err = m.insertResponse(ctx, shared, key, resp); err != nil {
+		if sqltx.IsUniqueViolation(err) {
+			// a concurrent request with the same key got there first
+			writeProblem(w, r, http.StatusConflict, fmt.Sprintf("a request with the same %s is being processed", HeaderKey))
+			return
+		}
+
+		writeProblem(w, r, http.StatusInternalServerError, "")
+		return
+	}
+
+	if err = shared.Commit(); err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, "")
+		return
+	}
+	committed = true
+
+	writeResponse(w, resp)
+}
+
+// fingerprintOf hashes the parts of the request that identify it. The body is read and
+// replaced so that it can be read again by the next handler.
+func fingerprintOf(r *http.Request) (string, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func findResponse(ctx context.Context, tx sqltx.Tx, key string) (storedResponse, bool, error) {
+	var (
+		stored storedResponse
+		header string
+		body   string
+	)
+
+	err := tx.QueryRowContext(ctx, selectKey, key).Scan(&stored.fingerprint, &stored.status, &header, &body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storedResponse{}, false, nil
+	}
+	if err != nil {
+		return storedResponse{}, false, fmt.Errorf("could not find idempotency key: %w", err)
+	}
+	if err = json.Unmarshal([]byte(header), &stored.header); err != nil {
+		return storedResponse{}, false, fmt.Errorf("could not decode headers of idempotency key: %w", err)
+	}
+	stored.body = []byte(body)
+
+	return stored, true, nil
+}
+
+func (m *Middleware) insertResponse(ctx context.Context, tx sqltx.Tx, key string, resp storedResponse) error {
+	header, err := json.Marshal(resp.header)
+	if err != nil {
+		return fmt.Errorf("could not encode headers of idempotency key: %w", err)
+	}
+
+	createdAt := m.now().UTC().Format(time.RFC3339Nano)
+	_, err = tx.ExecContext(ctx, insertKey, key, resp.fingerprint, resp.status, string(header), string(resp.body), createdAt)
+	if err != nil {
+		return fmt.Errorf("could not insert idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// recorder is an http.ResponseWriter that buffers the response so it can be stored before
+// it's written. The status, headers and body are kept.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: make(http.Header)}
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+
+	return rec.body.Write(b)
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *recorder) response(fingerprint string) storedResponse {
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	return storedResponse{
+		fingerprint: fingerprint,
+		status:      status,
+		header:      rec.header.Clone(),
+		body:        rec.body.Bytes(),
+	}
+}
+
+func writeResponse(w http.ResponseWriter, resp storedResponse) {
+	for name, values := range resp.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.status)
+	w.Write(resp.body)
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	apperror.NewStatusProblem(r, status, detail).Write(w)
+}
@@ -0,0 +1,214 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/migrations"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/sqltx"
+	transactionscript "github.com/tobbstr-examples/business-logic-patterns/business-logic/transaction-script"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	resourceID      = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+	otherResourceID = "0f8fad5b-d9cb-469f-a165-70867728950e"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "idempotency.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	runner, err := migrations.NewRunner(db, migrations.SQLite)
+	require.NoError(t, err)
+	require.NoError(t, runner.Up(context.Background()))
+
+	return db
+}
+
+func count(t *testing.T, db *sql.DB, table string) int {
+	t.Helper()
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM "+table+";").Scan(&n))
+
+	return n
+}
+
+func createResource(handler http.Handler, key, id string) *httptest.ResponseRecorder {
+	body := `{"id":"` + id + `","weight":70}`
+	req := httptest.NewRequest(http.MethodPost, "/resources", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(HeaderKey, key)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestMiddleware_Wrap(t *testing.T) {
+	// Given
+	require := require.New(t)
+	db := openTestDB(t)
+	controller := transactionscript.NewController(sqltx.NewBeginner(db))
+	handler := NewMiddleware(db).Wrap(http.HandlerFunc(controller.CreateResource))
+
+	// When the request is first made
+	first := createResource(handler, "key-1", resourceID)
+
+	// Then the resource is created and the response is stored with the key
+	require.Equal(http.StatusCreated, first.Code)
+	require.Empty(first.Header().Get(HeaderReplayed))
+	require.Equal(1, count(t, db, "example_tbl"))
+	require.Equal(1, count(t, db, "idempotency_keys"))
+
+	// When the request is retried
+	retry := createResource(handler, "key-1", resourceID)
+
+	// Then the stored response is replayed without creating the resource again
+	require.Equal(http.StatusCreated, retry.Code)
+	require.Equal("true", retry.Header().Get(HeaderReplayed))
+	require.Equal("application/json", retry.Header().Get("Content-Type"))
+	require.JSONEq(first.Body.String(), retry.Body.String())
+	require.Equal(1, count(t, db, "example_tbl"))
+
+	// When the key is reused for a different request
+	reused := createResource(handler, "key-1", otherResourceID)
+
+	// Then the request is rejected
+	require.Equal(http.StatusUnprocessableEntity, reused.Code)
+	require.Equal(1, count(t, db, "example_tbl"))
+
+	// When a request that is rolled back is made with a new key
+	conflict := createResource(handler, "key-2", resourceID)
+
+	// Then neither the key nor any changes are stored
+	require.Equal(http.StatusConflict, conflict.Code)
+	require.Equal(1, count(t, db, "idempotency_keys"))
+
+	// When a request is made without a key
+	withoutKey := createResource(handler, "", otherResourceID)
+
+	// Then it's handled as usual
+	require.Equal(http.StatusCreated, withoutKey.Code)
+	require.Equal(2, count(t, db, "example_tbl"))
+	require.Equal(1, count(t, db, "idempotency_keys"))
+}
+
+func TestMiddleware_Wrap_ReplaysHeaders(t *testing.T) {
+	// Given a handler that sets headers besides the content type
+	require := require.New(t)
+	db := openTestDB(t)
+	calls := 0
+	handler := NewMiddleware(db).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Location", "/resources/"+resourceID)
+		w.Header().Add("Link", "</resources>; rel=\"collection\"")
+		w.Header().Add("Link", "</resources/"+resourceID+"/history>; rel=\"history\"")
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	// When the request is first made
+	first := createResource(handler, "key-1", resourceID)
+
+	// Then the headers are passed on
+	require.Equal(http.StatusCreated, first.Code)
+	require.Equal("/resources/"+resourceID, first.Header().Get("Location"))
+	require.Len(first.Header().Values("Link"), 2)
+
+	// When the request is retried
+	retry := createResource(handler, "key-1", resourceID)
+
+	// Then the headers are replayed
+	require.Equal(1, calls)
+	require.Equal(http.StatusCreated, retry.Code)
+	require.Equal("true", retry.Header().Get(HeaderReplayed))
+	require.Equal("/resources/"+resourceID, retry.Header().Get("Location"))
+	require.Equal(first.Header().Values("Link"), retry.Header().Values("Link"))
+}
+
+func TestMiddleware_Wrap_RejectsLongKeys(t *testing.T) {
+	db := openTestDB(t)
+	handler := NewMiddleware(db).Wrap(http.NotFoundHandler())
+
+	rec := createResource(handler, strings.Repeat("k", maxKeyLength+1), resourceID)
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestMiddleware_Wrap_RejectsLargeBodies(t *testing.T) {
+	// Given
+	require := require.New(t)
+	db := openTestDB(t)
+	called := false
+	handler := NewMiddleware(db, WithMaxBodyBytes(16)).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	// When
+	rec := createResource(handler, "key-1", resourceID)
+
+	// Then the body isn't read past the limit and the request isn't handled
+	require.Equal(http.StatusBadRequest, rec.Code)
+	require.Contains(rec.Body.String(), "must not be larger than 16 bytes")
+	require.False(called)
+	require.Equal(0, count(t, db, "idempotency_keys"))
+}
+
+func TestMiddleware_Wrap_KeyStoreFailures(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(t *testing.T, db *sql.DB)
+		wantStatus int
+	}{
+		{
+			name: "should return conflict when a concurrent request stored the key first",
+			setup: func(t *testing.T, db *sql.DB) {
+				// the key is stored once the request has been handled, as if by a concurrent request
+				_, err := db.Exec(`CREATE TRIGGER concurrent_request AFTER INSERT ON example_tbl BEGIN
+					INSERT INTO idempotency_keys (idempotency_key, fingerprint, status, headers, body, created_at)
+					VALUES ('key-1', 'other', 201, '{}', '', '');
+				END;`)
+				require.NoError(t, err)
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "should return internal server error when the key can't be stored",
+			setup: func(t *testing.T, db *sql.DB) {
+				_, err := db.Exec(`CREATE TRIGGER failing_store BEFORE INSERT ON idempotency_keys BEGIN
+					SELECT RAISE(ABORT, 'disk full');
+				END;`)
+				require.NoError(t, err)
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			db := openTestDB(t)
+			tt.setup(t, db)
+			controller := transactionscript.NewController(sqltx.NewBeginner(db))
+			handler := NewMiddleware(db).Wrap(http.HandlerFunc(controller.CreateResource))
+
+			// When
+			rec := createResource(handler, "key-1", resourceID)
+
+			// Then nothing is stored
+			require.Equal(tt.wantStatus, rec.Code)
+			require.Equal(0, count(t, db, "example_tbl"))
+			require.Equal(0, count(t, db, "idempotency_keys"))
+		})
+	}
+}
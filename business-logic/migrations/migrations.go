@@ -0,0 +1,400 @@
+/*
+Package migrations holds the versioned database schema used by the pattern examples together
+with a runner that applies it. Migrations are embedded SQL files named <version>_<name>.up.sql
+and <version>_<name>.down.sql, and every migration is applied in its own transaction.
+*/
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+const (
+	createVersionTable = "CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at TEXT NOT NULL);"
+	createLockTable    = "CREATE TABLE IF NOT EXISTS schema_migrations_lock (id INTEGER PRIMARY KEY, locked_at TEXT NOT NULL);"
+	selectVersions     = "SELECT version, applied_at FROM schema_migrations ORDER BY version;"
+	insertVersion      = "INSERT INTO schema_migrations (version, name, applied_at) VALUES (%s, %s, %s);"
+	deleteVersion      = "DELETE FROM schema_migrations WHERE version = %s;"
+	insertLock         = "INSERT INTO schema_migrations_lock (id, locked_at) VALUES (1, %s);"
+	selectLock         = "SELECT locked_at FROM schema_migrations_lock WHERE id = 1;"
+	deleteLock         = "DELETE FROM schema_migrations_lock WHERE id = 1;"
+)
+
+// ErrLocked is returned when another runner holds the migration lock
+var ErrLocked = errors.New("migrations are locked by another runner")
+
+// Dialect captures the differences between the supported databases
+type Dialect struct {
+	Name        string
+	placeholder func(n int) string
+}
+
+var (
+	SQLite   = Dialect{Name: "sqlite", placeholder: func(int) string { return "?" }}
+	Postgres = Dialect{Name: "postgres", placeholder: func(n int) string { return "$" + strconv.Itoa(n) }}
+)
+
+// DialectByName returns the dialect with the given name
+func DialectByName(name string) (Dialect, error) {
+	switch name {
+	case SQLite.Name:
+		return SQLite, nil
+	case Postgres.Name:
+		return Postgres, nil
+	default:
+		return Dialect{}, fmt.Errorf("unsupported dialect = %s", name)
+	}
+}
+
+// query replaces the %s verbs in format with the dialect's bind parameter placeholders
+func (d Dialect) query(format string, numArgs int) string {
+	placeholders := make([]any, 0, numArgs)
+	for i := 1; i <= numArgs; i++ {
+		placeholders = append(placeholders, d.placeholder(i))
+	}
+
+	return fmt.Sprintf(format, placeholders...)
+}
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a migration has been applied
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Load reads the migrations found in dir of fsys and returns them ordered by version
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read migrations dir = %s: %w", dir, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		version, name, direction, err := parseFileName(fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, fileName))
+		if err != nil {
+			return nil, fmt.Errorf("could not read migration = %s: %w", fileName, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration version = %d has conflicting names %s and %s", version, m.Name, name)
+		}
+
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration version = %d must have both an up and a down file", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// parseFileName splits a file name such as 0001_create_human.up.sql into its parts
+func parseFileName(fileName string) (version int, name, direction string, err error) {
+	base := strings.TrimSuffix(fileName, ".sql")
+	switch {
+	case strings.HasSuffix(base, ".up"):
+		direction = "up"
+	case strings.HasSuffix(base, ".down"):
+		direction = "down"
+	default:
+		return 0, "", "", fmt.Errorf("migration = %s must end with .up.sql or .down.sql", fileName)
+	}
+	base = strings.TrimSuffix(base, "."+direction)
+
+	rawVersion, name, found := strings.Cut(base, "_")
+	if !found {
+		return 0, "", "", fmt.Errorf("migration = %s must be named <version>_<name>", fileName)
+	}
+	version, err = strconv.Atoi(rawVersion)
+	if err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("migration = %s has an invalid version", fileName)
+	}
+
+	return version, name, direction, nil
+}
+
+// Runner applies migrations to a database
+type Runner struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+	now        func() time.Time
+}
+
+// NewRunner returns a Runner for the migrations embedded in this package
+func NewRunner(db *sql.DB, dialect Dialect) (*Runner, error) {
+	migrations, err := Load(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRunnerWithMigrations(db, dialect, migrations), nil
+}
+
+// NewRunnerWithMigrations returns a Runner for the given migrations
+func NewRunnerWithMigrations(db *sql.DB, dialect Dialect, migrations []Migration) *Runner {
+	return &Runner{
+		db:         db,
+		dialect:    dialect,
+		migrations: migrations,
+		now:        time.Now,
+	}
+}
+
+// Up applies all pending migrations
+func (r *Runner) Up(ctx context.Context) error {
+	if len(r.migrations) == 0 {
+		return nil
+	}
+
+	return r.To(ctx, r.migrations[len(r.migrations)-1].Version)
+}
+
+// Down reverts the most recently applied migration
+func (r *Runner) Down(ctx context.Context) error {
+	return r.locked(ctx, func(applied map[int]time.Time) error {
+		for i := len(r.migrations) - 1; i >= 0; i-- {
+			if _, ok := applied[r.migrations[i].Version]; ok {
+				return r.revert(ctx, r.migrations[i])
+			}
+		}
+
+		return nil
+	})
+}
+
+// To applies or reverts migrations until the schema is at the given version. Version 0 reverts
+// every migration.
+func (r *Runner) To(ctx context.Context, version int) error {
+	if version != 0 && r.find(version) == nil {
+		return fmt.Errorf("unknown migration version = %d", version)
+	}
+
+	return r.locked(ctx, func(applied map[int]time.Time) error {
+		// revert everything above the target version, newest first
+		for i := len(r.migrations) - 1; i >= 0; i-- {
+			m := r.migrations[i]
+			if _, ok := applied[m.Version]; ok && m.Version > version {
+				if err := r.revert(ctx, m); err != nil {
+					return err
+				}
+			}
+		}
+
+		// apply everything up to and including the target version, oldest first
+		for _, m := range r.migrations {
+			if _, ok := applied[m.Version]; !ok && m.Version <= version {
+				if err := r.apply(ctx, m); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// Status lists every known migration and whether it has been applied
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	if err := r.createTables(ctx); err != nil {
+		return nil, err
+	}
+
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(r.migrations))
+	for _, m := range r.migrations {
+		appliedAt, ok := applied[m.Version]
+		statuses = append(statuses, Status{Migration: m, Applied: ok, AppliedAt: appliedAt})
+	}
+
+	return statuses, nil
+}
+
+// locked runs fn while holding the migration lock
+func (r *Runner) locked(ctx context.Context, fn func(applied map[int]time.Time) error) (err error) {
+	if err = r.createTables(ctx); err != nil {
+		return err
+	}
+
+	if err = r.lock(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if _, unlockErr := r.db.ExecContext(ctx, deleteLock); unlockErr != nil && err == nil {
+			err = fmt.Errorf("could not release migration lock: %w", unlockErr)
+		}
+	}()
+
+	applied, err := r.applied(ctx)
+	if err != nil {
+		return err
+	}
+
+	for version := range applied {
+		if r.find(version) == nil {
+			return fmt.Errorf("database has unknown migration version = %d applied", version)
+		}
+	}
+
+	return fn(applied)
+}
+
+// ForceUnlock releases the migration lock, whoever holds it. It's meant for locks left behind by
+// runners that stopped before releasing them and must not be used while a runner is migrating.
+func (r *Runner) ForceUnlock(ctx context.Context) error {
+	if err := r.createTables(ctx); err != nil {
+		return err
+	}
+
+	if _, err := r.db.ExecContext(ctx, deleteLock); err != nil {
+		return fmt.Errorf("could not release migration lock: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Runner) createTables(ctx context.Context) error {
+	for _, query := range []string{createVersionTable, createLockTable} {
+		if _, err := r.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("could not create migration bookkeeping tables: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Runner) lock(ctx context.Context) error {
+	lockedAt := r.now().UTC().Format(time.RFC3339)
+	_, insertErr := r.db.ExecContext(ctx, r.dialect.query(insertLock, 1), lockedAt)
+	if insertErr == nil {
+		return nil
+	}
+
+	// tell a held lock apart from any other failure
+	var heldSince string
+	if err := r.db.QueryRowContext(ctx, selectLock).Scan(&heldSince); err == nil {
+		return fmt.Errorf("%w since %s", ErrLocked, heldSince)
+	}
+
+	return fmt.Errorf("could not acquire migration lock: %w", insertErr)
+}
+
+func (r *Runner) applied(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := r.db.QueryContext(ctx, selectVersions)
+	if err != nil {
+		return nil, fmt.Errorf("could not read applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt string
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("could not scan applied migration: %w", err)
+		}
+
+		applied[version], err = time.Parse(time.RFC3339, appliedAt)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse applied_at of migration version = %d: %w", version, err)
+		}
+	}
+
+	return applied, rows.Err()
+}
+
+func (r *Runner) apply(ctx context.Context, m Migration) error {
+	appliedAt := r.now().UTC().Format(time.RFC3339)
+	return r.inTx(ctx, m.Up, r.dialect.query(insertVersion, 3), m.Version, m.Name, appliedAt)
+}
+
+func (r *Runner) revert(ctx context.Context, m Migration) error {
+	return r.inTx(ctx, m.Down, r.dialect.query(deleteVersion, 1), m.Version)
+}
+
+// inTx runs the migration script and the bookkeeping query in the same transaction
+func (r *Runner) inTx(ctx context.Context, script, bookkeeping string, args ...any) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("could not run migration version = %v: %w", args[0], err)
+	}
+
+	if _, err = tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return fmt.Errorf("could not record migration version = %v: %w", args[0], err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit migration version = %v: %w", args[0], err)
+	}
+
+	return nil
+}
+
+func (r *Runner) find(version int) *Migration {
+	for i := range r.migrations {
+		if r.migrations[i].Version == version {
+			return &r.migrations[i]
+		}
+	}
+
+	return nil
+}
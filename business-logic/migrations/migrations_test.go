@@ -0,0 +1,190 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "migrations.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func appliedVersions(t *testing.T, runner *Runner) []int {
+	t.Helper()
+	statuses, err := runner.Status(context.Background())
+	require.NoError(t, err)
+
+	var versions []int
+	for _, s := range statuses {
+		if s.Applied {
+			versions = append(versions, s.Version)
+		}
+	}
+
+	return versions
+}
+
+func TestRunner(t *testing.T) {
+	// Given
+	require := require.New(t)
+	ctx := context.Background()
+	db := openTestDB(t)
+	runner, err := NewRunner(db, SQLite)
+	require.NoError(err)
+	latest := runner.migrations[len(runner.migrations)-1].Version
+
+	// When all migrations are applied
+	require.NoError(runner.Up(ctx))
+
+	// Then
+	require.Len(appliedVersions(t, runner), len(runner.migrations))
+	_, err = db.Exec("INSERT INTO human (id, name, weight, height) VALUES ('1', 'Jane', 70, 170);")
+	require.NoError(err)
+
+	// When running up again
+	require.NoError(runner.Up(ctx))
+
+	// Then nothing changes
+	require.Len(appliedVersions(t, runner), len(runner.migrations))
+
+	// When reverting a single migration
+	require.NoError(runner.Down(ctx))
+
+	// Then the latest is no longer applied
+	require.NotContains(appliedVersions(t, runner), latest)
+
+	// When migrating to a specific version
+	require.NoError(runner.To(ctx, 1))
+
+	// Then only migrations up to it are applied
+	require.Equal([]int{1}, appliedVersions(t, runner))
+
+	// When migrating to version 0
+	require.NoError(runner.To(ctx, 0))
+
+	// Then everything has been reverted
+	require.Empty(appliedVersions(t, runner))
+	_, err = db.Exec("SELECT 1 FROM human;")
+	require.Error(err)
+}
+
+func TestRunner_Locked(t *testing.T) {
+	// Given
+	require := require.New(t)
+	ctx := context.Background()
+	db := openTestDB(t)
+	runner, err := NewRunner(db, SQLite)
+	require.NoError(err)
+	require.NoError(runner.createTables(ctx))
+	require.NoError(runner.lock(ctx))
+
+	// When
+	err = runner.Up(ctx)
+
+	// Then
+	require.True(errors.Is(err, ErrLocked))
+	require.Empty(appliedVersions(t, runner))
+
+	// When the lock is left behind by a runner that stopped, and it's released by force
+	require.NoError(runner.ForceUnlock(ctx))
+	err = runner.Up(ctx)
+
+	// Then the migrations are applied
+	require.NoError(err)
+	require.NotEmpty(appliedVersions(t, runner))
+}
+
+func TestRunner_To_UnknownVersion(t *testing.T) {
+	require := require.New(t)
+	runner, err := NewRunner(openTestDB(t), SQLite)
+	require.NoError(err)
+
+	require.Error(runner.To(context.Background(), 9999))
+}
+
+func TestLoad_EmbeddedMigrations(t *testing.T) {
+	require := require.New(t)
+
+	migrations, err := Load(files, "sql")
+
+	require.NoError(err)
+	for i, m := range migrations {
+		require.Equal(i+1, m.Version, "migration versions must be contiguous")
+		require.NotEmpty(m.Up)
+		require.NotEmpty(m.Down)
+	}
+}
+
+func TestDataMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int      // the version of the migration that migrates the data
+		setup   []string // statements writing the data before the migration is applied
+		query   string
+		want    []string
+	}{
+		{
+			name:    "should continue the event sequence after the last position",
+			version: 15,
+			setup: []string{
+				"INSERT INTO order_events VALUES (1, 'e1', 'o1', 1, 'OrderCreated', '', '{}');",
+				"INSERT INTO order_events VALUES (2, 'e2', 'o1', 2, 'OrderSubmitted', '', '{}');",
+			},
+			query: "SELECT position FROM order_event_sequence;",
+			want:  []string{"2"},
+		},
+		{
+			name:    "should project the daily submitted totals again",
+			version: 16,
+			setup: []string{
+				"INSERT INTO daily_submitted_totals VALUES ('2022-08-01', 1, 2);",
+				"INSERT INTO projection_checkpoints VALUES ('daily-submitted-totals', 42);",
+				"INSERT INTO projection_checkpoints VALUES ('order-summaries', 42);",
+			},
+			query: "SELECT name FROM projection_checkpoints UNION ALL SELECT day FROM daily_submitted_totals;",
+			want:  []string{"order-summaries"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given data written before the migration
+			require := require.New(t)
+			ctx := context.Background()
+			db := openTestDB(t)
+			runner, err := NewRunner(db, SQLite)
+			require.NoError(err)
+			require.NoError(runner.To(ctx, tt.version-1))
+			for _, stmt := range tt.setup {
+				_, err = db.ExecContext(ctx, stmt)
+				require.NoError(err)
+			}
+
+			// When
+			require.NoError(runner.To(ctx, tt.version))
+
+			// Then
+			rows, err := db.QueryContext(ctx, tt.query)
+			require.NoError(err)
+			defer rows.Close()
+			var got []string
+			for rows.Next() {
+				var value string
+				require.NoError(rows.Scan(&value))
+				got = append(got, value)
+			}
+			require.NoError(rows.Err())
+			require.Equal(tt.want, got)
+		})
+	}
+}
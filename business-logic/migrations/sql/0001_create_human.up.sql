@@ -0,0 +1,6 @@
+CREATE TABLE human (
+    id     TEXT PRIMARY KEY,
+    name   TEXT    NOT NULL,
+    weight INTEGER NOT NULL,
+    height INTEGER NOT NULL
+);
@@ -0,0 +1 @@
+DROP TABLE human_audit;
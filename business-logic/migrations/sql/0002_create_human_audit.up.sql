@@ -0,0 +1,13 @@
+-- changed_at is in UTC with a fixed number of fraction digits, so the entries sort in the order
+-- they were made
+CREATE TABLE human_audit (
+    id         TEXT PRIMARY KEY,
+    record_id  TEXT NOT NULL,
+    operation  TEXT NOT NULL,
+    actor      TEXT NOT NULL,
+    changed_at TEXT NOT NULL,
+    old_values TEXT NOT NULL,
+    new_values TEXT NOT NULL
+);
+
+CREATE INDEX human_audit_record_id_idx ON human_audit (record_id, changed_at);
@@ -0,0 +1 @@
+DROP TABLE example_tbl;
@@ -0,0 +1,4 @@
+CREATE TABLE example_tbl (
+    id     TEXT PRIMARY KEY,
+    weight INTEGER NOT NULL
+);
@@ -0,0 +1,8 @@
+CREATE TABLE orders (
+    id          TEXT PRIMARY KEY,
+    customer_id TEXT    NOT NULL,
+    order_items TEXT    NOT NULL,
+    state       INTEGER NOT NULL
+);
+
+CREATE INDEX orders_customer_id_idx ON orders (customer_id);
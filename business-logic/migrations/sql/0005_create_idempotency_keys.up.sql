@@ -0,0 +1,8 @@
+CREATE TABLE idempotency_keys (
+    idempotency_key TEXT PRIMARY KEY,
+    fingerprint     TEXT    NOT NULL,
+    status          INTEGER NOT NULL,
+    headers         TEXT    NOT NULL,
+    body            TEXT    NOT NULL,
+    created_at      TEXT    NOT NULL
+);
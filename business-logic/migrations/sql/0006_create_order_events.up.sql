@@ -0,0 +1,10 @@
+CREATE TABLE order_events (
+    position          BIGINT  PRIMARY KEY,
+    event_id          TEXT    NOT NULL UNIQUE,
+    aggregate_id      TEXT    NOT NULL,
+    aggregate_version INTEGER NOT NULL,
+    event_type        TEXT    NOT NULL,
+    occurred_at       TEXT    NOT NULL,
+    data              TEXT    NOT NULL,
+    UNIQUE (aggregate_id, aggregate_version)
+);
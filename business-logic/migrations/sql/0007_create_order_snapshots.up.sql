@@ -0,0 +1,8 @@
+CREATE TABLE order_snapshots (
+    aggregate_id      TEXT    NOT NULL,
+    aggregate_version INTEGER NOT NULL,
+    schema_version    INTEGER NOT NULL,
+    data              TEXT    NOT NULL,
+    created_at        TEXT    NOT NULL,
+    PRIMARY KEY (aggregate_id, aggregate_version)
+);
@@ -0,0 +1,4 @@
+DROP TABLE daily_submitted_totals;
+DROP TABLE order_state_counts;
+DROP TABLE order_summaries;
+DROP TABLE projection_checkpoints;
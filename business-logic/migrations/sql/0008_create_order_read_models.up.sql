@@ -0,0 +1,26 @@
+CREATE TABLE projection_checkpoints (
+    name     TEXT PRIMARY KEY,
+    position BIGINT NOT NULL
+);
+
+CREATE TABLE order_summaries (
+    order_id    TEXT PRIMARY KEY,
+    customer_id TEXT    NOT NULL,
+    state       TEXT    NOT NULL,
+    item_count  INTEGER NOT NULL,
+    created_at  TEXT    NOT NULL,
+    updated_at  TEXT    NOT NULL
+);
+
+CREATE INDEX order_summaries_customer_id_idx ON order_summaries (customer_id, created_at);
+
+CREATE TABLE order_state_counts (
+    state       TEXT PRIMARY KEY,
+    order_count INTEGER NOT NULL
+);
+
+CREATE TABLE daily_submitted_totals (
+    day         TEXT PRIMARY KEY,
+    order_count INTEGER NOT NULL,
+    item_count  INTEGER NOT NULL
+);
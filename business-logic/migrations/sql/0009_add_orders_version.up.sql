@@ -0,0 +1,2 @@
+-- orders are at version 1 when they're created
+ALTER TABLE orders ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,5 @@
+ALTER TABLE order_summaries DROP COLUMN total;
+ALTER TABLE order_summaries DROP COLUMN currency;
+ALTER TABLE orders DROP COLUMN total;
+ALTER TABLE orders DROP COLUMN subtotal;
+ALTER TABLE orders DROP COLUMN currency;
@@ -0,0 +1,5 @@
+ALTER TABLE orders ADD COLUMN currency TEXT NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN subtotal BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN total BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE order_summaries ADD COLUMN currency TEXT NOT NULL DEFAULT '';
+ALTER TABLE order_summaries ADD COLUMN total BIGINT NOT NULL DEFAULT 0;
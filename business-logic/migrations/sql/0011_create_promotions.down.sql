@@ -0,0 +1,2 @@
+DROP TABLE promotion_usages;
+ALTER TABLE orders DROP COLUMN pricing;
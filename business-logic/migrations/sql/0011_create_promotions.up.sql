@@ -0,0 +1,11 @@
+ALTER TABLE orders ADD COLUMN pricing TEXT NOT NULL DEFAULT '{}';
+
+CREATE TABLE promotion_usages (
+    promotion_id TEXT NOT NULL,
+    order_id     TEXT NOT NULL,
+    customer_id  TEXT NOT NULL,
+    used_at      TEXT NOT NULL,
+    PRIMARY KEY (promotion_id, order_id)
+);
+
+CREATE INDEX promotion_usages_customer_id_idx ON promotion_usages (promotion_id, customer_id);
@@ -0,0 +1,11 @@
+CREATE TABLE customers (
+    id           TEXT PRIMARY KEY,
+    name         TEXT    NOT NULL,
+    status       INTEGER NOT NULL,
+    blocked      BOOLEAN NOT NULL DEFAULT FALSE,
+    currency     TEXT    NOT NULL,
+    credit_limit BIGINT  NOT NULL,
+    credit_used  BIGINT  NOT NULL,
+    addresses    TEXT    NOT NULL DEFAULT '[]',
+    version      INTEGER NOT NULL
+);
@@ -0,0 +1,14 @@
+CREATE TABLE stock (
+    article_no TEXT PRIMARY KEY,
+    on_hand    INTEGER NOT NULL,
+    reserved   INTEGER NOT NULL,
+    version    INTEGER NOT NULL
+);
+
+CREATE TABLE stock_reservations (
+    order_id   TEXT PRIMARY KEY,
+    status     TEXT NOT NULL,
+    items      TEXT NOT NULL,
+    reason     TEXT NOT NULL DEFAULT '',
+    updated_at TEXT NOT NULL
+);
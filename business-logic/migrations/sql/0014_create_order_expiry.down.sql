@@ -0,0 +1,3 @@
+DROP TABLE scheduler_leases;
+DROP INDEX orders_state_created_at_idx;
+ALTER TABLE orders DROP COLUMN created_at;
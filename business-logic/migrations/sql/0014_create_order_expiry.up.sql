@@ -0,0 +1,10 @@
+-- created_at is in UTC with a fixed number of fraction digits, so it can be compared as text
+ALTER TABLE orders ADD COLUMN created_at TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX orders_state_created_at_idx ON orders (state, created_at);
+
+CREATE TABLE scheduler_leases (
+    name       TEXT PRIMARY KEY,
+    owner      TEXT NOT NULL,
+    expires_at TEXT NOT NULL
+);
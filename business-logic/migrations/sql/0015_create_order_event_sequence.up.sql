@@ -0,0 +1,6 @@
+CREATE TABLE order_event_sequence (
+    id       INTEGER PRIMARY KEY,
+    position BIGINT  NOT NULL
+);
+
+INSERT INTO order_event_sequence (id, position) SELECT 1, COALESCE(MAX(position), 0) FROM order_events;
@@ -0,0 +1 @@
+DROP TABLE daily_submitted_currency_totals;
@@ -0,0 +1,10 @@
+CREATE TABLE daily_submitted_currency_totals (
+    day      TEXT   NOT NULL,
+    currency TEXT   NOT NULL,
+    total    BIGINT NOT NULL,
+    PRIMARY KEY (day, currency)
+);
+
+-- the daily totals are projected again from scratch to sum up the totals of the past days
+DELETE FROM daily_submitted_totals;
+DELETE FROM projection_checkpoints WHERE name = 'daily-submitted-totals';
@@ -0,0 +1,5 @@
+DROP INDEX promotion_usages_customer_slot_idx;
+DROP INDEX promotion_usages_slot_idx;
+
+ALTER TABLE promotion_usages DROP COLUMN customer_slot;
+ALTER TABLE promotion_usages DROP COLUMN slot;
@@ -0,0 +1,7 @@
+-- every usage of a promotion with a usage limit takes a numbered slot, so concurrent redemptions
+-- of the same slot can't both be recorded
+ALTER TABLE promotion_usages ADD COLUMN slot INTEGER;
+ALTER TABLE promotion_usages ADD COLUMN customer_slot INTEGER;
+
+CREATE UNIQUE INDEX promotion_usages_slot_idx ON promotion_usages (promotion_id, slot);
+CREATE UNIQUE INDEX promotion_usages_customer_slot_idx ON promotion_usages (promotion_id, customer_id, customer_slot);
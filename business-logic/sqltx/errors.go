@@ -0,0 +1,27 @@
+package sqltx
+
+import "errors"
+
+const (
+	sqliteConstraintPrimaryKey = 1555 // SQLITE_CONSTRAINT_PRIMARYKEY
+	sqliteConstraintUnique     = 2067 // SQLITE_CONSTRAINT_UNIQUE
+	postgresUniqueViolation    = "23505"
+)
+
+// IsUniqueViolation reports whether err was caused by a row violating a primary key or unique
+// constraint. The errors of the sqlite and Postgres drivers are recognized without depending on
+// them.
+func IsUniqueViolation(err error) bool {
+	var sqliteErr interface{ Code() int }
+	if errors.As(err, &sqliteErr) {
+		code := sqliteErr.Code()
+		return code == sqliteConstraintPrimaryKey || code == sqliteConstraintUnique
+	}
+
+	var postgresErr interface{ SQLState() string }
+	if errors.As(err, &postgresErr) {
+		return postgresErr.SQLState() == postgresUniqueViolation
+	}
+
+	return false
+}
@@ -0,0 +1,136 @@
+/*
+Package sqltx lets code that manages its own database transactions take part in a transaction
+started further up the call chain. A caller starts a shared transaction with Begin and passes the
+returned context on. Transactions begun with a Beginner on that context join the shared
+transaction instead of starting a new one, so their Commit is a no-op and their Rollback marks
+the shared transaction as rollback-only. The caller that started the shared transaction decides
+whether it's committed. Work that must wait until then, like publishing events, can be registered
+with the AfterCommit method of the joining transactions.
+*/
+package sqltx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrRollbackOnly is returned when joining a shared transaction that a transaction that joined it
+// before rolled back
+var ErrRollbackOnly = errors.New("shared transaction is rollback-only")
+
+// Tx is the behaviour shared by *sql.Tx and transactions joining a shared transaction
+type Tx interface {
+	Commit() error
+	Exec(query string, args ...any) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	Rollback() error
+}
+
+type sharedCtxKey struct{}
+
+// Shared is a transaction that transactions begun on its context join
+type Shared struct {
+	*sql.Tx
+	rollbackOnly bool
+	afterCommit  []func()
+}
+
+// Begin starts a shared transaction and returns a copy of ctx carrying it
+func Begin(ctx context.Context, db *sql.DB) (context.Context, *Shared, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return ctx, nil, fmt.Errorf("could not begin shared transaction: %w", err)
+	}
+
+	shared := &Shared{Tx: tx}
+	return context.WithValue(ctx, sharedCtxKey{}, shared), shared, nil
+}
+
+// InShared reports whether ctx carries a shared transaction, which transactions begun on ctx join
+func InShared(ctx context.Context) bool {
+	_, ok := ctx.Value(sharedCtxKey{}).(*Shared)
+	return ok
+}
+
+// RollbackOnly reports whether a transaction that joined s was rolled back, in which case s
+// must not be committed
+func (s *Shared) RollbackOnly() bool {
+	return s.rollbackOnly
+}
+
+// Commit commits the shared transaction, then runs the functions the transactions that joined it
+// registered with AfterCommit
+func (s *Shared) Commit() error {
+	if err := s.Tx.Commit(); err != nil {
+		return err
+	}
+
+	afterCommit := s.afterCommit
+	s.afterCommit = nil
+	for _, fn := range afterCommit {
+		fn()
+	}
+
+	return nil
+}
+
+// Beginner begins transactions that join the shared transaction of the context, if any
+type Beginner struct {
+	db *sql.DB
+}
+
+func NewBeginner(db *sql.DB) *Beginner {
+	return &Beginner{db: db}
+}
+
+// BeginTx joins the shared transaction of ctx or begins a new transaction if there is none. A
+// shared transaction that is rollback-only can't be joined, since nothing done in it will last.
+func (b *Beginner) BeginTx(ctx context.Context, opts *sql.TxOptions) (Tx, error) {
+	if shared, ok := ctx.Value(sharedCtxKey{}).(*Shared); ok {
+		if shared.rollbackOnly {
+			return nil, ErrRollbackOnly
+		}
+		return &joined{Tx: shared.Tx, shared: shared}, nil
+	}
+
+	return b.db.BeginTx(ctx, opts)
+}
+
+// joined is a transaction that takes part in a shared transaction
+type joined struct {
+	*sql.Tx
+	shared *Shared
+	done   bool
+}
+
+// Commit leaves committing to whoever started the shared transaction
+func (j *joined) Commit() error {
+	if j.done {
+		return sql.ErrTxDone
+	}
+	j.done = true
+
+	return nil
+}
+
+// AfterCommit makes fn run once the shared transaction is committed. It never runs if the shared
+// transaction is rolled back.
+func (j *joined) AfterCommit(fn func()) {
+	j.shared.afterCommit = append(j.shared.afterCommit, fn)
+}
+
+// Rollback marks the shared transaction as rollback-only
+func (j *joined) Rollback() error {
+	if j.done {
+		return sql.ErrTxDone
+	}
+	j.done = true
+	j.shared.rollbackOnly = true
+
+	return nil
+}
@@ -2,43 +2,83 @@ package transactionscript
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/sqltx"
 )
 
-type sqlDbClient interface {
-	Exec(ctx context.Context, query string)
+type RequestModel struct {
+	ID     string `json:"id"`
+	Weight int    `json:"weight"`
 }
 
-type RequestModel struct {
+type ResponseModel struct {
 	ID     string `json:"id"`
 	Weight int    `json:"weight"`
 }
 
 type Controller struct {
-	dbClient sqlDbClient
+	createResource http.Handler
+}
+
+func NewController(db txBeginner) *Controller {
+	return &Controller{createResource: Handler(db, CreateResourceScript)}
+}
+
+// RegisterScripts registers all of the package's transaction scripts on the router
+func RegisterScripts(router *Router) {
+	Register(router, http.MethodPost, "/resources", CreateResourceScript)
 }
 
-func NewController(dbClient sqlDbClient) *Controller {
-	return &Controller{dbClient: dbClient}
+// CreateResourceScript creates a fictious resource using the transaction script pattern. For more
+// advanced scenarios it would do all of its work in the transaction it's given, so it remains a
+// single transaction script.
+var CreateResourceScript = Script[RequestModel, ResponseModel]{
+	Validate: validateCreateResource,
+	Run: func(ctx context.Context, tx Tx, reqModel RequestModel) (ResponseModel, error) {
+		// This paragraph inserts a record in a database. The primary key makes sure the resource
+		// doesn't already exist, even when it's created concurrently.
+		query := "INSERT INTO example_tbl (id, weight) VALUES ($1, $2);"
+		if _, err := tx.ExecContext(ctx, query, reqModel.ID, reqModel.Weight); err != nil {
+			if sqltx.IsUniqueViolation(err) {
+				return ResponseModel{}, Conflict("resource with id = %s already exists", reqModel.ID)
+			}
+
+			return ResponseModel{}, fmt.Errorf("could not insert resource: %w", err)
+		}
+
+		return ResponseModel(reqModel), nil
+	},
+	Status: http.StatusCreated,
+}
+
+const (
+	minWeight = 1
+	maxWeight = 1000
+)
+
+func validateCreateResource(reqModel RequestModel) error {
+	var invalidParams []InvalidParam
+	if _, err := uuid.Parse(reqModel.ID); err != nil {
+		invalidParams = append(invalidParams, InvalidParam{Name: "id", Reason: "must be a UUID"})
+	}
+
+	if reqModel.Weight < minWeight || reqModel.Weight > maxWeight {
+		reason := fmt.Sprintf("must be between %d and %d", minWeight, maxWeight)
+		invalidParams = append(invalidParams, InvalidParam{Name: "weight", Reason: reason})
+	}
+
+	if len(invalidParams) > 0 {
+		return InvalidFields(invalidParams...)
+	}
+
+	return nil
 }
 
 // CreateResource is an HTTP endpoint that creates a fictious resource using the transaction script pattern.
-// Note! Error handling and input validation have been omitted in this example, but if they were included
-// they would all be part of the same transaction script i.e. the CreateResource method.
+// See CreateResourceScript for the transaction script itself.
 func (c *Controller) CreateResource(w http.ResponseWriter, r *http.Request) {
-	body, _ := io.ReadAll(r.Body)
-	var reqModel RequestModel
-	json.Unmarshal(body, &reqModel)
-
-	// This paragraph inserts a record in a database. For more advanced scenarios in which a database transaction
-	// would be needed, it'd be all be done in this HTTP handler as a single transaction script.
-	ctx := r.Context()
-	query := fmt.Sprintf("INSERT INTO example_tbl (id, weight) VALUES('%s', '%d');", reqModel.ID, reqModel.Weight)
-	c.dbClient.Exec(ctx, query)
-
-	w.WriteHeader(http.StatusCreated)
-	w.Write(nil)
+	c.createResource.ServeHTTP(w, r)
 }
@@ -0,0 +1,103 @@
+package transactionscript
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/sqltx"
+)
+
+func TestController_CreateResource(t *testing.T) {
+	const existingID = "0f8fad5b-d9cb-469f-a165-70867728950e"
+
+	tests := []struct {
+		name              string
+		body              string
+		dropTable         bool
+		wantStatus        int
+		wantInvalidParams []InvalidParam
+	}{
+		{
+			name:       "should create resource",
+			body:       `{"id":"7c9e6679-7425-40de-944b-e07fc1f90ae7","weight":80}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "should reject unknown fields",
+			body:       `{"id":"7c9e6679-7425-40de-944b-e07fc1f90ae7","weight":80,"height":180}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should reject trailing data",
+			body:       `{"id":"7c9e6679-7425-40de-944b-e07fc1f90ae7","weight":80}{}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should reject wrongly typed fields",
+			body:       `{"id":"7c9e6679-7425-40de-944b-e07fc1f90ae7","weight":"80"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should reject bodies above the size limit",
+			body:       `{"id":"` + strings.Repeat("a", DefaultMaxBodyBytes) + `"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should reject invalid id and weight",
+			body:       `{"id":"not-a-uuid","weight":0}`,
+			wantStatus: http.StatusBadRequest,
+			wantInvalidParams: []InvalidParam{
+				{Name: "id", Reason: "must be a UUID"},
+				{Name: "weight", Reason: "must be between 1 and 1000"},
+			},
+		},
+		{
+			name:       "should return conflict when resource already exists",
+			body:       `{"id":"` + existingID + `","weight":80}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "should return internal server error when the database fails",
+			body:       `{"id":"7c9e6679-7425-40de-944b-e07fc1f90ae7","weight":80}`,
+			dropTable:  true,
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			db := openTestDB(t)
+			_, err := db.Exec("INSERT INTO example_tbl (id, weight) VALUES ($1, 70);", existingID)
+			require.NoError(err)
+			if tt.dropTable {
+				_, err = db.Exec("DROP TABLE example_tbl;")
+				require.NoError(err)
+			}
+			controller := NewController(sqltx.NewBeginner(db))
+
+			// When
+			rec := httptest.NewRecorder()
+			controller.CreateResource(rec, httptest.NewRequest(http.MethodPost, "/resources", strings.NewReader(tt.body)))
+
+			// Then
+			require.Equal(tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusCreated {
+				return
+			}
+
+			var problem Problem
+			require.Equal(ProblemContentType, rec.Header().Get("Content-Type"))
+			require.NoError(json.Unmarshal(rec.Body.Bytes(), &problem))
+			require.Equal(tt.wantStatus, problem.Status)
+			require.Equal(tt.wantInvalidParams, problem.InvalidParams)
+			if tt.wantStatus == http.StatusInternalServerError {
+				require.Empty(problem.Detail, "internal errors must not be exposed")
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package transactionscript
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details
+const ProblemContentType = apperror.ProblemContentType
+
+// Problem is an RFC 7807 problem details object. Errors are mapped to problems by
+// apperror.WriteProblem.
+type Problem = apperror.Problem
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	body, _ := json.Marshal(v)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,61 @@
+package transactionscript
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+)
+
+// Router routes requests to the transaction scripts registered on it by method and path
+type Router struct {
+	db     txBeginner
+	routes map[string]map[string]http.Handler // path -> method -> handler
+}
+
+func NewRouter(db txBeginner) *Router {
+	return &Router{db: db, routes: make(map[string]map[string]http.Handler)}
+}
+
+// Register adds the script to the router. It panics if a script has already been registered
+// for the method and path since that's a programming error.
+func Register[Req, Resp any](router *Router, method, path string, script Script[Req, Resp]) {
+	if script.Run == nil {
+		panic(fmt.Sprintf("transaction script %s %s must have a Run function", method, path))
+	}
+
+	methods, ok := router.routes[path]
+	if !ok {
+		methods = make(map[string]http.Handler)
+		router.routes[path] = methods
+	}
+
+	if _, ok := methods[method]; ok {
+		panic(fmt.Sprintf("transaction script %s %s is already registered", method, path))
+	}
+	methods[method] = Handler(router.db, script)
+}
+
+func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	methods, ok := router.routes[r.URL.Path]
+	if !ok {
+		apperror.WriteProblem(w, r, NotFound("no transaction script for path = %s", r.URL.Path))
+		return
+	}
+
+	handler, ok := methods[r.Method]
+	if !ok {
+		allowed := make([]string, 0, len(methods))
+		for method := range methods {
+			allowed = append(allowed, method)
+		}
+		sort.Strings(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		apperror.NewStatusProblem(r, http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not allowed for path = %s", r.Method, r.URL.Path)).Write(w)
+		return
+	}
+
+	handler.ServeHTTP(w, r)
+}
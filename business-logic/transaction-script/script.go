@@ -0,0 +1,196 @@
+package transactionscript
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/apperror"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/sqltx"
+)
+
+type (
+	// Tx is the database transaction a transaction script runs in
+	Tx interface {
+		ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+		QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+		QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	}
+
+	txBeginner interface {
+		BeginTx(ctx context.Context, opts *sql.TxOptions) (sqltx.Tx, error)
+	}
+)
+
+// Script is a transaction script for a single command. It's made up of the steps every script goes
+// through, which lets the Handler take care of the parts that are the same for all of them such as
+// transaction management, error handling and response mapping.
+type Script[Req, Resp any] struct {
+	// Decode binds the request model. Defaults to decoding the request body as JSON.
+	Decode func(r *http.Request) (Req, error)
+
+	// Validate checks the request model before the transaction begins. Optional.
+	Validate func(req Req) error
+
+	// Run is the transaction script itself. The transaction is committed if it returns nil.
+	Run func(ctx context.Context, tx Tx, req Req) (Resp, error)
+
+	// Status is the HTTP status code of a successful response. Defaults to 200 OK.
+	Status int
+
+	// MaxBodyBytes limits the size of the request body. Defaults to DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+}
+
+// DefaultMaxBodyBytes is the request body size limit of scripts that don't set their own
+const DefaultMaxBodyBytes = 1 << 20
+
+// Handler returns an HTTP handler that runs the script in a database transaction started on db
+func Handler[Req, Resp any](db txBeginner, script Script[Req, Resp]) http.Handler {
+	if script.Decode == nil {
+		script.Decode = DecodeJSON[Req]
+	}
+
+	if script.Status == 0 {
+		script.Status = http.StatusOK
+	}
+
+	if script.MaxBodyBytes == 0 {
+		script.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if err := limitBody(r, script.MaxBodyBytes); err != nil {
+			apperror.WriteProblem(w, r, err)
+			return
+		}
+
+		// Bind request model
+		req, err := script.Decode(r)
+		if err != nil {
+			apperror.WriteProblem(w, r, apperror.Ensure(err, apperror.CategoryValidation))
+			return
+		}
+
+		// Validate request model
+		if script.Validate != nil {
+			if err = script.Validate(req); err != nil {
+				apperror.WriteProblem(w, r, apperror.Ensure(err, apperror.CategoryValidation))
+				return
+			}
+		}
+
+		// Run the transaction script
+		resp, err := runInTx(ctx, db, script.Run, req)
+		if err != nil {
+			apperror.WriteProblem(w, r, err)
+			return
+		}
+
+		writeJSON(w, script.Status, resp)
+	})
+}
+
+func runInTx[Req, Resp any](ctx context.Context, db txBeginner, run func(context.Context, Tx, Req) (Resp, error), req Req) (resp Resp, err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return resp, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if resp, err = run(ctx, tx, req); err != nil {
+		return resp, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return resp, fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return resp, nil
+}
+
+// limitBody reads the request body up front so that requests with bodies larger than
+// maxBytes are rejected before anything else happens
+func limitBody(r *http.Request, maxBytes int64) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBytes+1))
+	if err != nil {
+		return Invalid("could not read request body")
+	}
+
+	if int64(len(body)) > maxBytes {
+		return Invalid("request body must not be larger than %d bytes", maxBytes)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return nil
+}
+
+// DecodeJSON strictly decodes the request body as a single JSON value into a request model.
+// Unknown fields are rejected so that misspelled fields don't go unnoticed.
+func DecodeJSON[Req any](r *http.Request) (Req, error) {
+	var reqModel Req
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&reqModel); err != nil {
+		return reqModel, Invalid("could not decode request body: %s", describeDecodeError(err))
+	}
+
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return reqModel, Invalid("request body must contain a single JSON value")
+	}
+
+	return reqModel, nil
+}
+
+func describeDecodeError(err error) string {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return "body must not be empty"
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.As(err, &syntaxErr):
+		return "malformed JSON"
+	case errors.As(err, &typeErr):
+		return fmt.Sprintf("field %s must be of type %s", typeErr.Field, typeErr.Type)
+	default:
+		// covers unknown fields, for which encoding/json has no typed error
+		return err.Error()
+	}
+}
+
+// InvalidParam describes why a request field failed validation
+type InvalidParam = apperror.InvalidParam
+
+// Invalid returns an error for a request that isn't valid
+func Invalid(format string, args ...any) error {
+	return apperror.Validation("", format, args...)
+}
+
+// InvalidFields returns an error for a request whose fields failed validation
+func InvalidFields(params ...InvalidParam) error {
+	return apperror.InvalidFields(params...)
+}
+
+// NotFound returns an error for a resource that doesn't exist
+func NotFound(format string, args ...any) error {
+	return apperror.NotFound("", format, args...)
+}
+
+// Conflict returns an error for a request that conflicts with the current state of a resource
+func Conflict(format string, args ...any) error {
+	return apperror.Conflict("", format, args...)
+}
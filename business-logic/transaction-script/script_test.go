@@ -0,0 +1,141 @@
+package transactionscript
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/migrations"
+	"github.com/tobbstr-examples/business-logic-patterns/business-logic/sqltx"
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "transactionscript.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	runner, err := migrations.NewRunner(db, migrations.SQLite)
+	require.NoError(t, err)
+	require.NoError(t, runner.Up(context.Background()))
+
+	return db
+}
+
+func countResources(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM example_tbl;").Scan(&count))
+
+	return count
+}
+
+func TestRouter(t *testing.T) {
+	failingScript := Script[RequestModel, ResponseModel]{
+		Run: func(ctx context.Context, tx Tx, req RequestModel) (ResponseModel, error) {
+			// the insert must be rolled back since the script fails
+			if _, err := tx.ExecContext(ctx, "INSERT INTO example_tbl (id, weight) VALUES ($1, $2);", req.ID, req.Weight); err != nil {
+				return ResponseModel{}, err
+			}
+			return ResponseModel{}, Conflict("resource = %s already exists", req.ID)
+		},
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		body          string
+		wantStatus    int
+		wantBody      string
+		wantAllow     string
+		wantResources int
+	}{
+		{
+			name:          "should create resource",
+			method:        http.MethodPost,
+			path:          "/resources",
+			body:          `{"id":"9b3b7f4e-6b59-4c1e-9d2a-5d0f0c1b7e11","weight":70}`,
+			wantStatus:    http.StatusCreated,
+			wantBody:      `{"id":"9b3b7f4e-6b59-4c1e-9d2a-5d0f0c1b7e11","weight":70}`,
+			wantResources: 1,
+		},
+		{
+			name:       "should return bad request when body can't be decoded",
+			method:     http.MethodPost,
+			path:       "/resources",
+			body:       `{"id":`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should return method not allowed for unregistered method",
+			method:     http.MethodGet,
+			path:       "/resources",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantAllow:  http.MethodPost,
+		},
+		{
+			name:       "should list all allowed methods when method isn't allowed",
+			method:     http.MethodGet,
+			path:       "/failing",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantAllow:  "POST, PUT",
+		},
+		{
+			name:       "should return not found for unregistered path",
+			method:     http.MethodPost,
+			path:       "/unknown",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:          "should map script error and roll back",
+			method:        http.MethodPost,
+			path:          "/failing",
+			body:          `{"id":"1","weight":70}`,
+			wantStatus:    http.StatusConflict,
+			wantBody:      `{"type":"about:blank","title":"Conflict","status":409,"detail":"resource = 1 already exists","instance":"/failing"}`,
+			wantResources: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Given
+			require := require.New(t)
+			db := openTestDB(t)
+			router := NewRouter(sqltx.NewBeginner(db))
+			RegisterScripts(router)
+			Register(router, http.MethodPost, "/failing", failingScript)
+			Register(router, http.MethodPut, "/failing", failingScript)
+
+			// When
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			// Then
+			require.Equal(tt.wantStatus, rec.Code)
+			if tt.wantStatus < http.StatusBadRequest {
+				require.Equal("application/json", rec.Header().Get("Content-Type"))
+			} else {
+				require.Equal(ProblemContentType, rec.Header().Get("Content-Type"))
+			}
+			if tt.wantBody != "" {
+				require.JSONEq(tt.wantBody, rec.Body.String())
+			}
+			require.Equal(tt.wantAllow, rec.Header().Get("Allow"))
+			require.Equal(tt.wantResources, countResources(t, db))
+		})
+	}
+}
+
+func TestRegister_PanicsOnDuplicate(t *testing.T) {
+	router := NewRouter(nil)
+	RegisterScripts(router)
+
+	require.Panics(t, func() { RegisterScripts(router) })
+}